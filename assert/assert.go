@@ -0,0 +1,208 @@
+package assert
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"grpc_ui_tool/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Kind is the type of check an assertion makes against a response
+type Kind string
+
+const (
+	StatusCode    Kind = "status"
+	JSONEquals    Kind = "json_equals"
+	JSONMatches   Kind = "json_matches"
+	JSONExists    Kind = "json_exists"
+	LatencyUnder  Kind = "latency_under"
+	HeaderPresent Kind = "header"
+)
+
+// Kinds lists every assertion type in the order they are offered to the user
+var Kinds = []Kind{StatusCode, JSONEquals, JSONMatches, JSONExists, LatencyUnder, HeaderPresent}
+
+// Assertion is a single expectation attached to a saved request, Path is the JSON path or header name
+// and Value is the expected value, pattern or limit depending on Kind
+type Assertion struct {
+	Kind  Kind   `json:"kind"`
+	Path  string `json:"path,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// Outcome is the result of checking one assertion
+type Outcome struct {
+	Assertion Assertion
+	Passed    bool
+	Message   string
+}
+
+// String returns a user facing name for the kind
+func (k Kind) String() string {
+	switch k {
+	case StatusCode:
+		return "Status Code"
+	case JSONEquals:
+		return "JSON Path Equals"
+	case JSONMatches:
+		return "JSON Path Matches"
+	case JSONExists:
+		return "JSON Path Exists"
+	case LatencyUnder:
+		return "Latency Under (ms)"
+	case HeaderPresent:
+		return "Header Present"
+	}
+	return string(k)
+}
+
+// Describe returns a short description of what the assertion expects
+func (a Assertion) Describe() string {
+	switch a.Kind {
+	case StatusCode:
+		return "status is " + a.Value
+	case JSONEquals:
+		return a.Path + " equals " + a.Value
+	case JSONMatches:
+		return a.Path + " matches " + a.Value
+	case JSONExists:
+		return a.Path + " exists"
+	case LatencyUnder:
+		return "latency under " + a.Value + "ms"
+	case HeaderPresent:
+		if a.Value != "" {
+			return "header " + a.Path + " is " + a.Value
+		}
+		return "header " + a.Path + " is present"
+	}
+	return string(a.Kind)
+}
+
+// Check evaluates an assertion against the response of a call and the error it returned
+func Check(a Assertion, resp *proto.Response, callErr error) Outcome {
+	passed, message := check(a, resp, callErr)
+	return Outcome{Assertion: a, Passed: passed, Message: message}
+}
+
+// CheckAll evaluates every assertion, a request without a status assertion is expected to succeed
+func CheckAll(assertions []Assertion, resp *proto.Response, callErr error) []Outcome {
+	hasStatus := false
+	for _, a := range assertions {
+		if a.Kind == StatusCode {
+			hasStatus = true
+		}
+	}
+	var outcomes []Outcome
+	if !hasStatus {
+		outcomes = append(outcomes, Check(Assertion{Kind: StatusCode, Value: codes.OK.String()}, resp, callErr))
+	}
+	for _, a := range assertions {
+		outcomes = append(outcomes, Check(a, resp, callErr))
+	}
+	return outcomes
+}
+
+func check(a Assertion, resp *proto.Response, callErr error) (bool, string) {
+	switch a.Kind {
+	case StatusCode:
+		expected, err := ParseCode(a.Value)
+		if err != nil {
+			return false, err.Error()
+		}
+		actual := status.Code(callErr)
+		if actual != expected {
+			if callErr != nil {
+				return false, fmt.Sprintf("expected status %s, got %s: %s", expected, actual, status.Convert(callErr).Message())
+			}
+			return false, fmt.Sprintf("expected status %s, got %s", expected, actual)
+		}
+		return true, "status " + actual.String()
+	case LatencyUnder:
+		limit, err := strconv.ParseFloat(a.Value, 64)
+		if err != nil {
+			return false, fmt.Sprintf("invalid latency limit %q", a.Value)
+		}
+		if resp == nil || resp.Duration == 0 {
+			return false, "call was not made"
+		}
+		actual := float64(resp.Duration) / float64(time.Millisecond)
+		if actual >= limit {
+			return false, fmt.Sprintf("latency %.2fms is not under %sms", actual, a.Value)
+		}
+		return true, fmt.Sprintf("latency %.2fms", actual)
+	case HeaderPresent:
+		if resp == nil {
+			return false, "no response"
+		}
+		key := strings.ToLower(a.Path)
+		values := append(resp.Headers.Get(key), resp.Trailers.Get(key)...)
+		if len(values) == 0 {
+			return false, "header " + key + " is missing"
+		}
+		if a.Value == "" {
+			return true, "header " + key + " is present"
+		}
+		for _, value := range values {
+			if value == a.Value {
+				return true, "header " + key + " is " + value
+			}
+		}
+		return false, fmt.Sprintf("header %s is %q, expected %q", key, strings.Join(values, ", "), a.Value)
+	case JSONEquals, JSONMatches, JSONExists:
+		if callErr != nil || resp == nil {
+			return false, "no response body to check"
+		}
+		value, found, err := Lookup(resp.Body, a.Path)
+		if err != nil {
+			return false, err.Error()
+		}
+		if !found {
+			return false, a.Path + " does not exist"
+		}
+		actual := Text(value)
+		switch a.Kind {
+		case JSONExists:
+			return true, a.Path + " exists"
+		case JSONEquals:
+			expected := strings.Trim(a.Value, `"`)
+			if actual != expected {
+				return false, fmt.Sprintf("%s is %q, expected %q", a.Path, actual, expected)
+			}
+			return true, fmt.Sprintf("%s is %q", a.Path, actual)
+		default:
+			re, err := regexp.Compile(a.Value)
+			if err != nil {
+				return false, fmt.Sprintf("invalid pattern: %v", err)
+			}
+			if !re.MatchString(actual) {
+				return false, fmt.Sprintf("%s is %q which does not match %s", a.Path, actual, a.Value)
+			}
+			return true, fmt.Sprintf("%s is %q", a.Path, actual)
+		}
+	}
+	return false, fmt.Sprintf("unknown assertion %q", a.Kind)
+}
+
+// ParseCode accepts a status code name such as NOT_FOUND or NotFound, or its number
+func ParseCode(value string) (codes.Code, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return codes.OK, nil
+	}
+	if n, err := strconv.Atoi(value); err == nil {
+		return codes.Code(n), nil
+	}
+	normalised := strings.ToLower(strings.ReplaceAll(value, "_", ""))
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if strings.ToLower(c.String()) == normalised {
+			return c, nil
+		}
+	}
+	return codes.Unknown, fmt.Errorf("unknown status code %q", value)
+}
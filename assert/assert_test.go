@@ -0,0 +1,103 @@
+package assert
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"grpc_ui_tool/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseCode(t *testing.T) {
+	tests := []struct {
+		value string
+		want  codes.Code
+	}{
+		{"", codes.OK},
+		{"OK", codes.OK},
+		{"NOT_FOUND", codes.NotFound},
+		{"NotFound", codes.NotFound},
+		{"not_found", codes.NotFound},
+		{" 5 ", codes.NotFound},
+		{"UNAUTHENTICATED", codes.Unauthenticated},
+		{"DEADLINE_EXCEEDED", codes.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		got, err := ParseCode(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseCode(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+	if _, err := ParseCode("NOPE"); err == nil {
+		t.Error("expected an error for an unknown code")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	resp := &proto.Response{
+		Body:     `{"user": {"id": "7", "name": "Ada"}, "roles": ["admin"]}`,
+		Headers:  metadata.Pairs("x-request-id", "abc"),
+		Trailers: metadata.Pairs("x-trailer", "t"),
+		Duration: 20 * time.Millisecond,
+	}
+	notFound := status.Error(codes.NotFound, "no such user")
+	tests := []struct {
+		name      string
+		assertion Assertion
+		resp      *proto.Response
+		err       error
+		passed    bool
+		message   string
+	}{
+		{"status ok", Assertion{Kind: StatusCode, Value: "OK"}, resp, nil, true, "status OK"},
+		{"status expected failure", Assertion{Kind: StatusCode, Value: "NOT_FOUND"}, resp, notFound, true, "status NotFound"},
+		{"status wrapped error", Assertion{Kind: StatusCode, Value: "NOT_FOUND"}, resp,
+			fmt.Errorf("call: %w", notFound), true, "status NotFound"},
+		{"status mismatch", Assertion{Kind: StatusCode, Value: "OK"}, resp, notFound, false,
+			"expected status OK, got NotFound: no such user"},
+		{"status invalid", Assertion{Kind: StatusCode, Value: "SOMETIMES"}, resp, nil, false, `unknown status code "SOMETIMES"`},
+		{"json equals", Assertion{Kind: JSONEquals, Path: "user.id", Value: `"7"`}, resp, nil, true, `user.id is "7"`},
+		{"json equals mismatch", Assertion{Kind: JSONEquals, Path: "user.name", Value: "Bob"}, resp, nil, false,
+			`user.name is "Ada", expected "Bob"`},
+		{"json matches", Assertion{Kind: JSONMatches, Path: "roles[0]", Value: "^adm"}, resp, nil, true, `roles[0] is "admin"`},
+		{"json matches invalid", Assertion{Kind: JSONMatches, Path: "roles[0]", Value: "("}, resp, nil, false,
+			"invalid pattern: error parsing regexp: missing closing ): `(`"},
+		{"json exists", Assertion{Kind: JSONExists, Path: "user"}, resp, nil, true, "user exists"},
+		{"json missing", Assertion{Kind: JSONExists, Path: "user.email"}, resp, nil, false, "user.email does not exist"},
+		{"json on error", Assertion{Kind: JSONExists, Path: "user"}, resp, notFound, false, "no response body to check"},
+		{"latency", Assertion{Kind: LatencyUnder, Value: "50"}, resp, nil, true, "latency 20.00ms"},
+		{"latency over", Assertion{Kind: LatencyUnder, Value: "10"}, resp, nil, false, "latency 20.00ms is not under 10ms"},
+		{"latency not made", Assertion{Kind: LatencyUnder, Value: "10"}, &proto.Response{}, notFound, false, "call was not made"},
+		{"header present", Assertion{Kind: HeaderPresent, Path: "X-Request-Id"}, resp, nil, true, "header x-request-id is present"},
+		{"trailer value", Assertion{Kind: HeaderPresent, Path: "x-trailer", Value: "t"}, resp, nil, true, "header x-trailer is t"},
+		{"header missing", Assertion{Kind: HeaderPresent, Path: "x-other"}, resp, nil, false, "header x-other is missing"},
+		{"header on failed call", Assertion{Kind: HeaderPresent, Path: "x-request-id"}, resp, notFound, true,
+			"header x-request-id is present"},
+		{"unknown kind", Assertion{Kind: "size"}, resp, nil, false, `unknown assertion "size"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome := Check(tt.assertion, tt.resp, tt.err)
+			if outcome.Passed != tt.passed || outcome.Message != tt.message {
+				t.Errorf("got %v %q, want %v %q", outcome.Passed, outcome.Message, tt.passed, tt.message)
+			}
+		})
+	}
+}
+
+func TestCheckAllExpectsSuccessWithoutStatus(t *testing.T) {
+	notFound := status.Error(codes.NotFound, "gone")
+	outcomes := CheckAll([]Assertion{{Kind: LatencyUnder, Value: "1000"}}, &proto.Response{Duration: time.Millisecond}, notFound)
+	if len(outcomes) != 2 || outcomes[0].Assertion.Kind != StatusCode || outcomes[0].Passed {
+		t.Fatalf("expected an implicit failing OK status first, got %+v", outcomes)
+	}
+
+	outcomes = CheckAll([]Assertion{{Kind: StatusCode, Value: "NOT_FOUND"}}, &proto.Response{}, notFound)
+	if len(outcomes) != 1 || !outcomes[0].Passed {
+		t.Fatalf("expected the explicit status to replace the implicit one, got %+v", outcomes)
+	}
+}
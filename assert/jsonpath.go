@@ -0,0 +1,94 @@
+package assert
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Lookup finds the value at path in a JSON document, paths are dotted field names with optional array indexes
+// such as $.items[0].id or items.0.id. The bool is false when the path does not exist
+func Lookup(document string, path string) (interface{}, bool, error) {
+	var root interface{}
+	decoder := json.NewDecoder(strings.NewReader(document))
+	decoder.UseNumber()
+	if err := decoder.Decode(&root); err != nil {
+		return nil, false, fmt.Errorf("response is not valid JSON: %w", err)
+	}
+
+	segments, err := splitPath(path)
+	if err != nil {
+		return nil, false, err
+	}
+	current := root
+	for _, segment := range segments {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, false, nil
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil {
+				return nil, false, fmt.Errorf("path %s: %q is not an array index", path, segment)
+			}
+			if index < 0 {
+				index += len(node)
+			}
+			if index < 0 || index >= len(node) {
+				return nil, false, nil
+			}
+			current = node[index]
+		default:
+			return nil, false, nil
+		}
+	}
+	return current, true, nil
+}
+
+func splitPath(path string) ([]string, error) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	var segments []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			segments = append(segments, current.String())
+			current.Reset()
+		}
+	}
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '.':
+			flush()
+		case '[':
+			flush()
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("path %s: unterminated [", path)
+			}
+			segments = append(segments, strings.Trim(path[i+1:i+end], `"'`))
+			i += end
+		default:
+			current.WriteByte(path[i])
+		}
+	}
+	flush()
+	return segments, nil
+}
+
+// Text returns the printable form of a JSON value, strings are returned without quotes
+func Text(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case nil:
+		return "null"
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
@@ -0,0 +1,112 @@
+package assert
+
+import (
+	"testing"
+)
+
+const document = `{
+  "id": "42",
+  "count": 3,
+  "ratio": 0.5,
+  "big": 12345678901234567890,
+  "active": true,
+  "missing": null,
+  "items": [{"id": "a", "tags": ["x", "y"]}, {"id": "b"}],
+  "nested": {"dotted.key": "value", "empty": {}}
+}`
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		path  string
+		found bool
+		text  string
+	}{
+		{"id", true, "42"},
+		{"$.id", true, "42"},
+		{"count", true, "3"},
+		{"ratio", true, "0.5"},
+		{"big", true, "12345678901234567890"},
+		{"active", true, "true"},
+		{"missing", true, "null"},
+		{"items[0].id", true, "a"},
+		{"$.items[1].id", true, "b"},
+		{"items.0.id", true, "a"},
+		{"items[-1].id", true, "b"},
+		{"items[0].tags[1]", true, "y"},
+		{"items[2]", false, ""},
+		{"items[-3]", false, ""},
+		{`nested["dotted.key"]`, true, "value"},
+		{"nested['dotted.key']", true, "value"},
+		{"nested.empty", true, "{}"},
+		{"items[0].tags", true, `["x","y"]`},
+		{"absent", false, ""},
+		{"id.deeper", false, ""},
+		{"", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			value, found, err := Lookup(document, tt.path)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if found != tt.found {
+				t.Fatalf("found = %v, want %v", found, tt.found)
+			}
+			if found && tt.path != "" && Text(value) != tt.text {
+				t.Errorf("Text = %q, want %q", Text(value), tt.text)
+			}
+		})
+	}
+}
+
+func TestLookupErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		path     string
+		want     string
+	}{
+		{"invalid json", `{"id":`, "id", "response is not valid JSON: unexpected EOF"},
+		{"unterminated index", document, "items[0", "path items[0: unterminated ["},
+		{"non numeric index", document, "items.first", `path items.first: "first" is not an array index`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Lookup(tt.document, tt.path)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"a.b.c", []string{"a", "b", "c"}},
+		{"$.a[0].b", []string{"a", "0", "b"}},
+		{" $a ", []string{"a"}},
+		{"a..b", []string{"a", "b"}},
+		{`a["b.c"][1]`, []string{"a", "b.c", "1"}},
+		{"$", nil},
+	}
+	for _, tt := range tests {
+		got, err := splitPath(tt.path)
+		if err != nil {
+			t.Errorf("splitPath(%q): %v", tt.path, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("splitPath(%q) = %q, want %q", tt.path, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("splitPath(%q) = %q, want %q", tt.path, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// Type selects how the credentials for a server are obtained
+type Type string
+
+const (
+	None         Type = ""
+	StaticToken  Type = "static"
+	TokenFile    Type = "file"
+	ExecCommand  Type = "exec"
+	OAuth2Client Type = "oauth2"
+)
+
+// Types lists every provider in the order they are offered to the user
+var Types = []Type{None, StaticToken, TokenFile, ExecCommand, OAuth2Client}
+
+// expirySkew refreshes cached tokens slightly before they actually expire
+const expirySkew = 30 * time.Second
+
+// Config holds the settings for every provider type, only the fields used by Type are relevant
+type Config struct {
+	Type   Type
+	Header string
+	Scheme string
+
+	Token   string
+	File    string
+	Command string
+
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       string
+	Audience     string
+}
+
+// String returns a user facing name for the provider type
+func (t Type) String() string {
+	switch t {
+	case None:
+		return "None"
+	case StaticToken:
+		return "Static Token"
+	case TokenFile:
+		return "Token File"
+	case ExecCommand:
+		return "Exec Command"
+	case OAuth2Client:
+		return "OAuth2 Client Credentials"
+	}
+	return string(t)
+}
+
+// ParseType returns the type with the given name as used in configuration files
+func ParseType(name string) (Type, error) {
+	for _, t := range Types {
+		if string(t) == name {
+			return t, nil
+		}
+	}
+	return None, fmt.Errorf("unknown credential provider %q", name)
+}
+
+// Provider attaches credentials to every call made on a connection
+type Provider interface {
+	credentials.PerRPCCredentials
+}
+
+// token is a fetched credential along with when it stops being valid, a zero expiry never expires
+type token struct {
+	value  string
+	expiry time.Time
+}
+
+func (t *token) valid() bool {
+	return t != nil && (t.expiry.IsZero() || time.Now().Add(expirySkew).Before(t.expiry))
+}
+
+// provider turns a token source into per call metadata, caching tokens until they expire
+type provider struct {
+	header string
+	scheme string
+	fetch  func(ctx context.Context) (*token, error)
+	cache  bool
+
+	mu     sync.Mutex
+	cached *token
+}
+
+// New creates the provider described by cfg, nil is returned when cfg.Type is None
+func New(cfg Config) (Provider, error) {
+	p := &provider{header: strings.ToLower(cfg.Header), scheme: cfg.Scheme}
+	if p.header == "" {
+		p.header = "authorization"
+	}
+
+	switch cfg.Type {
+	case None:
+		return nil, nil
+	case StaticToken:
+		if cfg.Token == "" {
+			return nil, fmt.Errorf("static token is empty")
+		}
+		p.fetch = func(context.Context) (*token, error) { return &token{value: cfg.Token}, nil }
+	case TokenFile:
+		if cfg.File == "" {
+			return nil, fmt.Errorf("token file is not set")
+		}
+		p.fetch = func(context.Context) (*token, error) { return readTokenFile(cfg.File) }
+	case ExecCommand:
+		if strings.TrimSpace(cfg.Command) == "" {
+			return nil, fmt.Errorf("credential command is not set")
+		}
+		p.fetch = func(ctx context.Context) (*token, error) { return execToken(ctx, cfg.Command) }
+		p.cache = true
+	case OAuth2Client:
+		if cfg.TokenURL == "" || cfg.ClientID == "" {
+			return nil, fmt.Errorf("oauth2 token url and client id are required")
+		}
+		p.fetch = func(ctx context.Context) (*token, error) { return clientCredentialsToken(ctx, cfg) }
+		p.cache = true
+		if p.scheme == "" {
+			p.scheme = "Bearer"
+		}
+	default:
+		return nil, fmt.Errorf("unknown credential provider %q", cfg.Type)
+	}
+	return p, nil
+}
+
+// GetRequestMetadata returns the credential metadata for a single call
+func (p *provider) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	t, err := p.token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("credentials: %w", err)
+	}
+	value := t.value
+	if p.scheme != "" {
+		value = p.scheme + " " + value
+	}
+	return map[string]string{p.header: value}, nil
+}
+
+// RequireTransportSecurity is false so credentials can also be used against plaintext development servers
+func (p *provider) RequireTransportSecurity() bool {
+	return false
+}
+
+func (p *provider) token(ctx context.Context) (*token, error) {
+	if !p.cache {
+		return p.fetch(ctx)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cached.valid() {
+		return p.cached, nil
+	}
+	t, err := p.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.cached = t
+	return t, nil
+}
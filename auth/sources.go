@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"grpc_ui_tool/shell"
+)
+
+const fetchTimeout = 30 * time.Second
+
+func readTokenFile(path string) (*token, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	value := strings.TrimSpace(string(contents))
+	if value == "" {
+		return nil, fmt.Errorf("token file %s is empty", path)
+	}
+	return &token{value: value}, nil
+}
+
+// execCredential is the output of a kubectl style exec credential plugin, a bare token and expiry are also accepted
+type execCredential struct {
+	Status struct {
+		Token               string    `json:"token"`
+		ExpirationTimestamp time.Time `json:"expirationTimestamp"`
+	} `json:"status"`
+	Token  string    `json:"token"`
+	Expiry time.Time `json:"expiry"`
+}
+
+// execToken runs command, split into arguments with shell quoting rules but without a shell, and reads an exec
+// credential from its standard output
+func execToken(ctx context.Context, command string) (*token, error) {
+	args, err := shell.Split(command)
+	if err != nil {
+		return nil, fmt.Errorf("credential command: %w", err)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("credential command is not set")
+	}
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	cred := &execCredential{}
+	if err := json.Unmarshal(stdout.Bytes(), cred); err != nil {
+		return nil, fmt.Errorf("%s: invalid credential output: %w", args[0], err)
+	}
+	if cred.Status.Token != "" {
+		return &token{value: cred.Status.Token, expiry: cred.Status.ExpirationTimestamp}, nil
+	}
+	if cred.Token != "" {
+		return &token{value: cred.Token, expiry: cred.Expiry}, nil
+	}
+	return nil, fmt.Errorf("%s: credential output contains no token", args[0])
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// clientCredentialsToken requests a token from an OAuth2 token endpoint using the client credentials grant
+func clientCredentialsToken(ctx context.Context, cfg Config) (*token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if cfg.Scopes != "" {
+		form.Set("scope", strings.Join(strings.FieldsFunc(cfg.Scopes, func(r rune) bool { return r == ',' || r == ' ' }), " "))
+	}
+	if cfg.Audience != "" {
+		form.Set("audience", cfg.Audience)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	tr := &tokenResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(tr); err != nil {
+		return nil, fmt.Errorf("token endpoint returned %s: %w", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK || tr.Error != "" {
+		return nil, fmt.Errorf("token endpoint returned %s: %s %s", resp.Status, tr.Error, tr.ErrorDescription)
+	}
+	if tr.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint returned no access token")
+	}
+
+	t := &token{value: tr.AccessToken}
+	if tr.ExpiresIn > 0 {
+		t.expiry = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return t, nil
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestHelperProcess is run by the exec tests as the credential command, printing the output named by its arguments
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	switch args[1] {
+	case "exec-credential":
+		fmt.Printf(`{"kind": "ExecCredential", "status": {"token": %q, "expirationTimestamp": %q}}`, args[2], args[3])
+	case "bare":
+		fmt.Printf(`{"token": %q}`, args[2])
+	case "empty":
+		fmt.Print(`{"status": {}}`)
+	case "invalid":
+		fmt.Print("token")
+	case "fail":
+		fmt.Fprint(os.Stderr, "not logged in")
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// helperCommand returns a credential command running TestHelperProcess with args
+func helperCommand(t *testing.T, args ...string) string {
+	t.Setenv("GO_WANT_HELPER_PROCESS", "1")
+	return fmt.Sprintf("'%s' -test.run=TestHelperProcess -- %s", os.Args[0], strings.Join(args, " "))
+}
+
+func TestExecToken(t *testing.T) {
+	expiry := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	tests := []struct {
+		name   string
+		args   []string
+		token  string
+		expiry time.Time
+		err    string
+	}{
+		{"exec credential", []string{"exec-credential", "abc", expiry.Format(time.RFC3339)}, "abc", expiry, ""},
+		{"bare token", []string{"bare", "def"}, "def", time.Time{}, ""},
+		{"no token", []string{"empty"}, "", time.Time{}, "credential output contains no token"},
+		{"invalid output", []string{"invalid"}, "", time.Time{}, "invalid credential output"},
+		{"failing command", []string{"fail"}, "", time.Time{}, "not logged in"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := execToken(context.Background(), helperCommand(t, tt.args...))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.value != tt.token || !got.expiry.Equal(tt.expiry) {
+				t.Errorf("got %q expiring %v, want %q expiring %v", got.value, got.expiry, tt.token, tt.expiry)
+			}
+		})
+	}
+}
+
+func TestExecProviderCachesUntilExpiry(t *testing.T) {
+	tests := []struct {
+		name   string
+		expiry time.Duration
+		cached bool
+	}{
+		{"valid for an hour", time.Hour, true},
+		// within the skew the token is fetched again for every call
+		{"expiring in ten seconds", 10 * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expiry := time.Now().Add(tt.expiry).UTC().Format(time.RFC3339)
+			p, err := New(Config{Type: ExecCommand, Command: helperCommand(t, "exec-credential", "abc", expiry)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			first, err := p.(*provider).token(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := p.(*provider).token(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (first == second) != tt.cached {
+				t.Errorf("cached %v, want %v", first == second, tt.cached)
+			}
+		})
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		header   string
+		fetches  int64
+		err      string
+	}{
+		{"cached until expiry", http.StatusOK, `{"access_token": "abc", "token_type": "Bearer", "expires_in": 3600}`,
+			"Bearer abc", 1, ""},
+		{"no expiry", http.StatusOK, `{"access_token": "abc"}`, "Bearer abc", 1, ""},
+		{"expires within the skew", http.StatusOK, `{"access_token": "abc", "expires_in": 10}`, "Bearer abc", 2, ""},
+		{"error response", http.StatusUnauthorized, `{"error": "invalid_client", "error_description": "bad secret"}`,
+			"", 1, "401 Unauthorized: invalid_client bad secret"},
+		{"no access token", http.StatusOK, `{"token_type": "Bearer"}`, "", 1, "no access token"},
+		{"not json", http.StatusBadGateway, "bad gateway", "", 1, "token endpoint returned 502 Bad Gateway"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetches atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fetches.Add(1)
+				id, secret, _ := r.BasicAuth()
+				if err := r.ParseForm(); err != nil {
+					t.Error(err)
+				}
+				if id != "client" || secret != "s3cret" || r.Form.Get("grant_type") != "client_credentials" ||
+					r.Form.Get("scope") != "read write admin" || r.Form.Get("audience") != "api" {
+					t.Errorf("unexpected token request from %s:%s: %v", id, secret, r.Form)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			p, err := New(Config{Type: OAuth2Client, TokenURL: server.URL, ClientID: "client", ClientSecret: "s3cret",
+				Scopes: "read,write admin", Audience: "api"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2; i++ {
+				md, err := p.GetRequestMetadata(context.Background())
+				if tt.err != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.err) {
+						t.Fatalf("got error %v, want %q", err, tt.err)
+					}
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if md["authorization"] != tt.header {
+					t.Errorf("metadata %v, want authorization %q", md, tt.header)
+				}
+			}
+			if got := fetches.Load(); got != tt.fetches {
+				t.Errorf("the token endpoint was called %d times, want %d", got, tt.fetches)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"grpc_ui_tool/proto"
+
+	"google.golang.org/grpc/status"
+)
+
+const histogramBuckets = 10
+
+// Options configures a benchmark run, it stops at whichever of Total or Duration is reached first
+type Options struct {
+	Concurrency int
+	Connections int
+	Total       int
+	Duration    time.Duration
+	QPS         float64
+	Timeout     time.Duration
+}
+
+// Latency summarises call latencies in milliseconds
+type Latency struct {
+	Min  float64 `json:"min_ms"`
+	Mean float64 `json:"mean_ms"`
+	Max  float64 `json:"max_ms"`
+	P50  float64 `json:"p50_ms"`
+	P90  float64 `json:"p90_ms"`
+	P95  float64 `json:"p95_ms"`
+	P99  float64 `json:"p99_ms"`
+}
+
+// Bucket counts the calls with a latency up to Upper milliseconds and above the previous bucket
+type Bucket struct {
+	Upper float64 `json:"upper_ms"`
+	Count int     `json:"count"`
+}
+
+// Result is the outcome of a benchmark run
+type Result struct {
+	Target      string         `json:"target"`
+	Method      string         `json:"method"`
+	Concurrency int            `json:"concurrency"`
+	Connections int            `json:"connections"`
+	Total       int            `json:"total"`
+	Duration    float64        `json:"duration_s"`
+	Throughput  float64        `json:"throughput_rps"`
+	Latency     Latency        `json:"latency"`
+	Histogram   []Bucket       `json:"histogram"`
+	Statuses    map[string]int `json:"statuses"`
+	Errors      map[string]int `json:"errors,omitempty"`
+}
+
+// Run invokes call repeatedly against connections created by gcd and reports the results, template actions in the
+// call are evaluated again for every call. progress is called periodically with the number of completed calls if it
+// is not nil
+func Run(ctx context.Context, gcd *proto.GrpcConnection, call *proto.Call, opts Options, progress func(done int)) (*Result, error) {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Connections < 1 {
+		opts.Connections = 1
+	}
+	if opts.Connections > opts.Concurrency {
+		return nil, fmt.Errorf("%d connections is more than the concurrency of %d, each worker uses one connection",
+			opts.Connections, opts.Concurrency)
+	}
+	if opts.Total < 1 && opts.Duration <= 0 {
+		return nil, fmt.Errorf("a total number of calls or a duration is required")
+	}
+	var interval time.Duration
+	if opts.QPS > 0 {
+		var err error
+		if interval, err = pace(opts.QPS); err != nil {
+			return nil, err
+		}
+	}
+
+	// calls are not logged, recording every call would add to the latencies being measured
+	unlogged := gcd.WithVariables(nil)
+	unlogged.SetDebugLog(nil)
+	unlogged.SetBinaryLog(nil)
+	conns := make([]proto.Conn, opts.Connections)
+	for i := range conns {
+		conn, err := unlogged.Dial(call.Target)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		conns[i] = conn
+	}
+
+	// a call that cannot be prepared again stops the run
+	ctx, stopRun := context.WithCancel(ctx)
+	defer stopRun()
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	var ticks <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	var started, done int64
+	var mu sync.Mutex
+	var prepareErr error
+	var latencies []time.Duration
+	statuses := make(map[string]int)
+	errs := make(map[string]int)
+
+	var wg sync.WaitGroup
+	stopProgress := make(chan struct{})
+	if progress != nil {
+		go func() {
+			ticker := time.NewTicker(250 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					progress(int(atomic.LoadInt64(&done)))
+				case <-stopProgress:
+					return
+				}
+			}
+		}()
+	}
+
+	start := time.Now()
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		go func(conn proto.Conn) {
+			defer wg.Done()
+			for {
+				if opts.Total > 0 && atomic.AddInt64(&started, 1) > int64(opts.Total) {
+					return
+				}
+				if ticks != nil {
+					select {
+					case <-ticks:
+					case <-ctx.Done():
+						return
+					}
+				}
+				if ctx.Err() != nil {
+					return
+				}
+
+				next, err := call.Next()
+				if err != nil {
+					mu.Lock()
+					if prepareErr == nil {
+						prepareErr = err
+					}
+					mu.Unlock()
+					stopRun()
+					return
+				}
+
+				callCtx := ctx
+				var cancel context.CancelFunc
+				if opts.Timeout > 0 {
+					callCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+				}
+				callStart := time.Now()
+				_, _, _, err = next.Invoke(callCtx, conn)
+				latency := time.Since(callStart)
+				if cancel != nil {
+					cancel()
+				}
+				// calls cut short by the end of the run are not counted
+				if err != nil && ctx.Err() != nil {
+					return
+				}
+
+				code := status.Code(err)
+				mu.Lock()
+				latencies = append(latencies, latency)
+				statuses[code.String()]++
+				if err != nil {
+					errs[status.Convert(err).Message()]++
+				}
+				mu.Unlock()
+				atomic.AddInt64(&done, 1)
+			}
+		}(conns[w%len(conns)])
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	close(stopProgress)
+	if progress != nil {
+		progress(int(done))
+	}
+	if prepareErr != nil {
+		return nil, prepareErr
+	}
+
+	result := &Result{
+		Target:      call.Target,
+		Method:      call.Method,
+		Concurrency: opts.Concurrency,
+		Connections: opts.Connections,
+		Total:       len(latencies),
+		Duration:    elapsed.Seconds(),
+		Statuses:    statuses,
+	}
+	if len(errs) > 0 {
+		result.Errors = errs
+	}
+	if elapsed > 0 {
+		result.Throughput = float64(len(latencies)) / elapsed.Seconds()
+	}
+	result.Latency, result.Histogram = summarise(latencies)
+	return result, nil
+}
+
+// pace returns the interval between calls for qps, the ticker that paces them needs a positive interval
+func pace(qps float64) (time.Duration, error) {
+	interval := float64(time.Second) / qps
+	if !(interval >= 1 && interval < math.MaxInt64) {
+		return 0, fmt.Errorf("qps %g is out of range, it must be at most %d", qps, int64(time.Second))
+	}
+	return time.Duration(interval), nil
+}
+
+func summarise(latencies []time.Duration) (Latency, []Bucket) {
+	if len(latencies) == 0 {
+		return Latency{}, nil
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	var sum time.Duration
+	for _, l := range latencies {
+		sum += l
+	}
+	percentile := func(p float64) float64 {
+		index := int(p/100*float64(len(latencies))+0.5) - 1
+		if index < 0 {
+			index = 0
+		}
+		if index >= len(latencies) {
+			index = len(latencies) - 1
+		}
+		return ms(latencies[index])
+	}
+	latency := Latency{
+		Min:  ms(latencies[0]),
+		Mean: ms(sum / time.Duration(len(latencies))),
+		Max:  ms(latencies[len(latencies)-1]),
+		P50:  percentile(50),
+		P90:  percentile(90),
+		P95:  percentile(95),
+		P99:  percentile(99),
+	}
+
+	buckets := make([]Bucket, histogramBuckets)
+	width := (latency.Max - latency.Min) / histogramBuckets
+	for i := range buckets {
+		buckets[i].Upper = latency.Min + width*float64(i+1)
+	}
+	for _, l := range latencies {
+		i := histogramBuckets - 1
+		if width > 0 {
+			i = int((ms(l) - latency.Min) / width)
+			if i >= histogramBuckets {
+				i = histogramBuckets - 1
+			}
+		}
+		buckets[i].Count++
+	}
+	return latency, buckets
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,68 @@
+package bench
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const histogramWidth = 40
+
+// Format renders a result as a human readable report with a text histogram
+func (r *Result) Format() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Target:       %s\n", r.Target)
+	fmt.Fprintf(&sb, "Method:       %s\n", r.Method)
+	fmt.Fprintf(&sb, "Concurrency:  %d over %d connection(s)\n", r.Concurrency, r.Connections)
+	fmt.Fprintf(&sb, "Calls:        %d in %.2fs\n", r.Total, r.Duration)
+	fmt.Fprintf(&sb, "Throughput:   %.2f calls/s\n\n", r.Throughput)
+
+	sb.WriteString("Latency (ms)\n")
+	fmt.Fprintf(&sb, "  min %.2f  mean %.2f  max %.2f\n", r.Latency.Min, r.Latency.Mean, r.Latency.Max)
+	fmt.Fprintf(&sb, "  p50 %.2f  p90 %.2f  p95 %.2f  p99 %.2f\n\n", r.Latency.P50, r.Latency.P90, r.Latency.P95, r.Latency.P99)
+
+	if len(r.Histogram) > 0 {
+		sb.WriteString("Histogram (ms)\n")
+		largest := 0
+		for _, b := range r.Histogram {
+			if b.Count > largest {
+				largest = b.Count
+			}
+		}
+		for _, b := range r.Histogram {
+			bar := 0
+			if largest > 0 {
+				bar = b.Count * histogramWidth / largest
+			}
+			fmt.Fprintf(&sb, "  %10.2f [%6d] %s\n", b.Upper, b.Count, strings.Repeat("#", bar))
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("Status Codes\n")
+	for _, code := range sortedCounts(r.Statuses) {
+		fmt.Fprintf(&sb, "  %-20s %d\n", code, r.Statuses[code])
+	}
+	if len(r.Errors) > 0 {
+		sb.WriteString("\nErrors\n")
+		for _, msg := range sortedCounts(r.Errors) {
+			fmt.Fprintf(&sb, "  [%d] %s\n", r.Errors[msg], msg)
+		}
+	}
+	return sb.String()
+}
+
+// sortedCounts returns the keys ordered by descending count
+func sortedCounts(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
@@ -0,0 +1,149 @@
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"grpc_ui_tool/assert"
+	"grpc_ui_tool/proto"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types/ref"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Source selects how a capture expression is evaluated
+type Source string
+
+const (
+	JSONPath Source = "json"
+	CEL      Source = "cel"
+)
+
+// Sources lists every capture source in the order they are offered to the user
+var Sources = []Source{JSONPath, CEL}
+
+// Capture stores a value from a response into a variable usable as {{Variable}} by later requests
+type Capture struct {
+	Variable   string `json:"variable"`
+	Source     Source `json:"source"`
+	Expression string `json:"expression"`
+}
+
+// String returns a user facing name for the source
+func (s Source) String() string {
+	switch s {
+	case JSONPath:
+		return "JSON Path"
+	case CEL:
+		return "CEL"
+	}
+	return string(s)
+}
+
+// Extract evaluates the capture against a response. CEL expressions can use response (the decoded body),
+// headers and trailers (maps of lists of strings) and status (the status code name)
+func Extract(c Capture, resp *proto.Response, callErr error) (string, error) {
+	if c.Variable == "" {
+		return "", fmt.Errorf("capture has no variable name")
+	}
+	switch c.Source {
+	case JSONPath, "":
+		if resp == nil || resp.Body == "" {
+			return "", fmt.Errorf("capture %s: no response body", c.Variable)
+		}
+		value, found, err := assert.Lookup(resp.Body, c.Expression)
+		if err != nil {
+			return "", fmt.Errorf("capture %s: %w", c.Variable, err)
+		}
+		if !found {
+			return "", fmt.Errorf("capture %s: %s does not exist", c.Variable, c.Expression)
+		}
+		return assert.Text(value), nil
+	case CEL:
+		value, err := evaluateCEL(c.Expression, resp, callErr)
+		if err != nil {
+			return "", fmt.Errorf("capture %s: %w", c.Variable, err)
+		}
+		return value, nil
+	}
+	return "", fmt.Errorf("capture %s: unknown source %q", c.Variable, c.Source)
+}
+
+func evaluateCEL(expression string, resp *proto.Response, callErr error) (string, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("response", cel.DynType),
+		cel.Variable("headers", cel.MapType(cel.StringType, cel.ListType(cel.StringType))),
+		cel.Variable("trailers", cel.MapType(cel.StringType, cel.ListType(cel.StringType))),
+		cel.Variable("status", cel.StringType),
+	)
+	if err != nil {
+		return "", err
+	}
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return "", issues.Err()
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return "", err
+	}
+
+	var body interface{} = map[string]interface{}{}
+	headers := map[string][]string{}
+	trailers := map[string][]string{}
+	if resp != nil {
+		if resp.Body != "" {
+			if err = json.Unmarshal([]byte(resp.Body), &body); err != nil {
+				return "", fmt.Errorf("response is not valid JSON: %w", err)
+			}
+		}
+		for key, values := range resp.Headers {
+			headers[key] = values
+		}
+		for key, values := range resp.Trailers {
+			trailers[key] = values
+		}
+	}
+
+	out, _, err := program.Eval(map[string]interface{}{
+		"response": body,
+		"headers":  headers,
+		"trailers": trailers,
+		"status":   status.Code(callErr).String(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return celText(out)
+}
+
+// celText converts a CEL result into the text substituted into later requests
+func celText(value ref.Val) (string, error) {
+	native := value.Value()
+	switch v := native.(type) {
+	case string:
+		return v, nil
+	case float64:
+		// JSON numbers decode as doubles, print whole numbers without an exponent
+		if v == float64(int64(v)) {
+			return fmt.Sprintf("%d", int64(v)), nil
+		}
+		return fmt.Sprint(v), nil
+	case int64, uint64, bool:
+		return fmt.Sprint(v), nil
+	}
+	converted, err := value.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err != nil {
+		return strings.TrimSpace(fmt.Sprint(native)), nil
+	}
+	encoded, err := protojson.Marshal(converted.(*structpb.Value))
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"grpc_ui_tool/bench"
+	"grpc_ui_tool/proto"
+)
+
+func runBench(grpcConn *proto.GrpcConnection, args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	var cf connectionFlags
+	cf.register(fs)
+	method := fs.String("method", "", "method to call as package.Service/Method")
+	data := fs.String("d", "", "request body as JSON, @file reads it from a file and @- from standard input")
+	var opts bench.Options
+	fs.IntVar(&opts.Concurrency, "c", 10, "number of concurrent workers")
+	fs.IntVar(&opts.Connections, "connections", 1, "number of connections shared by the workers")
+	fs.IntVar(&opts.Total, "n", 0, "total number of calls, 0 runs until -duration")
+	fs.DurationVar(&opts.Duration, "duration", 0, "how long to run for, 0 runs until -n calls are made")
+	fs.Float64Var(&opts.QPS, "qps", 0, "target calls per second across all workers, 0 is unlimited")
+	fs.DurationVar(&opts.Timeout, "timeout", 0, "timeout for each call")
+	text := fs.Bool("text", false, "print a human readable report instead of JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if opts.Total == 0 && opts.Duration == 0 {
+		opts.Total = 200
+	}
+
+	if err := cf.apply(grpcConn); err != nil {
+		return err
+	}
+	service, name, err := splitMethod(*method)
+	if err != nil {
+		return err
+	}
+	body, err := readData(*data)
+	if err != nil {
+		return err
+	}
+	md, err := cf.requestMetadata()
+	if err != nil {
+		return err
+	}
+	call, err := grpcConn.Prepare(service, name, body, md)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	result, err := bench.Run(ctx, grpcConn, call, opts, func(done int) {
+		fmt.Fprintf(os.Stderr, "\r%d calls", done)
+	})
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	if *text {
+		fmt.Print(result.Format())
+		return nil
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
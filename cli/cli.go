@@ -0,0 +1,302 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"grpc_ui_tool/config"
+	"grpc_ui_tool/expand"
+	"grpc_ui_tool/proto"
+	"grpc_ui_tool/vault"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// VaultPassphraseEnv names the environment variable used to unlock the secret vault from the command line
+const VaultPassphraseEnv = "GRPC_TOOL_VAULT_PASSPHRASE"
+
+type command struct {
+	name    string
+	summary string
+	run     func(grpcConn *proto.GrpcConnection, args []string) error
+}
+
+var commands = []*command{
+	{"bench", "load test a method and print the results as JSON", runBench},
+	{"test", "run a collection's requests and assertions, optionally writing JUnit XML", runTest},
+	{"mock", "serve canned responses for every method in a proto file", runMock},
+}
+
+// IsCommand reports whether arg names a subcommand or asks for help, anything else such as the -psn_ argument macOS
+// passes to applications opened from Finder leaves the UI to start
+func IsCommand(arg string) bool {
+	if isHelp(arg) {
+		return true
+	}
+	for _, cmd := range commands {
+		if cmd.name == arg {
+			return true
+		}
+	}
+	return false
+}
+
+func isHelp(arg string) bool {
+	return arg == "help" || arg == "-h" || arg == "--help"
+}
+
+// Run executes a command line subcommand with the given arguments and returns the process exit code
+func Run(grpcConn *proto.GrpcConnection, args []string) int {
+	if isHelp(args[0]) {
+		usage()
+		return 0
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			if err := cmd.run(grpcConn, args[1:]); err == errTestsFailed {
+				return 1
+			} else if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+				return 1
+			}
+			return 0
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+	usage()
+	return 2
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: grpc_ui_tool [command] [flags], run without a command to open the UI")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// connectionFlags are shared by every command that talks to a server
+type connectionFlags struct {
+	server      string
+	host        string
+	port        string
+	protoFile   string
+	importPaths stringList
+	headers     stringList
+	environment string
+	plaintext   bool
+	insecure    bool
+	caCert      string
+	protocol    string
+	codec       string
+	binaryLog   string
+	b3          string
+	spans       string
+}
+
+func (cf *connectionFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&cf.server, "server", "", "saved .gtserver file with the connection details")
+	fs.StringVar(&cf.host, "host", "", "server hostname, overrides -server")
+	fs.StringVar(&cf.port, "port", "", "server port, overrides -server")
+	fs.StringVar(&cf.protoFile, "proto", "", ".proto file describing the service")
+	fs.Var(&cf.importPaths, "import-path", "proto import path, may be repeated")
+	fs.Var(&cf.headers, "H", "metadata as 'key: value', may be repeated")
+	fs.StringVar(&cf.environment, "env", "", "environment to substitute variables from, defaults to the one selected in the UI")
+	fs.BoolVar(&cf.plaintext, "plaintext", false, "connect without TLS, overrides -server")
+	fs.BoolVar(&cf.insecure, "insecure", false, "use TLS without verifying the server certificate, overrides -server")
+	fs.StringVar(&cf.caCert, "cacert", "", "verify the server certificate against this PEM file, overrides -server")
+	fs.StringVar(&cf.protocol, "protocol", "", "grpc, grpc-web, grpc-web-text, connect or connect-stream, overrides -server")
+	fs.StringVar(&cf.codec, "codec", "", "proto or json message encoding for the connect protocols, overrides -server")
+	fs.StringVar(&cf.binaryLog, "binary-log", "", "write a gRPC binary log of every call to this file")
+	fs.StringVar(&cf.b3, "b3", "", "also send B3 trace headers, single or multi, overrides -server")
+	fs.StringVar(&cf.spans, "spans", "", "export a client span of every call to this OTLP JSON file, or to a collector "+
+		"when an http:// or https:// URL, overrides -server")
+}
+
+// savePin stores a certificate pinned on first use in the server file, so later runs refuse a different certificate
+func (cf *connectionFlags) savePin(pin string) {
+	server, err := config.LoadServer(cf.server)
+	if err == nil {
+		server.TLS.Pin = pin
+		err = config.SaveServer(cf.server, server)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pinned certificate %s not saved: %v\n", pin, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "pinned certificate %s in %s\n", pin, cf.server)
+}
+
+// apply configures grpcConn from the flags, loading the server, registry, environment and secret vault
+func (cf *connectionFlags) apply(grpcConn *proto.GrpcConnection) error {
+	server := &config.Server{Metadata: metadata.MD{}}
+	if cf.server != "" {
+		var err error
+		server, err = config.LoadServer(cf.server)
+		if err != nil {
+			return err
+		}
+	}
+	if cf.host != "" {
+		server.Hostname = cf.host
+	}
+	if cf.port != "" {
+		server.Port = cf.port
+	}
+	if server.Hostname == "" && len(server.Addresses) == 0 {
+		return fmt.Errorf("a -server file or -host is required")
+	}
+	grpcConn.SetConnectionDetails(server.Hostname, server.Port, server.Metadata)
+	grpcConn.SetAddresses(server.Addresses, server.LoadBalancing)
+	grpcConn.SetAuth(server.Auth)
+	switch {
+	case cf.plaintext:
+		server.TLS = proto.TLSConfig{Mode: proto.TLSPlaintext}
+	case cf.caCert != "":
+		server.TLS = proto.TLSConfig{Mode: proto.TLSVerify, CACert: cf.caCert}
+	case cf.insecure:
+		server.TLS = proto.TLSConfig{Mode: proto.TLSInsecure}
+	}
+	grpcConn.SetTLS(server.TLS)
+	if server.TLS.Mode == proto.TLSTrustOnFirstUse {
+		grpcConn.OnPin = cf.savePin
+	}
+	if cf.protocol == "grpc" {
+		server.Transport.Protocol = proto.ProtocolGRPC
+	} else if cf.protocol != "" {
+		protocol, err := proto.ParseProtocol(cf.protocol)
+		if err != nil {
+			return err
+		}
+		server.Transport.Protocol = protocol
+	}
+	if cf.codec == "proto" {
+		server.Transport.Codec = proto.CodecProto
+	} else if cf.codec != "" {
+		codec, err := proto.ParseCodec(cf.codec)
+		if err != nil {
+			return err
+		}
+		server.Transport.Codec = codec
+	}
+	grpcConn.SetTransport(server.Transport)
+	grpcConn.SetRoute(server.Route)
+	grpcConn.SetTuning(server.Tuning)
+	if cf.b3 != "" {
+		mode, err := proto.ParseB3Mode(cf.b3)
+		if err != nil {
+			return err
+		}
+		server.Trace.B3 = mode
+	}
+	if cf.spans != "" {
+		server.Trace.Export, server.Trace.Destination = proto.ExportFile, cf.spans
+		if strings.HasPrefix(cf.spans, "http://") || strings.HasPrefix(cf.spans, "https://") {
+			server.Trace.Export = proto.ExportCollector
+		}
+	}
+	grpcConn.SetTrace(server.Trace)
+	grpcConn.SetServiceConfig(server.ServiceConfig)
+
+	if cf.protoFile == "" {
+		return fmt.Errorf("-proto is required")
+	}
+	if err := grpcConn.LoadRegistry(cf.importPaths, cf.protoFile); err != nil {
+		return err
+	}
+	if err := grpcConn.CheckServiceConfig(); err != nil {
+		return err
+	}
+
+	envs, err := config.LoadEnvironments()
+	if err != nil {
+		return err
+	}
+	if cf.environment != "" {
+		if envs.Get(cf.environment) == nil {
+			return fmt.Errorf("unknown environment %q", cf.environment)
+		}
+		envs.Active = cf.environment
+	}
+	grpcConn.SetVariables(envs.ActiveVariables())
+
+	if passphrase := os.Getenv(VaultPassphraseEnv); passphrase != "" {
+		secrets, err := vault.Open()
+		if err != nil {
+			return err
+		}
+		if err = secrets.Unlock(passphrase); err != nil {
+			return fmt.Errorf("secret vault: %w", err)
+		}
+		expand.SetSecretLookup(secrets.Get)
+	}
+
+	// entries are written as each call happens, so the file is complete without being closed
+	if cf.binaryLog != "" {
+		rules, err := config.LoadRedactRules()
+		if err != nil {
+			return err
+		}
+		binaryLog, err := proto.CreateBinaryLog(cf.binaryLog, rules)
+		if err != nil {
+			return err
+		}
+		grpcConn.SetBinaryLog(binaryLog)
+	}
+	return nil
+}
+
+// requestMetadata parses the -H flags
+func (cf *connectionFlags) requestMetadata() (metadata.MD, error) {
+	md := metadata.MD{}
+	for _, header := range cf.headers {
+		key, value, found := strings.Cut(header, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid header %q, expected 'key: value'", header)
+		}
+		key = proto.MetadataKey(strings.TrimSpace(key))
+		md[key] = append(md[key], strings.TrimSpace(value))
+	}
+	return md, nil
+}
+
+// splitMethod splits a method given as package.Service/Method or package.Service.Method
+func splitMethod(method string) (string, string, error) {
+	if service, name, found := strings.Cut(strings.TrimPrefix(method, "/"), "/"); found {
+		return service, name, nil
+	}
+	if i := strings.LastIndex(method, "."); i > 0 {
+		return method[:i], method[i+1:], nil
+	}
+	return "", "", fmt.Errorf("invalid method %q, expected package.Service/Method", method)
+}
+
+// readData returns the request body, @path reads it from a file and @- from standard input
+func readData(data string) (string, error) {
+	if data == "" {
+		return "{}", nil
+	}
+	if !strings.HasPrefix(data, "@") {
+		return data, nil
+	}
+	if data == "@-" {
+		contents, err := io.ReadAll(os.Stdin)
+		return string(contents), err
+	}
+	contents, err := os.ReadFile(data[1:])
+	return string(contents), err
+}
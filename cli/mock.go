@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"grpc_ui_tool/config"
+	"grpc_ui_tool/mock"
+	"grpc_ui_tool/proto"
+
+	"google.golang.org/grpc/status"
+)
+
+func runMock(grpcConn *proto.GrpcConnection, args []string) error {
+	fs := flag.NewFlagSet("mock", flag.ContinueOnError)
+	protoFile := fs.String("proto", "", ".proto file describing the services to mock")
+	var importPaths stringList
+	fs.Var(&importPaths, "import-path", "proto import path, may be repeated")
+	responses := fs.String("responses", "", ".gtmock file with the canned response for each method")
+	var opts mock.Options
+	fs.StringVar(&opts.Address, "listen", "127.0.0.1:50051", "address to listen on")
+	fs.BoolVar(&opts.Reflection, "reflection", false, "enable the server reflection service")
+	fs.BoolVar(&opts.Plaintext, "plaintext", false, "serve without TLS instead of using a self signed certificate")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *protoFile == "" {
+		return fmt.Errorf("-proto is required")
+	}
+	if err := grpcConn.LoadRegistry(importPaths, *protoFile); err != nil {
+		return err
+	}
+	mocks := &config.Mocks{}
+	if *responses != "" {
+		var err error
+		if mocks, err = config.LoadMocks(*responses); err != nil {
+			return err
+		}
+	}
+
+	server := mock.New(grpcConn.FileRegistry, mocks, func(call mock.Call) {
+		result := "OK"
+		if call.Err != nil {
+			result = status.Code(call.Err).String()
+		}
+		fmt.Fprintf(os.Stderr, "%s %s %s %s\n", call.Time.Format("15:04:05.000"), call.Method, result, call.Request)
+	})
+	addr, err := server.Start(opts)
+	if err != nil {
+		return err
+	}
+	defer server.Stop()
+	fmt.Fprintf(os.Stderr, "mock server listening on %s\n", addr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	<-ctx.Done()
+	return nil
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"grpc_ui_tool/config"
+	"grpc_ui_tool/proto"
+	"grpc_ui_tool/suite"
+)
+
+// errTestsFailed makes the command exit non zero without printing an extra message
+var errTestsFailed = errors.New("tests failed")
+
+func runTest(grpcConn *proto.GrpcConnection, args []string) error {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var cf connectionFlags
+	cf.register(fs)
+	collectionFile := fs.String("collection", "", ".gtcollection file to run")
+	junit := fs.String("junit", "", "write a JUnit XML report to this file")
+	quiet := fs.Bool("q", false, "only print the summary")
+	var opts suite.Options
+	fs.BoolVar(&opts.StopOnFailure, "stop-on-failure", false, "skip the remaining requests after a failure, useful for chained requests")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *collectionFile == "" {
+		return fmt.Errorf("-collection is required")
+	}
+
+	collection, err := config.LoadCollection(*collectionFile)
+	if err != nil {
+		return err
+	}
+	if cf.server == "" && cf.host == "" {
+		cf.server = config.ResolvePath(*collectionFile, collection.Server)
+	}
+	if cf.protoFile == "" {
+		cf.protoFile = config.ResolvePath(*collectionFile, collection.Proto)
+		if len(cf.importPaths) == 0 {
+			for _, path := range collection.ImportPaths {
+				cf.importPaths = append(cf.importPaths, config.ResolvePath(*collectionFile, path))
+			}
+		}
+	}
+	if err = cf.apply(grpcConn); err != nil {
+		return err
+	}
+
+	report := suite.Run(grpcConn, collection, opts, func(index int, result *suite.CaseResult) {
+		if !*quiet && result.Status == suite.Running {
+			fmt.Fprintf(os.Stderr, "[%d/%d] %s\n", index+1, len(collection.Requests), result.Request.Name)
+		}
+		// a span that could not be exported leaves the result alone but the trace would be missing it
+		if result.Response != nil && result.Response.SpanError != nil && result.Status != suite.Running {
+			fmt.Fprintf(os.Stderr, "%s: span not exported: %v\n", result.Request.Name, result.Response.SpanError)
+		}
+	})
+	fmt.Print(report.Format())
+
+	if *junit != "" {
+		file, err := os.Create(*junit)
+		if err != nil {
+			return err
+		}
+		if err = report.WriteJUnit(file); err != nil {
+			_ = file.Close()
+			return err
+		}
+		if err = file.Close(); err != nil {
+			return err
+		}
+	}
+
+	if report.Failures() > 0 {
+		return errTestsFailed
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"grpc_ui_tool/assert"
+	"grpc_ui_tool/capture"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// SavedRequest is a request stored in a collection so it can be sent again or run as a test
+type SavedRequest struct {
+	Name       string             `json:"name"`
+	Service    string             `json:"service"`
+	Method     string             `json:"method"`
+	Body       string             `json:"body"`
+	Metadata   metadata.MD        `json:"metadata,omitempty"`
+	Assertions []assert.Assertion `json:"assertions,omitempty"`
+	Captures   []capture.Capture  `json:"captures,omitempty"`
+}
+
+// Collection is an ordered set of saved requests stored in a .gtcollection file. Server, Proto and ImportPaths are
+// optional and relative to the collection file, when set they are used to run the collection without the UI
+type Collection struct {
+	Name        string          `json:"name"`
+	Server      string          `json:"server,omitempty"`
+	Proto       string          `json:"proto,omitempty"`
+	ImportPaths []string        `json:"import_paths,omitempty"`
+	Requests    []*SavedRequest `json:"requests"`
+}
+
+// LoadCollection reads a collection file
+func LoadCollection(path string) (*Collection, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	collection := &Collection{}
+	if err = json.Unmarshal(contents, collection); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, req := range collection.Requests {
+		if req.Service == "" || req.Method == "" {
+			return nil, fmt.Errorf("%s: request %d (%s) has no service or method", path, i+1, req.Name)
+		}
+	}
+	return collection, nil
+}
+
+// SaveCollection writes a collection file
+func SaveCollection(path string, collection *Collection) error {
+	contents, err := json.MarshalIndent(collection, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(contents, '\n'), 0644)
+}
+
+// ResolvePath returns path relative to the directory of the collection file at collectionPath
+func ResolvePath(collectionPath string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(collectionPath), path)
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Environment is a named set of variables substituted into requests with {{name}}
+type Environment struct {
+	Name      string
+	Variables map[string]string
+}
+
+// Environments holds every saved environment along with the one currently selected
+type Environments struct {
+	Active string
+	List   []*Environment
+}
+
+var environmentFields = map[string]int{
+	"Active":      1,
+	"Environment": 1,
+	"Variable":    3,
+}
+
+// Get returns the environment with the given name or nil
+func (envs *Environments) Get(name string) *Environment {
+	for _, env := range envs.List {
+		if env.Name == name {
+			return env
+		}
+	}
+	return nil
+}
+
+// Names returns the names of all environments in the order they were added
+func (envs *Environments) Names() []string {
+	names := make([]string, 0, len(envs.List))
+	for _, env := range envs.List {
+		names = append(names, env.Name)
+	}
+	return names
+}
+
+// ActiveVariables returns the variables of the selected environment, nil if none is selected
+func (envs *Environments) ActiveVariables() map[string]string {
+	env := envs.Get(envs.Active)
+	if env == nil {
+		return nil
+	}
+	return env.Variables
+}
+
+// ReadEnvironments parses an environments file, errors include the offending line number
+func ReadEnvironments(r io.Reader) (*Environments, error) {
+	lines, err := readLines(r, environmentFields)
+	if err != nil {
+		return nil, err
+	}
+
+	envs := &Environments{}
+	activeLine := 0
+	for _, l := range lines {
+		switch l.key {
+		case "Active":
+			if activeLine != 0 {
+				return nil, fmt.Errorf("line %d: duplicate Active, first set on line %d", l.number, activeLine)
+			}
+			activeLine = l.number
+			envs.Active = l.fields[0]
+		case "Environment":
+			if l.fields[0] == "" {
+				return nil, fmt.Errorf("line %d: empty environment name", l.number)
+			}
+			if envs.Get(l.fields[0]) != nil {
+				return nil, fmt.Errorf("line %d: duplicate environment %q", l.number, l.fields[0])
+			}
+			envs.List = append(envs.List, &Environment{Name: l.fields[0], Variables: make(map[string]string)})
+		case "Variable":
+			env := envs.Get(l.fields[0])
+			if env == nil {
+				return nil, fmt.Errorf("line %d: variable for undeclared environment %q", l.number, l.fields[0])
+			}
+			if l.fields[1] == "" {
+				return nil, fmt.Errorf("line %d: empty variable name", l.number)
+			}
+			if _, ok := env.Variables[l.fields[1]]; ok {
+				return nil, fmt.Errorf("line %d: duplicate variable %q in environment %q", l.number, l.fields[1], l.fields[0])
+			}
+			env.Variables[l.fields[1]] = l.fields[2]
+		}
+	}
+	if envs.Active != "" && envs.Get(envs.Active) == nil {
+		return nil, fmt.Errorf("line %d: active environment %q is not declared", activeLine, envs.Active)
+	}
+	return envs, nil
+}
+
+// WriteEnvironments writes an environments file that ReadEnvironments will read back unchanged
+func WriteEnvironments(w io.Writer, envs *Environments) error {
+	if envs.Active != "" {
+		if err := writeLine(w, "Active", envs.Active); err != nil {
+			return err
+		}
+	}
+	for _, env := range envs.List {
+		if err := writeLine(w, "Environment", env.Name); err != nil {
+			return err
+		}
+		for _, key := range slices.Sorted(maps.Keys(env.Variables)) {
+			if err := writeLine(w, "Variable", env.Name, key, env.Variables[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EnvironmentsPath is where environments are stored between runs
+func EnvironmentsPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "environments.gtenv"), nil
+}
+
+// LoadEnvironments reads the stored environments, a missing file yields no environments
+func LoadEnvironments() (*Environments, error) {
+	path, err := EnvironmentsPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &Environments{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	envs, err := ReadEnvironments(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return envs, nil
+}
+
+// SaveEnvironments stores the environments for the next run
+func SaveEnvironments(envs *Environments) error {
+	path, err := EnvironmentsPath()
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = WriteEnvironments(file, envs); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// line is a single parsed entry of a configuration file along with its position in the file
+type line struct {
+	number int
+	key    string
+	fields []string
+}
+
+// escape makes a value safe to be written as a field. Colons are escaped in every field so a value reads back
+// the same whichever position it is written in, files with bare colons in the last field still read
+func escape(value string) string {
+	var sb strings.Builder
+	for _, r := range value {
+		switch r {
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case ':':
+			sb.WriteString(`\:`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// unescape reverses escape. Files written before values were escaped hold backslashes as they are, so a backslash
+// that does not start an escape sequence, such as the one of a\d+, is kept along with the character after it
+func unescape(value string) string {
+	var sb strings.Builder
+	escaped := false
+	for _, r := range value {
+		if !escaped {
+			if r == '\\' {
+				escaped = true
+			} else {
+				sb.WriteRune(r)
+			}
+			continue
+		}
+		switch r {
+		case '\\':
+			sb.WriteRune('\\')
+		case 'n':
+			sb.WriteRune('\n')
+		case 'r':
+			sb.WriteRune('\r')
+		case ':':
+			sb.WriteRune(':')
+		default:
+			sb.WriteRune('\\')
+			sb.WriteRune(r)
+		}
+		escaped = false
+	}
+	if escaped {
+		sb.WriteRune('\\')
+	}
+	return sb.String()
+}
+
+// splitFields splits a raw line on unescaped colons into at most n fields, any colons left over belong to the last field
+func splitFields(raw string, n int) []string {
+	var fields []string
+	start := 0
+	escaped := false
+	for i := 0; i < len(raw) && len(fields) < n-1; i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case raw[i] == '\\':
+			escaped = true
+		case raw[i] == ':':
+			fields = append(fields, raw[start:i])
+			start = i + 1
+		}
+	}
+	return append(fields, raw[start:])
+}
+
+// writeLine writes a key and its fields as a single escaped line
+func writeLine(w io.Writer, key string, fields ...string) error {
+	out := key
+	for _, field := range fields {
+		out += ":" + escape(field)
+	}
+	_, err := io.WriteString(w, out+"\n")
+	return err
+}
+
+// readLines parses a configuration file, fieldCounts gives the number of fields expected after each supported key
+func readLines(r io.Reader, fieldCounts map[string]int) ([]*line, error) {
+	var lines []*line
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	number := 0
+	for scanner.Scan() {
+		number++
+		raw := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(raw) == "" || strings.HasPrefix(raw, "#") {
+			continue
+		}
+		key, rest, found := strings.Cut(raw, ":")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected key:value, got %q", number, raw)
+		}
+		count, ok := fieldCounts[key]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown key %q", number, key)
+		}
+		fields := splitFields(rest, count)
+		if len(fields) != count {
+			return nil, fmt.Errorf("line %d: %s expects %d fields, got %d", number, key, count, len(fields))
+		}
+		for i, field := range fields {
+			fields[i] = unescape(field)
+		}
+		lines = append(lines, &line{number: number, key: key, fields: fields})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("line %d: %w", number+1, err)
+	}
+	return lines, nil
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// MockResponse is the canned reply of the mock server for one method. Body is expanded with the fields of the
+// request as variables, a JSON array sends one message per element from server streaming methods, and a Code
+// other than OK replies with that status and Message instead of a body
+type MockResponse struct {
+	Method  string `json:"method"`
+	Body    string `json:"body,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	Delay   string `json:"delay,omitempty"`
+}
+
+// DelayDuration parses Delay, an empty delay is zero
+func (r *MockResponse) DelayDuration() (time.Duration, error) {
+	if r.Delay == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(r.Delay)
+}
+
+// Mocks is the set of canned responses stored in a .gtmock file
+type Mocks struct {
+	Responses []*MockResponse `json:"responses"`
+}
+
+// Get returns the response for a method given as package.Service/Method, or nil
+func (m *Mocks) Get(method string) *MockResponse {
+	for _, resp := range m.Responses {
+		if resp.Method == method {
+			return resp
+		}
+	}
+	return nil
+}
+
+// Set adds or replaces the response for its method
+func (m *Mocks) Set(resp *MockResponse) {
+	for i, existing := range m.Responses {
+		if existing.Method == resp.Method {
+			m.Responses[i] = resp
+			return
+		}
+	}
+	m.Responses = append(m.Responses, resp)
+}
+
+// LoadMocks reads a mock responses file
+func LoadMocks(path string) (*Mocks, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mocks := &Mocks{}
+	if err = json.Unmarshal(contents, mocks); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, resp := range mocks.Responses {
+		if resp.Method == "" {
+			return nil, fmt.Errorf("%s: response %d has no method", path, i+1)
+		}
+		if _, err = resp.DelayDuration(); err != nil {
+			return nil, fmt.Errorf("%s: %s delay: %w", path, resp.Method, err)
+		}
+	}
+	return mocks, nil
+}
+
+// SaveMocks writes a mock responses file
+func SaveMocks(path string, mocks *Mocks) error {
+	contents, err := json.MarshalIndent(mocks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(contents, '\n'), 0644)
+}
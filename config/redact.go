@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"grpc_ui_tool/proto"
+)
+
+var redactFields = map[string]int{
+	"Redact": 2,
+}
+
+// ReadRedactRules parses a file of debug log redaction rules, errors include the offending line number
+func ReadRedactRules(r io.Reader) ([]proto.RedactRule, error) {
+	lines, err := readLines(r, redactFields)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []proto.RedactRule{}
+	for _, l := range lines {
+		rule := proto.RedactRule{Header: l.fields[0], Pattern: l.fields[1]}
+		if err = rule.Check(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", l.number, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// WriteRedactRules writes a rules file that ReadRedactRules will read back unchanged
+func WriteRedactRules(w io.Writer, rules []proto.RedactRule) error {
+	for _, rule := range rules {
+		if err := writeLine(w, "Redact", rule.Header, rule.Pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RedactRulesPath is where the debug log redaction rules are stored between runs
+func RedactRulesPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "redact.gtredact"), nil
+}
+
+// LoadRedactRules reads the stored redaction rules, a missing file yields the default rules
+func LoadRedactRules() ([]proto.RedactRule, error) {
+	path, err := RedactRulesPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return proto.DefaultRedactRules, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rules, err := ReadRedactRules(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// SaveRedactRules stores the redaction rules for the next run
+func SaveRedactRules(rules []proto.RedactRule) error {
+	path, err := RedactRulesPath()
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = WriteRedactRules(file, rules); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
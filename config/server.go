@@ -0,0 +1,523 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"grpc_ui_tool/auth"
+	"grpc_ui_tool/proto"
+	"grpc_ui_tool/route"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Server holds the connection details saved in a .gtserver file
+type Server struct {
+	Hostname      string
+	Port          string
+	Addresses     []string
+	LoadBalancing string
+	Metadata      metadata.MD
+	Auth          auth.Config
+	TLS           proto.TLSConfig
+	Transport     proto.TransportConfig
+	Route         route.Config
+	Tuning        proto.TuningConfig
+	Trace         proto.TraceConfig
+	ServiceConfig string
+}
+
+var serverFields = map[string]int{
+	"Hostname":      1,
+	"Port":          1,
+	"Address":       1,
+	"LoadBalancing": 1,
+	"Metadata":      2,
+	"Auth":          2,
+	"TLS":           2,
+	"Transport":     2,
+	"Route":         2,
+	"Tuning":        2,
+	"Trace":         2,
+	"ServiceConfig": 1,
+}
+
+// ReadServer parses a server configuration, errors include the offending line number
+func ReadServer(r io.Reader) (*Server, error) {
+	lines, err := readLines(r, serverFields)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &Server{Metadata: metadata.MD{}}
+	seen := make(map[string]int)
+	for _, l := range lines {
+		if l.key != "Metadata" && l.key != "Address" {
+			name := l.key
+			if l.key == "Auth" || l.key == "TLS" || l.key == "Transport" || l.key == "Route" || l.key == "Tuning" ||
+				l.key == "Trace" {
+				name += " " + l.fields[0]
+			}
+			if first, ok := seen[name]; ok {
+				return nil, fmt.Errorf("line %d: duplicate %s, first set on line %d", l.number, name, first)
+			}
+			seen[name] = l.number
+		}
+		switch l.key {
+		case "Hostname":
+			server.Hostname = l.fields[0]
+		case "Port":
+			server.Port = l.fields[0]
+		case "Address":
+			server.Addresses = append(server.Addresses, l.fields[0])
+		case "LoadBalancing":
+			if !slices.Contains(proto.LoadBalancingPolicies, l.fields[0]) {
+				return nil, fmt.Errorf("line %d: unknown load balancing policy %q", l.number, l.fields[0])
+			}
+			server.LoadBalancing = l.fields[0]
+		case "Metadata":
+			if l.fields[0] == "" {
+				return nil, fmt.Errorf("line %d: empty metadata key", l.number)
+			}
+			server.Metadata[l.fields[0]] = append(server.Metadata[l.fields[0]], l.fields[1])
+		case "Auth":
+			if err = setAuthField(&server.Auth, l.fields[0], l.fields[1]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", l.number, err)
+			}
+		case "TLS":
+			if err = setTLSField(&server.TLS, l.fields[0], l.fields[1]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", l.number, err)
+			}
+		case "Transport":
+			if err = setTransportField(&server.Transport, l.fields[0], l.fields[1]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", l.number, err)
+			}
+		case "Route":
+			if err = setRouteField(&server.Route, l.fields[0], l.fields[1]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", l.number, err)
+			}
+		case "Tuning":
+			if err = SetTuningField(&server.Tuning, l.fields[0], l.fields[1]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", l.number, err)
+			}
+		case "Trace":
+			if err = setTraceField(&server.Trace, l.fields[0], l.fields[1]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", l.number, err)
+			}
+		case "ServiceConfig":
+			if !json.Valid([]byte(l.fields[0])) {
+				return nil, fmt.Errorf("line %d: service config is not valid JSON", l.number)
+			}
+			server.ServiceConfig = l.fields[0]
+		}
+	}
+	return server, nil
+}
+
+// WriteServer writes a server configuration that ReadServer will read back unchanged
+func WriteServer(w io.Writer, server *Server) error {
+	if err := writeLine(w, "Hostname", server.Hostname); err != nil {
+		return err
+	}
+	if err := writeLine(w, "Port", server.Port); err != nil {
+		return err
+	}
+	for _, address := range server.Addresses {
+		if err := writeLine(w, "Address", address); err != nil {
+			return err
+		}
+	}
+	if server.LoadBalancing != "" {
+		if err := writeLine(w, "LoadBalancing", server.LoadBalancing); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(server.Metadata)) {
+		// ReadServer rejects empty keys, writing one would save a profile that cannot be opened again
+		if key == "" {
+			return fmt.Errorf("empty metadata key")
+		}
+		for _, value := range server.Metadata[key] {
+			if err := writeLine(w, "Metadata", key, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	if server.Auth.Type != auth.None {
+		for _, field := range authFields(&server.Auth) {
+			if *field.value == "" {
+				continue
+			}
+			if err := writeLine(w, "Auth", field.name, *field.value); err != nil {
+				return err
+			}
+		}
+	}
+
+	if server.TLS.Mode != proto.TLSInsecure {
+		if err := writeLine(w, "TLS", "Mode", string(server.TLS.Mode)); err != nil {
+			return err
+		}
+	}
+	if server.TLS.CACert != "" {
+		if err := writeLine(w, "TLS", "CACert", server.TLS.CACert); err != nil {
+			return err
+		}
+	}
+	if server.TLS.Pin != "" {
+		if err := writeLine(w, "TLS", "Pin", server.TLS.Pin); err != nil {
+			return err
+		}
+	}
+
+	if server.Transport.Protocol != proto.ProtocolGRPC {
+		if err := writeLine(w, "Transport", "Protocol", string(server.Transport.Protocol)); err != nil {
+			return err
+		}
+	}
+	if server.Transport.Codec != proto.CodecProto {
+		if err := writeLine(w, "Transport", "Codec", string(server.Transport.Codec)); err != nil {
+			return err
+		}
+	}
+
+	if server.Route.Type != route.Direct {
+		for _, field := range routeFields(&server.Route) {
+			if *field.value == "" {
+				continue
+			}
+			if err := writeLine(w, "Route", field.name, *field.value); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, field := range tuningFields(&server.Tuning) {
+		if value := field.get(); value != "" {
+			if err := writeLine(w, "Tuning", field.name, value); err != nil {
+				return err
+			}
+		}
+	}
+	if server.Trace.B3 != proto.B3None {
+		if err := writeLine(w, "Trace", "B3", string(server.Trace.B3)); err != nil {
+			return err
+		}
+	}
+	if server.Trace.TraceState != "" {
+		if err := writeLine(w, "Trace", "TraceState", server.Trace.TraceState); err != nil {
+			return err
+		}
+	}
+	if server.Trace.Export != proto.ExportNone {
+		if err := writeLine(w, "Trace", "Export", string(server.Trace.Export)); err != nil {
+			return err
+		}
+	}
+	if server.Trace.Destination != "" {
+		if err := writeLine(w, "Trace", "Destination", server.Trace.Destination); err != nil {
+			return err
+		}
+	}
+	if server.ServiceConfig != "" {
+		if err := writeLine(w, "ServiceConfig", server.ServiceConfig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setTLSField(cfg *proto.TLSConfig, name string, value string) error {
+	switch name {
+	case "Mode":
+		mode, err := proto.ParseTLSMode(value)
+		if err != nil {
+			return err
+		}
+		cfg.Mode = mode
+	case "CACert":
+		cfg.CACert = value
+	case "Pin":
+		cfg.Pin = value
+	default:
+		return fmt.Errorf("unknown TLS field %q", name)
+	}
+	return nil
+}
+
+func setTransportField(cfg *proto.TransportConfig, name string, value string) error {
+	switch name {
+	case "Protocol":
+		protocol, err := proto.ParseProtocol(value)
+		if err != nil {
+			return err
+		}
+		cfg.Protocol = protocol
+	case "Codec":
+		codec, err := proto.ParseCodec(value)
+		if err != nil {
+			return err
+		}
+		cfg.Codec = codec
+	default:
+		return fmt.Errorf("unknown Transport field %q", name)
+	}
+	return nil
+}
+
+func setTraceField(cfg *proto.TraceConfig, name string, value string) error {
+	switch name {
+	case "B3":
+		mode, err := proto.ParseB3Mode(value)
+		if err != nil {
+			return err
+		}
+		cfg.B3 = mode
+	case "TraceState":
+		cfg.TraceState = value
+	case "Export":
+		export, err := proto.ParseSpanExport(value)
+		if err != nil {
+			return err
+		}
+		cfg.Export = export
+	case "Destination":
+		cfg.Destination = value
+	default:
+		return fmt.Errorf("unknown Trace field %q", name)
+	}
+	return nil
+}
+
+// tuningField converts one advanced setting to and from its configuration text, the empty text is the zero value
+type tuningField struct {
+	name string
+	get  func() string
+	set  func(value string) error
+}
+
+func tuningFields(cfg *proto.TuningConfig) []tuningField {
+	intField := func(name string, value *int) tuningField {
+		return tuningField{name, func() string { return formatNonZero(int64(*value)) }, func(text string) error {
+			if text == "" {
+				*value = 0
+				return nil
+			}
+			n, err := strconv.Atoi(text)
+			if err != nil || n < 0 {
+				return fmt.Errorf("%s must be a positive number of bytes", name)
+			}
+			*value = n
+			return nil
+		}}
+	}
+	int32Field := func(name string, value *int32) tuningField {
+		return tuningField{name, func() string { return formatNonZero(int64(*value)) }, func(text string) error {
+			if text == "" {
+				*value = 0
+				return nil
+			}
+			n, err := strconv.ParseInt(text, 10, 32)
+			if err != nil || n < 0 {
+				return fmt.Errorf("%s must be a positive number of bytes", name)
+			}
+			*value = int32(n)
+			return nil
+		}}
+	}
+	durationField := func(name string, value *time.Duration) tuningField {
+		return tuningField{name, func() string {
+			if *value == 0 {
+				return ""
+			}
+			return value.String()
+		}, func(text string) error {
+			if text == "" {
+				*value = 0
+				return nil
+			}
+			d, err := time.ParseDuration(text)
+			if err != nil || d < 0 {
+				return fmt.Errorf("%s must be a duration such as 30s", name)
+			}
+			*value = d
+			return nil
+		}}
+	}
+	boolField := func(name string, value *bool) tuningField {
+		return tuningField{name, func() string {
+			if !*value {
+				return ""
+			}
+			return "true"
+		}, func(text string) error {
+			if text == "" {
+				*value = false
+				return nil
+			}
+			b, err := strconv.ParseBool(text)
+			if err != nil {
+				return fmt.Errorf("%s must be true or false", name)
+			}
+			*value = b
+			return nil
+		}}
+	}
+	textField := func(name string, value *string) tuningField {
+		return tuningField{name, func() string { return *value }, func(text string) error {
+			*value = text
+			return nil
+		}}
+	}
+
+	return []tuningField{
+		{"Compression", func() string { return cfg.Compression }, func(text string) error {
+			if !slices.Contains(proto.Compressions, text) {
+				return fmt.Errorf("unsupported compression %q", text)
+			}
+			cfg.Compression = text
+			return nil
+		}},
+		intField("MaxSendSize", &cfg.MaxSendSize),
+		intField("MaxReceiveSize", &cfg.MaxReceiveSize),
+		durationField("KeepaliveTime", &cfg.KeepaliveTime),
+		durationField("KeepaliveTimeout", &cfg.KeepaliveTimeout),
+		boolField("KeepaliveWithoutCalls", &cfg.KeepaliveWithoutCalls),
+		textField("Authority", &cfg.Authority),
+		textField("UserAgent", &cfg.UserAgent),
+		int32Field("InitialWindowSize", &cfg.InitialWindowSize),
+		int32Field("InitialConnWindowSize", &cfg.InitialConnWindowSize),
+		boolField("WaitForReady", &cfg.WaitForReady),
+	}
+}
+
+func formatNonZero(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// GetTuningField returns the text of an advanced setting as it is stored, empty for the default
+func GetTuningField(cfg *proto.TuningConfig, name string) string {
+	for _, field := range tuningFields(cfg) {
+		if field.name == name {
+			return field.get()
+		}
+	}
+	return ""
+}
+
+// SetTuningField parses the text of an advanced setting, the empty text restores the default
+func SetTuningField(cfg *proto.TuningConfig, name string, value string) error {
+	for _, field := range tuningFields(cfg) {
+		if field.name == name {
+			return field.set(value)
+		}
+	}
+	return fmt.Errorf("unknown Tuning field %q", name)
+}
+
+func routeFields(cfg *route.Config) []stringField {
+	return []stringField{
+		{"Type", (*string)(&cfg.Type)},
+		{"Address", &cfg.Address},
+		{"Username", &cfg.Username},
+		{"Password", &cfg.Password},
+		{"KeyFile", &cfg.KeyFile},
+		{"KnownHosts", &cfg.KnownHosts},
+	}
+}
+
+func setRouteField(cfg *route.Config, name string, value string) error {
+	if name == "Type" {
+		t, err := route.ParseType(value)
+		if err != nil {
+			return err
+		}
+		cfg.Type = t
+		return nil
+	}
+	for _, field := range routeFields(cfg) {
+		if field.name == name {
+			*field.value = value
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown Route field %q", name)
+}
+
+type stringField struct {
+	name  string
+	value *string
+}
+
+func authFields(cfg *auth.Config) []stringField {
+	return []stringField{
+		{"Type", (*string)(&cfg.Type)},
+		{"Header", &cfg.Header},
+		{"Scheme", &cfg.Scheme},
+		{"Token", &cfg.Token},
+		{"File", &cfg.File},
+		{"Command", &cfg.Command},
+		{"TokenURL", &cfg.TokenURL},
+		{"ClientID", &cfg.ClientID},
+		{"ClientSecret", &cfg.ClientSecret},
+		{"Scopes", &cfg.Scopes},
+		{"Audience", &cfg.Audience},
+	}
+}
+
+func setAuthField(cfg *auth.Config, name string, value string) error {
+	if name == "Type" {
+		t, err := auth.ParseType(value)
+		if err != nil {
+			return err
+		}
+		cfg.Type = t
+		return nil
+	}
+	for _, field := range authFields(cfg) {
+		if field.name == name {
+			*field.value = value
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown Auth field %q", name)
+}
+
+// LoadServer reads a server configuration from a file
+func LoadServer(path string) (*Server, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	server, err := ReadServer(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return server, nil
+}
+
+// SaveServer writes a server configuration to a file
+func SaveServer(path string, server *Server) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = WriteServer(file, server); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc_ui_tool/auth"
+	"grpc_ui_tool/proto"
+	"grpc_ui_tool/route"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestServerRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		server Server
+	}{
+		{"empty", Server{}},
+		{"host and port", Server{Hostname: "api.example.com", Port: "443"}},
+		{"target uri with colons", Server{Hostname: "dns:///api.example.com:443"}},
+		{"ipv6 host", Server{Hostname: "::1", Port: "8080"}},
+		{"static addresses", Server{Addresses: []string{"10.0.0.1:50051", "[::1]:50051", ""},
+			LoadBalancing: "round_robin"}},
+		{"multi value metadata", Server{Metadata: metadata.MD{
+			"x-tenant": {"a", "b", ""},
+			"x-empty":  {""},
+		}}},
+		{"metadata with colons and newlines", Server{Metadata: metadata.MD{
+			"x:key":    {"value:with:colons", "line one\nline two\r\n"},
+			"x-escape": {`back\slash\:`, `\n literal`},
+		}}},
+		{"metadata with templates", Server{Metadata: metadata.MD{
+			"authorization": {`Bearer {{secret "token"}}`},
+			"x-request-id":  {"{{uuid}}"},
+		}}},
+		{"static token", Server{Auth: auth.Config{Type: auth.StaticToken, Header: "x-api-key", Scheme: "Key",
+			Token: "abc:def\nghi"}}},
+		{"token file", Server{Auth: auth.Config{Type: auth.TokenFile, File: `C:\tokens\token.txt`}}},
+		{"exec command", Server{Auth: auth.Config{Type: auth.ExecCommand, Command: `kubectl get token --output "json"`}}},
+		{"oauth2", Server{Auth: auth.Config{Type: auth.OAuth2Client, TokenURL: "https://auth.example.com:8443/token",
+			ClientID: "client", ClientSecret: "s:e:c", Scopes: "read write", Audience: "api"}}},
+		{"tls verify", Server{TLS: proto.TLSConfig{Mode: proto.TLSVerify, CACert: "/etc/ssl/ca:1.pem"}}},
+		{"tls pinned", Server{TLS: proto.TLSConfig{Mode: proto.TLSTrustOnFirstUse,
+			Pin: "AB:CD:EF:01:23:45:67:89"}}},
+		{"plaintext", Server{TLS: proto.TLSConfig{Mode: proto.TLSPlaintext}}},
+		{"connect json", Server{Transport: proto.TransportConfig{Protocol: proto.ProtocolConnect, Codec: proto.CodecJSON}}},
+		{"grpc web", Server{Transport: proto.TransportConfig{Protocol: proto.ProtocolGRPCWebText}}},
+		{"http proxy", Server{Route: route.Config{Type: route.HTTPConnect, Address: "proxy:3128", Username: "user",
+			Password: "p:w"}}},
+		{"ssh tunnel", Server{Route: route.Config{Type: route.SSHTunnel, Address: "bastion:22", Username: "ops",
+			KeyFile: "~/.ssh/id_ed25519", KnownHosts: "/tmp/known_hosts"}}},
+		{"tuning", Server{Tuning: proto.TuningConfig{
+			Compression:           "gzip",
+			MaxSendSize:           1024,
+			MaxReceiveSize:        8 * 1024 * 1024,
+			KeepaliveTime:         30 * time.Second,
+			KeepaliveTimeout:      1500 * time.Millisecond,
+			KeepaliveWithoutCalls: true,
+			Authority:             "api.example.com:443",
+			UserAgent:             "tool/1.0 (linux: amd64)",
+			InitialWindowSize:     65535,
+			InitialConnWindowSize: 1 << 20,
+			WaitForReady:          true,
+		}}},
+		{"service config", Server{ServiceConfig: `{"methodConfig": [{"name": [{"service": "a.B"}], "timeout": "1s"}]}`}},
+		{"trace", Server{Trace: proto.TraceConfig{B3: proto.B3Multi, TraceState: "vendor=a:b,other=c",
+			Export: proto.ExportCollector, Destination: "http://{{host}}:4318/v1/traces"}}},
+		{"trace file", Server{Trace: proto.TraceConfig{B3: proto.B3Single, Export: proto.ExportFile,
+			Destination: "/tmp/spans.jsonl"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteServer(&buf, &tt.server); err != nil {
+				t.Fatalf("WriteServer: %v", err)
+			}
+			got, err := ReadServer(&buf)
+			if err != nil {
+				t.Fatalf("ReadServer: %v\n%s", err, buf.String())
+			}
+			want := tt.server
+			if want.Metadata == nil {
+				want.Metadata = metadata.MD{}
+			}
+			if !reflect.DeepEqual(*got, want) {
+				t.Errorf("round trip changed the server\ngot  %+v\nwant %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestWriteServerRejectsEmptyMetadataKey(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteServer(&buf, &Server{Metadata: metadata.MD{"": {"value"}}})
+	if err == nil {
+		t.Fatalf("expected an error, wrote\n%s", buf.String())
+	}
+}
+
+func TestReadServerErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"unknown key", "Colour:red\n", `line 1: unknown key "Colour"`},
+		{"empty metadata key", "Metadata::value\n", "line 1: empty metadata key"},
+		{"duplicate hostname", "Hostname:a\nHostname:b\n", "line 2: duplicate Hostname, first set on line 1"},
+		{"duplicate trace field", "Trace:B3:single\n\nTrace:B3:multi\n", "line 3: duplicate Trace B3, first set on line 1"},
+		{"unknown tls mode", "TLS:Mode:sometimes\n", `line 1: unknown TLS mode "sometimes"`},
+		{"missing field", "Metadata\n", `line 1: expected key:value, got "Metadata"`},
+		{"invalid service config", "ServiceConfig:{\n", "line 1: service config is not valid JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadServer(strings.NewReader(tt.input))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestReadServerUnescapedValues reads a file written before values were escaped
+func TestReadServerUnescapedValues(t *testing.T) {
+	server, err := ReadServer(strings.NewReader(`Metadata:x-filter:a\d+` + "\n" + `Metadata:x-path:C:\dir\` + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := server.Metadata.Get("x-filter"); len(got) != 1 || got[0] != `a\d+` {
+		t.Errorf("x-filter %q", got)
+	}
+	if got := server.Metadata.Get("x-path"); len(got) != 1 || got[0] != `C:\dir\` {
+		t.Errorf("x-path %q", got)
+	}
+}
+
+func TestReadServerBareColonsInLastField(t *testing.T) {
+	server, err := ReadServer(strings.NewReader("Hostname:dns:///host:443\nMetadata:x-time:12:30\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.Hostname != "dns:///host:443" {
+		t.Errorf("hostname %q", server.Hostname)
+	}
+	if got := server.Metadata.Get("x-time"); len(got) != 1 || got[0] != "12:30" {
+		t.Errorf("metadata %q", got)
+	}
+}
@@ -0,0 +1,176 @@
+package expand
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+var (
+	identifier   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	variableName = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+)
+
+// String evaluates every {{...}} in text, an action is either a variable name or a template function pipeline
+func String(text string, vars map[string]string) (string, error) {
+	return replace(text, vars, func(value string) string { return value })
+}
+
+// JSON evaluates every {{...}} in a JSON document, results are escaped so they are safe inside JSON strings except
+// those of the json function, such as {{json request}}, which are inserted as JSON values outside of strings
+func JSON(text string, vars map[string]string) (string, error) {
+	return replace(text, vars, escapeJSON)
+}
+
+// Metadata expands both the keys and values of request metadata, expanded keys are lowercased as gRPC sends them
+func Metadata(md map[string][]string, vars map[string]string) (map[string][]string, error) {
+	expanded := make(map[string][]string, len(md))
+	for key, values := range md {
+		expandedKey, err := String(key, vars)
+		if err != nil {
+			return nil, fmt.Errorf("metadata key %q: %w", key, err)
+		}
+		expandedKey = strings.ToLower(expandedKey)
+		for _, value := range values {
+			expandedValue, err := String(value, vars)
+			if err != nil {
+				return nil, fmt.Errorf("metadata %q: %w", key, err)
+			}
+			expanded[expandedKey] = append(expanded[expandedKey], expandedValue)
+		}
+	}
+	return expanded, nil
+}
+
+func replace(text string, vars map[string]string, escape func(string) string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	var funcs template.FuncMap
+	var evalErr error
+	// raw is set by the json function, whose result is inserted without escaping
+	var raw bool
+	result := replaceActions(text, func(match, body string) string {
+		if evalErr != nil {
+			return match
+		}
+		body = strings.TrimSpace(body)
+		if value, ok := vars[body]; ok {
+			return escape(value)
+		}
+		// variables with dots in their names cannot be passed to json in a pipeline
+		if name, ok := strings.CutPrefix(body, "json "); ok {
+			if value, ok := vars[strings.TrimSpace(name)]; ok {
+				return jsonValue(value)
+			}
+		}
+		if funcs == nil {
+			funcs = functions(vars)
+			funcs["json"] = func(value string) string {
+				raw = true
+				return jsonValue(value)
+			}
+		}
+		raw = false
+		value, err := evaluate(body, funcs)
+		if err != nil {
+			evalErr = err
+			return match
+		}
+		if raw {
+			return value
+		}
+		return escape(value)
+	})
+	if evalErr != nil {
+		return "", evalErr
+	}
+	return result, nil
+}
+
+// replaceActions replaces every {{...}} in text with the result of fn, which is given the whole action and its body.
+// Actions end at the first }} outside of a quoted string or character constant, as text/template reads them, so
+// {{printf "}}"}} is one action
+func replaceActions(text string, fn func(match, body string) string) string {
+	var sb strings.Builder
+	for {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			break
+		}
+		end := actionEnd(text[start+2:])
+		if end < 0 {
+			break
+		}
+		end += start + 2
+		sb.WriteString(text[:start])
+		sb.WriteString(fn(text[start:end+2], text[start+2:end]))
+		text = text[end+2:]
+	}
+	sb.WriteString(text)
+	return sb.String()
+}
+
+// actionEnd returns the index of the }} that closes the action starting body, or -1 when there is none. A quote
+// that is never closed is left for the template parser to report, with the action ending at the first }}
+func actionEnd(body string) int {
+	for i := 0; i < len(body); i++ {
+		switch c := body[i]; c {
+		case '}':
+			if strings.HasPrefix(body[i:], "}}") {
+				return i
+			}
+		case '`':
+			closing := strings.IndexByte(body[i+1:], '`')
+			if closing < 0 {
+				return strings.Index(body, "}}")
+			}
+			i += closing + 1
+		case '"', '\'':
+			for i++; i < len(body) && body[i] != c; i++ {
+				if body[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(body) {
+				return strings.Index(body, "}}")
+			}
+		}
+	}
+	return -1
+}
+
+// evaluate runs a single action, variables with names that are valid identifiers can be used inside pipelines
+func evaluate(body string, funcs template.FuncMap) (string, error) {
+	if variableName.MatchString(body) {
+		if _, ok := funcs[body]; !ok {
+			return "", fmt.Errorf("undefined variable %q", body)
+		}
+	}
+	tmpl, err := template.New("action").Option("missingkey=error").Funcs(funcs).Parse("{{" + body + "}}")
+	if err != nil {
+		return "", fmt.Errorf("{{%s}}: %w", body, err)
+	}
+	var sb strings.Builder
+	if err = tmpl.Execute(&sb, nil); err != nil {
+		return "", fmt.Errorf("{{%s}}: %w", body, err)
+	}
+	return sb.String(), nil
+}
+
+// jsonValue returns value as it is when it is JSON, such as an object, array or number, and as a JSON string otherwise
+func jsonValue(value string) string {
+	if trimmed := strings.TrimSpace(value); trimmed != "" && json.Valid([]byte(trimmed)) {
+		return trimmed
+	}
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
+
+func escapeJSON(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted[1 : len(quoted)-1])
+}
@@ -0,0 +1,94 @@
+package expand
+
+import (
+	"math"
+	"testing"
+)
+
+func TestJSONFunction(t *testing.T) {
+	vars := map[string]string{
+		"request":   `{"user": {"id": 7}}`,
+		"user":      `{"id": 7}`,
+		"user.id":   "7",
+		"user.name": `Ada "A"`,
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`{"echo": {{json request}}}`, `{"echo": {"user": {"id": 7}}}`},
+		{`{"echo": {{ json user }}}`, `{"echo": {"id": 7}}`},
+		{`{"id": {{json user.id}}}`, `{"id": 7}`},
+		{`{"name": {{json user.name}}}`, `{"name": "Ada \"A\""}`},
+		{`{"name": "{{user.name}}"}`, `{"name": "Ada \"A\""}`},
+		{`{"raw": "{{request}}"}`, `{"raw": "{\"user\": {\"id\": 7}}"}`},
+	}
+	for _, tt := range tests {
+		got, err := JSON(tt.text, vars)
+		if err != nil || got != tt.want {
+			t.Errorf("JSON(%s) = %s, %v, want %s", tt.text, got, err, tt.want)
+		}
+	}
+}
+
+func TestQuotedBraces(t *testing.T) {
+	vars := map[string]string{"name": "Ada"}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`{{printf "}}"}}`, `}}`},
+		{`{{printf "%s}}" name}}-{{name}}`, `Ada}}-Ada`},
+		{`{{printf "\"}}"}}`, `"}}`},
+		{"{{printf `}}`}}", `}}`},
+		{`{{printf "%c" '}'}}`, `}`},
+		{`{{name}} and {{name`, `Ada and {{name`},
+		{`no actions }}`, `no actions }}`},
+	}
+	for _, tt := range tests {
+		got, err := String(tt.text, vars)
+		if err != nil || got != tt.want {
+			t.Errorf("String(%s) = %s, %v, want %s", tt.text, got, err, tt.want)
+		}
+	}
+	if _, err := String(`{{printf "}}`, vars); err == nil {
+		t.Error("an unclosed quote did not fail")
+	}
+}
+
+func TestMetadataKeys(t *testing.T) {
+	vars := map[string]string{"TenantHeader": "X-Tenant", "tenant": "acme"}
+	md := map[string][]string{"{{TenantHeader}}": {"{{tenant}}"}, "x-tenant": {"other"}}
+	got, err := Metadata(md, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values := got["x-tenant"]; len(values) != 2 || len(got) != 1 {
+		t.Errorf("Metadata = %v, want both values under x-tenant", got)
+	}
+}
+
+func TestRandIntRanges(t *testing.T) {
+	tests := []struct {
+		min int64
+		max int64
+	}{
+		{1, 1},
+		{-5, 5},
+		{0, math.MaxInt64},
+		{math.MinInt64, 0},
+		{math.MinInt64, math.MaxInt64},
+	}
+	for _, tt := range tests {
+		n, err := randInt(tt.min, tt.max)
+		if err != nil || n < tt.min || n > tt.max {
+			t.Errorf("randInt(%d, %d) = %d, %v", tt.min, tt.max, n, err)
+		}
+	}
+	if _, err := randInt(2, 1); err == nil {
+		t.Error("randInt accepted max below min")
+	}
+	if got, err := String("{{randInt 0 9223372036854775807}}", nil); err != nil || got == "" {
+		t.Errorf("randInt over the whole positive range gave %q, %v", got, err)
+	}
+}
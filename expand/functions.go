@@ -0,0 +1,93 @@
+package expand
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"text/template"
+	"time"
+)
+
+// Time is a timestamp produced by now, printed on its own it uses the RFC 3339 form protobuf expects
+type Time struct {
+	time.Time
+}
+
+func (t Time) String() string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// secretLookup resolves {{secret "name"}}, it is set once a secret store is available
+var secretLookup func(name string) (string, error)
+
+// SetSecretLookup sets how {{secret "name"}} actions are resolved
+func SetSecretLookup(lookup func(name string) (string, error)) {
+	secretLookup = lookup
+}
+
+// functions returns the template functions available to every action along with the variables usable as identifiers
+func functions(vars map[string]string) template.FuncMap {
+	funcs := template.FuncMap{
+		"uuid":        newUUID,
+		"now":         func() Time { return Time{time.Now()} },
+		"rfc3339":     func(t Time) string { return t.UTC().Format(time.RFC3339) },
+		"rfc3339nano": func(t Time) string { return t.UTC().Format(time.RFC3339Nano) },
+		"unix":        func(t Time) int64 { return t.Unix() },
+		"unixMilli":   func(t Time) int64 { return t.UnixMilli() },
+		"format":      func(layout string, t Time) string { return t.Format(layout) },
+		"randInt":     randInt,
+		"base64":      func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+		"file":        readFile,
+		"secret":      secret,
+		"json":        jsonValue,
+	}
+	for name, value := range vars {
+		if _, builtin := funcs[name]; builtin || !identifier.MatchString(name) {
+			continue
+		}
+		value := value
+		funcs[name] = func() string { return value }
+	}
+	return funcs
+}
+
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// randInt returns a random integer between min and max inclusive
+func randInt(min int64, max int64) (int64, error) {
+	if max < min {
+		return 0, fmt.Errorf("randInt: max %d is less than min %d", max, min)
+	}
+	// the range is computed with big integers as it overflows int64 for the widest ranges
+	n := new(big.Int).Sub(big.NewInt(max), big.NewInt(min))
+	n, err := rand.Int(rand.Reader, n.Add(n, big.NewInt(1)))
+	if err != nil {
+		return 0, err
+	}
+	return n.Add(n, big.NewInt(min)).Int64(), nil
+}
+
+func readFile(path string) (string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(contents), nil
+}
+
+func secret(name string) (string, error) {
+	if secretLookup == nil {
+		return "", fmt.Errorf("secret %q: no secret store is available", name)
+	}
+	return secretLookup(name)
+}
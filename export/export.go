@@ -0,0 +1,307 @@
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"grpc_ui_tool/proto"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Request is everything needed to reproduce a call outside the tool, metadata values are as entered so those of -bin
+// keys are base64 encoded
+type Request struct {
+	Target      string
+	Method      protoreflect.MethodDescriptor
+	Body        string
+	Metadata    map[string][]string
+	ProtoFile   string
+	ImportPaths []string
+	Plaintext   bool
+	Insecure    bool
+	CACert      string
+	// connection settings that grpcurl has flags for
+	Authority      string
+	UserAgent      string
+	MaxReceiveSize int
+	KeepaliveTime  time.Duration
+}
+
+// Grpcurl returns a grpcurl command line for the request
+func Grpcurl(r *Request) string {
+	lines := []string{"grpcurl"}
+	switch {
+	case r.Plaintext:
+		lines = append(lines, "-plaintext")
+	case r.Insecure:
+		lines = append(lines, "-insecure")
+	case r.CACert != "":
+		lines = append(lines, "-cacert "+shellQuote(r.CACert))
+	}
+	if r.Authority != "" {
+		lines = append(lines, "-authority "+shellQuote(r.Authority))
+	}
+	if r.UserAgent != "" {
+		lines = append(lines, "-user-agent "+shellQuote(r.UserAgent))
+	}
+	if r.MaxReceiveSize > 0 {
+		lines = append(lines, "-max-msg-sz "+strconv.Itoa(r.MaxReceiveSize))
+	}
+	if r.KeepaliveTime > 0 {
+		lines = append(lines, "-keepalive-time "+strconv.FormatFloat(r.KeepaliveTime.Seconds(), 'f', -1, 64))
+	}
+	for _, importPath := range r.ImportPaths {
+		lines = append(lines, "-import-path "+shellQuote(importPath))
+	}
+	if r.ProtoFile != "" {
+		lines = append(lines, "-proto "+shellQuote(r.ProtoFile))
+	}
+	for _, key := range slices.Sorted(maps.Keys(r.Metadata)) {
+		for _, value := range r.Metadata[key] {
+			// grpcurl decodes -bin values from base64, padded standard base64 is the form every version accepts
+			if raw, ok := binaryValue(key, value); ok {
+				value = base64.StdEncoding.EncodeToString(raw)
+			}
+			lines = append(lines, "-H "+shellQuote(key+": "+value))
+		}
+	}
+	target := r.Target
+	// grpcurl takes unix sockets as a flag and other resolver schemes are not understood
+	if path, ok := strings.CutPrefix(target, "unix:"); ok {
+		lines = append(lines, "-unix")
+		target = strings.TrimPrefix(path, "//")
+	} else if _, address, ok := strings.Cut(target, ":///"); ok {
+		target = address
+	}
+	lines = append(lines, "-d "+shellQuote(compact(r.Body)))
+	lines = append(lines, shellQuote(target)+" "+shellQuote(string(r.Method.Parent().FullName())+"/"+string(r.Method.Name())))
+	return strings.Join(lines, " \\\n  ")
+}
+
+// Go returns a client program for the request using the Go code generated from the proto file,
+// the import path of the generated package is taken from its go_package option when it has one
+func Go(r *Request) string {
+	service := r.Method.Parent().(protoreflect.ServiceDescriptor)
+	goPackage := "example.com/gen/" + strings.ReplaceAll(string(r.Method.ParentFile().Package()), ".", "/")
+	if options, ok := r.Method.ParentFile().Options().(*descriptorpb.FileOptions); ok && options.GetGoPackage() != "" {
+		goPackage, _, _ = strings.Cut(options.GetGoPackage(), ";")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("package main\n\n")
+	sb.WriteString("import (\n\t\"context\"\n")
+	if r.Insecure && !r.Plaintext {
+		sb.WriteString("\t\"crypto/tls\"\n")
+	}
+	sb.WriteString("\t\"fmt\"\n\t\"log\"\n\t\"time\"\n\n")
+	sb.WriteString(fmt.Sprintf("\tpb %q\n\n", goPackage))
+	sb.WriteString("\t\"google.golang.org/grpc\"\n")
+	if r.Plaintext {
+		sb.WriteString("\t\"google.golang.org/grpc/credentials/insecure\"\n")
+	} else {
+		sb.WriteString("\t\"google.golang.org/grpc/credentials\"\n")
+	}
+	if len(r.Metadata) > 0 {
+		sb.WriteString("\t\"google.golang.org/grpc/metadata\"\n")
+	}
+	sb.WriteString("\t\"google.golang.org/protobuf/encoding/protojson\"\n)\n\n")
+	sb.WriteString("func main() {\n")
+	switch {
+	case r.Plaintext:
+		sb.WriteString("\tcreds := insecure.NewCredentials()\n")
+	case r.Insecure:
+		sb.WriteString("\tcreds := credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})\n")
+	case r.CACert != "":
+		sb.WriteString(fmt.Sprintf("\tcreds, err := credentials.NewClientTLSFromFile(%q, \"\")\n", r.CACert))
+		sb.WriteString("\tif err != nil {\n\t\tlog.Fatal(err)\n\t}\n")
+	default:
+		sb.WriteString("\tcreds := credentials.NewTLS(nil)\n")
+	}
+	sb.WriteString(fmt.Sprintf("\tconn, err := grpc.NewClient(%q, grpc.WithTransportCredentials(creds))\n", r.Target))
+	sb.WriteString("\tif err != nil {\n\t\tlog.Fatal(err)\n\t}\n\tdefer conn.Close()\n\n")
+	sb.WriteString(fmt.Sprintf("\treq := &pb.%s{}\n", goName(r.Method.Input())))
+	sb.WriteString(fmt.Sprintf("\tif err = protojson.Unmarshal([]byte(%s), req); err != nil {\n\t\tlog.Fatal(err)\n\t}\n\n", goString(r.Body)))
+	sb.WriteString("\tctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)\n\tdefer cancel()\n")
+	if len(r.Metadata) > 0 {
+		sb.WriteString("\tctx = metadata.AppendToOutgoingContext(ctx")
+		for _, key := range slices.Sorted(maps.Keys(r.Metadata)) {
+			for _, value := range r.Metadata[key] {
+				// grpc base64 encodes -bin values itself
+				if raw, ok := binaryValue(key, value); ok {
+					value = string(raw)
+				}
+				sb.WriteString(fmt.Sprintf(",\n\t\t%q, %q", key, value))
+			}
+		}
+		sb.WriteString(")\n")
+	}
+	sb.WriteString(fmt.Sprintf("\n\tresp, err := pb.New%sClient(conn).%s(ctx, req)\n", service.Name(), r.Method.Name()))
+	sb.WriteString("\tif err != nil {\n\t\tlog.Fatal(err)\n\t}\n\tfmt.Println(protojson.Format(resp))\n}\n")
+	return sb.String()
+}
+
+// Python returns a client script for the request using the modules generated by grpcio-tools from the proto file
+func Python(r *Request) string {
+	service := r.Method.Parent().(protoreflect.ServiceDescriptor)
+	module := strings.ReplaceAll(strings.TrimSuffix(r.Method.ParentFile().Path(), ".proto"), "/", ".")
+	moduleName := path.Base(strings.ReplaceAll(module, ".", "/"))
+
+	var sb strings.Builder
+	sb.WriteString("import grpc\nfrom google.protobuf import json_format\n\n")
+	if strings.Contains(module, ".") {
+		parent := module[:strings.LastIndex(module, ".")]
+		sb.WriteString(fmt.Sprintf("from %s import %s_pb2, %s_pb2_grpc\n\n", parent, moduleName, moduleName))
+	} else {
+		sb.WriteString(fmt.Sprintf("import %s_pb2\nimport %s_pb2_grpc\n\n", moduleName, moduleName))
+	}
+	switch {
+	case r.Plaintext:
+		sb.WriteString(fmt.Sprintf("with grpc.insecure_channel(%s) as channel:\n", pyString(r.Target)))
+	case r.CACert != "":
+		sb.WriteString(fmt.Sprintf("with open(%s, \"rb\") as f:\n", pyString(r.CACert)))
+		sb.WriteString("    credentials = grpc.ssl_channel_credentials(root_certificates=f.read())\n")
+		sb.WriteString(fmt.Sprintf("with grpc.secure_channel(%s, credentials) as channel:\n", pyString(r.Target)))
+	default:
+		if r.Insecure {
+			sb.WriteString("# the server certificate is not verified by the tool, for a self signed certificate pass its\n")
+			sb.WriteString("# PEM contents as root_certificates\n")
+		}
+		sb.WriteString("credentials = grpc.ssl_channel_credentials()\n")
+		sb.WriteString(fmt.Sprintf("with grpc.secure_channel(%s, credentials) as channel:\n", pyString(r.Target)))
+	}
+	sb.WriteString(fmt.Sprintf("    stub = %s_pb2_grpc.%sStub(channel)\n", moduleName, service.Name()))
+	sb.WriteString(fmt.Sprintf("    request = json_format.Parse(%s, %s_pb2.%s())\n", pyString(compact(r.Body)), moduleName,
+		pyName(r.Method.Input())))
+	sb.WriteString("    metadata = [")
+	first := true
+	for _, key := range slices.Sorted(maps.Keys(r.Metadata)) {
+		for _, value := range r.Metadata[key] {
+			if !first {
+				sb.WriteString(", ")
+			}
+			first = false
+			// grpcio takes -bin values as bytes and base64 encodes them itself
+			if raw, ok := binaryValue(key, value); ok {
+				sb.WriteString(fmt.Sprintf("(%s, %s)", pyString(key), pyBytes(raw)))
+			} else {
+				sb.WriteString(fmt.Sprintf("(%s, %s)", pyString(key), pyString(value)))
+			}
+		}
+	}
+	sb.WriteString("]\n")
+	sb.WriteString(fmt.Sprintf("    response = stub.%s(request, metadata=metadata, timeout=10)\n", r.Method.Name()))
+	sb.WriteString("    print(json_format.MessageToJson(response))\n")
+	return sb.String()
+}
+
+// goName returns the generated Go type name of a message, derived from its name within the package like protoc-gen-go
+func goName(message protoreflect.MessageDescriptor) string {
+	return goCamelCase(strings.TrimPrefix(string(message.FullName()), string(message.ParentFile().Package())+"."))
+}
+
+// goCamelCase converts a proto name to a Go identifier the way protoc-gen-go does: underscores followed by a lower
+// case letter are dropped and the letter upper cased, so foo_bar becomes FooBar, and dots become underscores
+func goCamelCase(name string) string {
+	var b []byte
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '.' && i+1 < len(name) && isLower(name[i+1]):
+			// the next word is upper cased instead
+		case c == '.':
+			b = append(b, '_')
+		case c == '_' && (i == 0 || name[i-1] == '.'):
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(name) && isLower(name[i+1]):
+			// the next word is upper cased instead
+		case c >= '0' && c <= '9':
+			b = append(b, c)
+		default:
+			if isLower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			for ; i+1 < len(name) && isLower(name[i+1]); i++ {
+				b = append(b, name[i+1])
+			}
+		}
+	}
+	return string(b)
+}
+
+func isLower(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
+
+// binaryValue returns the bytes of a -bin metadata value, values that are not base64 such as secret references are
+// left as text
+func binaryValue(key string, value string) ([]byte, bool) {
+	if !strings.HasSuffix(strings.ToLower(key), "-bin") {
+		return nil, false
+	}
+	raw, err := proto.DecodeBinaryValue(value)
+	return raw, err == nil
+}
+
+// pyName returns the Python attribute path of a message in its generated module
+func pyName(message protoreflect.MessageDescriptor) string {
+	return strings.TrimPrefix(string(message.FullName()), string(message.ParentFile().Package())+".")
+}
+
+// compact removes insignificant whitespace from a JSON body so it fits on one line
+func compact(body string) string {
+	var out bytes.Buffer
+	if err := json.Compact(&out, []byte(body)); err != nil {
+		return body
+	}
+	return out.String()
+}
+
+// shellQuote quotes a value for a POSIX shell when it contains anything but safe characters
+func shellQuote(value string) string {
+	if value != "" && strings.Trim(value, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@") == "" {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func goString(value string) string {
+	if !strings.Contains(value, "`") {
+		return "`" + value + "`"
+	}
+	return fmt.Sprintf("%q", value)
+}
+
+func pyString(value string) string {
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+// pyBytes returns a Python bytes literal, printable ASCII is kept and everything else escaped
+func pyBytes(value []byte) string {
+	var sb strings.Builder
+	sb.WriteString(`b"`)
+	for _, c := range value {
+		switch {
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c >= 0x20 && c < 0x7f:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, `\x%02x`, c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
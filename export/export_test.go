@@ -0,0 +1,110 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestGoCamelCase(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Request", "Request"},
+		{"foo_bar", "FooBar"},
+		{"foo_bar_baz", "FooBarBaz"},
+		{"Outer.Inner", "Outer_Inner"},
+		{"outer.inner_msg", "OuterInnerMsg"},
+		{"Outer.Inner_Msg", "Outer_Inner_Msg"},
+		{"_private", "XPrivate"},
+		{"Outer._inner", "Outer_XInner"},
+		{"v2_request", "V2Request"},
+		{"HTTPRequest", "HTTPRequest"},
+		{"foo__bar", "Foo_Bar"},
+	}
+	for _, tt := range tests {
+		if got := goCamelCase(tt.name); got != tt.want {
+			t.Errorf("goCamelCase(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPyBytes(t *testing.T) {
+	if got, want := pyBytes([]byte("a\"b\\c\x00\xff~")), `b"a\"b\\c\x00\xff~"`; got != want {
+		t.Errorf("pyBytes = %s, want %s", got, want)
+	}
+}
+
+// method returns a method whose request type is a nested message with a snake case name, protoc-gen-go drops the
+// underscore of a dot followed by a lower case letter too
+func method(t *testing.T) protoreflect.MethodDescriptor {
+	t.Helper()
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    strPtr("demo/echo.proto"),
+		Package: strPtr("demo"),
+		Syntax:  strPtr("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name:       strPtr("outer_msg"),
+			NestedType: []*descriptorpb.DescriptorProto{{Name: strPtr("echo_request")}},
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: strPtr("Echo"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       strPtr("Say"),
+				InputType:  strPtr(".demo.outer_msg.echo_request"),
+				OutputType: strPtr(".demo.outer_msg"),
+			}},
+		}},
+	}
+	fd, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd.Services().Get(0).Methods().Get(0)
+}
+
+func strPtr(value string) *string {
+	return &value
+}
+
+func TestBinaryMetadata(t *testing.T) {
+	r := &Request{
+		Target:    "localhost:50051",
+		Method:    method(t),
+		Body:      "{}",
+		Plaintext: true,
+		Metadata: map[string][]string{
+			// \x00\xff" as unpadded URL safe base64, and a secret reference that is not base64
+			"x-trace-bin": {"AP8i"},
+			"x-key-bin":   {`{{secret "key"}}`},
+			"x-text":      {"AP8i"},
+		},
+	}
+
+	grpcurl := Grpcurl(r)
+	for _, want := range []string{"-H 'x-trace-bin: AP8i'", "-H 'x-text: AP8i'", `-H 'x-key-bin: {{secret "key"}}'`} {
+		if !strings.Contains(grpcurl, want) {
+			t.Errorf("grpcurl command is missing %s\n%s", want, grpcurl)
+		}
+	}
+
+	goClient := Go(r)
+	for _, want := range []string{`"x-trace-bin", "\x00\xff\""`, `"x-text", "AP8i"`, `"x-key-bin", "{{secret \"key\"}}"`,
+		"req := &pb.OuterMsgEchoRequest{}"} {
+		if !strings.Contains(goClient, want) {
+			t.Errorf("Go client is missing %s\n%s", want, goClient)
+		}
+	}
+
+	python := Python(r)
+	for _, want := range []string{`("x-trace-bin", b"\x00\xff\"")`, `("x-text", "AP8i")`,
+		`("x-key-bin", "{{secret \"key\"}}")`} {
+		if !strings.Contains(python, want) {
+			t.Errorf("Python client is missing %s\n%s", want, python)
+		}
+	}
+}
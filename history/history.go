@@ -0,0 +1,94 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"grpc_ui_tool/config"
+)
+
+// Entry records a single request as it was sent, with variables and template functions already resolved
+type Entry struct {
+	Time     time.Time           `json:"time"`
+	Target   string              `json:"target"`
+	Service  string              `json:"service"`
+	Method   string              `json:"method"`
+	Metadata map[string][]string `json:"metadata,omitempty"`
+	Request  string              `json:"request"`
+	Response string              `json:"response,omitempty"`
+	Error    string              `json:"error,omitempty"`
+	Duration time.Duration       `json:"duration"`
+}
+
+// Path is where the history is stored between runs
+func Path() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+// Record appends an entry to the stored history
+func Record(entry *Entry) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Load returns up to limit of the most recent entries, newest first
+func Load(limit int) ([]*Entry, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []*Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	number := 0
+	for scanner.Scan() {
+		number++
+		entry := &Entry{}
+		if err = json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return nil, fmt.Errorf("%s: line %d: %w", path, number, err)
+		}
+		entries = append(entries, entry)
+		if len(entries) > limit {
+			entries = entries[1:]
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
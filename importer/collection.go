@@ -0,0 +1,332 @@
+package importer
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"grpc_ui_tool/config"
+	"grpc_ui_tool/proto"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// Result is a collection read from another tool, with one target per distinct server its requests are sent to
+type Result struct {
+	Name      string
+	Targets   []*Target
+	Variables map[string]string
+	// ProtoFiles holds proto sources embedded in the export by their path relative to the import path
+	ProtoFiles map[string]string
+	// Skipped describes every item that could not be mapped and why
+	Skipped []string
+}
+
+// Target is a server and the requests sent to it, Proto is set when the requests were described by an embedded proto
+type Target struct {
+	Server   *config.Server
+	Proto    string
+	Requests []*config.SavedRequest
+}
+
+// Files lists what Save wrote
+type Files struct {
+	Collections []string
+	Servers     []string
+	Protos      []string
+}
+
+// target returns the target for an address such as grpcs://host:port and proto, adding it when it is new
+func (r *Result) target(address string, protoPath string) (*Target, error) {
+	address = r.resolveVariable(address)
+	tlsMode := proto.TLSPlaintext
+	lower := strings.ToLower(address)
+	switch {
+	case strings.HasPrefix(lower, "grpcs://"), strings.HasPrefix(lower, "https://"):
+		tlsMode = proto.TLSVerify
+	}
+	if i := strings.Index(address, "://"); i >= 0 {
+		address = address[i+3:]
+	}
+	address = strings.TrimSuffix(r.resolveVariable(strings.TrimSuffix(address, "/")), "/")
+	hostname, port, err := net.SplitHostPort(address)
+	if err != nil {
+		if tlsMode == proto.TLSVerify {
+			hostname, port = address, "443"
+		} else {
+			return nil, fmt.Errorf("server address %q has no port", address)
+		}
+	}
+	for _, t := range r.Targets {
+		if t.Server.Hostname == hostname && t.Server.Port == port && t.Server.TLS.Mode == tlsMode && t.Proto == protoPath {
+			return t, nil
+		}
+	}
+	t := &Target{Server: &config.Server{
+		Hostname: hostname,
+		Port:     port,
+		Metadata: metadata.MD{},
+		TLS:      proto.TLSConfig{Mode: tlsMode},
+	}, Proto: protoPath}
+	r.Targets = append(r.Targets, t)
+	return t, nil
+}
+
+// resolveVariable returns the value of a collection variable when value is nothing but a reference to it, a server
+// held in one variable has to be resolved before it can be split into a hostname and port
+func (r *Result) resolveVariable(value string) string {
+	if name, ok := strings.CutPrefix(value, "{{"); ok && strings.HasSuffix(name, "}}") {
+		if resolved, ok := r.Variables[strings.TrimSuffix(name, "}}")]; ok {
+			return resolved
+		}
+	}
+	return value
+}
+
+// skip records an item that could not be mapped
+func (r *Result) skip(name string, format string, args ...any) {
+	r.Skipped = append(r.Skipped, name+": "+fmt.Sprintf(format, args...))
+}
+
+// Requests counts the mapped requests
+func (r *Result) Requests() int {
+	count := 0
+	for _, t := range r.Targets {
+		count += len(t.Requests)
+	}
+	return count
+}
+
+// Loader loads the registry for a proto file and its import paths
+type Loader func(protoFile string, importPaths []string) (*protoregistry.Files, error)
+
+// validate drops requests whose method is not in files and records them as skipped
+func (r *Result) validate(t *Target, files *protoregistry.Files) {
+	var kept []*config.SavedRequest
+	for _, req := range t.Requests {
+		desc, err := files.FindDescriptorByName(protoreflect.FullName(req.Service + "." + req.Method))
+		if _, ok := desc.(protoreflect.MethodDescriptor); err != nil || !ok {
+			r.skip(req.Name, "method %s/%s is not in the proto files", req.Service, req.Method)
+			continue
+		}
+		kept = append(kept, req)
+	}
+	t.Requests = kept
+}
+
+// ExistsError is returned by Save when it would replace files, Save with overwrite set replaces them
+type ExistsError struct {
+	Paths []string
+}
+
+func (e *ExistsError) Error() string {
+	return "these files already exist: " + strings.Join(e.Paths, ", ")
+}
+
+// protoPath returns where an embedded proto is written below protoDir. Paths come from the export, so absolute
+// paths and paths leaving protoDir are refused
+func protoPath(protoDir string, name string) (string, error) {
+	clean := path.Clean(filepath.ToSlash(name))
+	if name == "" || path.IsAbs(clean) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" ||
+		clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("embedded proto %q is outside the proto directory", name)
+	}
+	return filepath.Join(protoDir, filepath.FromSlash(clean)), nil
+}
+
+// targetName is the base name of the server and collection files of the i-th target
+func (r *Result) targetName(i int) string {
+	if len(r.Targets) > 1 {
+		return fmt.Sprintf("%s-%d", fileName(r.Name), i+1)
+	}
+	return fileName(r.Name)
+}
+
+// ExistingFiles lists the files in dir that Save would replace. An embedded proto already on disk with the same
+// source is not listed as writing it changes nothing
+func (r *Result) ExistingFiles(dir string) ([]string, error) {
+	var existing []string
+	protoDir := filepath.Join(dir, "protos")
+	for _, name := range slices.Sorted(maps.Keys(r.ProtoFiles)) {
+		full, err := protoPath(protoDir, name)
+		if err != nil {
+			return nil, err
+		}
+		if current, err := os.ReadFile(full); err == nil && string(current) != r.ProtoFiles[name] {
+			existing = append(existing, full)
+		}
+	}
+	for i := range r.Targets {
+		for _, ext := range []string{".gtserver", ".gtcollection"} {
+			full := filepath.Join(dir, r.targetName(i)+ext)
+			if _, err := os.Stat(full); err == nil {
+				existing = append(existing, full)
+			}
+		}
+	}
+	return existing, nil
+}
+
+// Save writes the embedded protos, a server file and a collection for every target into dir. protoFile and
+// importPaths are used by targets without an embedded proto, every request is checked against the protos loaded with
+// load and requests that cannot be sent are skipped rather than saved. Existing files are only replaced when
+// overwrite is set, otherwise an *ExistsError is returned before anything is written
+func (r *Result) Save(dir string, protoFile string, importPaths []string, load Loader, overwrite bool) (*Files, error) {
+	files := &Files{}
+	existing, err := r.ExistingFiles(dir)
+	if err != nil {
+		return files, err
+	}
+	if len(existing) > 0 && !overwrite {
+		return files, &ExistsError{Paths: existing}
+	}
+	protoDir := filepath.Join(dir, "protos")
+	for name, source := range r.ProtoFiles {
+		full, err := protoPath(protoDir, name)
+		if err != nil {
+			return files, err
+		}
+		if err = os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			return files, err
+		}
+		if err = os.WriteFile(full, []byte(source), 0644); err != nil {
+			return files, err
+		}
+		files.Protos = append(files.Protos, full)
+	}
+
+	for i, t := range r.Targets {
+		targetProto, targetImports := protoFile, importPaths
+		if len(targetImports) == 0 && filepath.IsAbs(targetProto) {
+			targetImports = []string{filepath.Dir(targetProto)}
+		}
+		if t.Proto != "" {
+			if targetProto, err = protoPath(protoDir, t.Proto); err != nil {
+				return files, err
+			}
+			targetImports = []string{protoDir}
+		}
+		if targetProto == "" {
+			for _, req := range t.Requests {
+				r.skip(req.Name, "there is no proto file for %s/%s, choose one for the import", req.Service, req.Method)
+			}
+			continue
+		}
+		registry, err := load(targetProto, targetImports)
+		if err != nil {
+			for _, req := range t.Requests {
+				r.skip(req.Name, "%s: %v", filepath.Base(targetProto), err)
+			}
+			continue
+		}
+		r.validate(t, registry)
+		if len(t.Requests) == 0 {
+			continue
+		}
+
+		name := r.targetName(i)
+		serverPath := filepath.Join(dir, name+".gtserver")
+		if err = config.SaveServer(serverPath, t.Server); err != nil {
+			return files, err
+		}
+		files.Servers = append(files.Servers, serverPath)
+
+		collectionPath := filepath.Join(dir, name+".gtcollection")
+		collection := &config.Collection{
+			Name:     r.Name,
+			Server:   filepath.Base(serverPath),
+			Proto:    relativeTo(dir, targetProto),
+			Requests: t.Requests,
+		}
+		if len(r.Targets) > 1 {
+			collection.Name = r.Name + " (" + net.JoinHostPort(t.Server.Hostname, t.Server.Port) + ")"
+		}
+		for _, importPath := range targetImports {
+			collection.ImportPaths = append(collection.ImportPaths, relativeTo(dir, importPath))
+		}
+		if err = config.SaveCollection(collectionPath, collection); err != nil {
+			return files, err
+		}
+		files.Collections = append(files.Collections, collectionPath)
+	}
+	return files, nil
+}
+
+var unsafeFileName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func fileName(name string) string {
+	name = strings.Trim(unsafeFileName.ReplaceAllString(name, "-"), "-.")
+	if name == "" {
+		return "imported"
+	}
+	return name
+}
+
+func relativeTo(dir string, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// splitMethodPath splits /package.Service/Method or package.Service/Method
+func splitMethodPath(path string) (string, string, bool) {
+	service, method, found := strings.Cut(strings.TrimPrefix(strings.TrimSpace(path), "/"), "/")
+	if !found || service == "" || method == "" {
+		return "", "", false
+	}
+	return service, method, true
+}
+
+var (
+	postmanDynamic = map[string]string{
+		"$guid":         "{{uuid}}",
+		"$randomUUID":   "{{uuid}}",
+		"$timestamp":    "{{now | unix}}",
+		"$isoTimestamp": "{{now | rfc3339}}",
+		"$randomInt":    "{{randInt 0 1000}}",
+	}
+	postmanVariable  = regexp.MustCompile(`{{\s*([^{}]+?)\s*}}`)
+	insomniaVariable = regexp.MustCompile(`{{\s*_\.([A-Za-z0-9_.-]+)\s*}}`)
+	insomniaTag      = regexp.MustCompile(`{%\s*([A-Za-z]+)[^%]*%}`)
+)
+
+// convertPostman rewrites Postman dynamic variables into template functions, {{name}} variables are kept as they
+// use the same syntax. Unknown dynamic variables are reported through unsupported
+func convertPostman(text string, unsupported func(string)) string {
+	return postmanVariable.ReplaceAllStringFunc(text, func(match string) string {
+		name := postmanVariable.FindStringSubmatch(match)[1]
+		if !strings.HasPrefix(name, "$") {
+			return "{{" + name + "}}"
+		}
+		if replacement, ok := postmanDynamic[name]; ok {
+			return replacement
+		}
+		unsupported(name)
+		return match
+	})
+}
+
+// convertInsomnia rewrites {{ _.name }} variables and the uuid and now tags, other tags are reported through unsupported
+func convertInsomnia(text string, unsupported func(string)) string {
+	text = insomniaVariable.ReplaceAllString(text, "{{$1}}")
+	return insomniaTag.ReplaceAllStringFunc(text, func(match string) string {
+		switch insomniaTag.FindStringSubmatch(match)[1] {
+		case "uuid":
+			return "{{uuid}}"
+		case "now":
+			return "{{now | rfc3339}}"
+		}
+		unsupported(match)
+		return match
+	})
+}
@@ -0,0 +1,103 @@
+package importer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"grpc_ui_tool/config"
+
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+func TestProtoPath(t *testing.T) {
+	protoDir := filepath.Join("out", "protos")
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"echo.proto", filepath.Join(protoDir, "echo.proto")},
+		{"api/v1/echo.proto", filepath.Join(protoDir, "api", "v1", "echo.proto")},
+		{"api/../echo.proto", filepath.Join(protoDir, "echo.proto")},
+		{"./echo.proto", filepath.Join(protoDir, "echo.proto")},
+		{"..proto", filepath.Join(protoDir, "..proto")},
+		{"", ""},
+		{"..", ""},
+		{"../echo.proto", ""},
+		{"api/../../echo.proto", ""},
+		{"/etc/echo.proto", ""},
+	}
+	for _, tt := range tests {
+		got, err := protoPath(protoDir, tt.name)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("protoPath(%q) = %q, expected an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("protoPath(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestSaveRefusesEscapingProto(t *testing.T) {
+	dir := t.TempDir()
+	result := &Result{Name: "api", ProtoFiles: map[string]string{"../../escaped.proto": "syntax = \"proto3\";"}}
+	if _, err := result.Save(dir, "", nil, nil, true); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escaped.proto")); !os.IsNotExist(err) {
+		t.Errorf("the proto was written outside the output directory: %v", err)
+	}
+}
+
+func TestSaveRefusesToOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	serverPath := filepath.Join(dir, "api.gtserver")
+	if err := os.WriteFile(serverPath, []byte("Hostname:existing\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	protoDir := filepath.Join(dir, "protos")
+	if err := os.MkdirAll(protoDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(protoDir, "same.proto"), []byte("same"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(protoDir, "changed.proto"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := &Result{
+		Name:       "api",
+		ProtoFiles: map[string]string{"same.proto": "same", "changed.proto": "new"},
+		Targets: []*Target{{
+			Server:   &config.Server{Hostname: "new"},
+			Requests: []*config.SavedRequest{{Name: "call", Service: "demo.Echo", Method: "Say"}},
+		}},
+	}
+	load := func(string, []string) (*protoregistry.Files, error) {
+		return nil, errors.New("not loaded")
+	}
+	_, err := result.Save(dir, "/protos/echo.proto", nil, load, false)
+	var existsErr *ExistsError
+	if !errors.As(err, &existsErr) {
+		t.Fatalf("expected an ExistsError, got %v", err)
+	}
+	want := []string{filepath.Join(protoDir, "changed.proto"), serverPath}
+	if len(existsErr.Paths) != len(want) || existsErr.Paths[0] != want[0] || existsErr.Paths[1] != want[1] {
+		t.Errorf("existing files %q, want %q", existsErr.Paths, want)
+	}
+	if contents, _ := os.ReadFile(filepath.Join(protoDir, "changed.proto")); string(contents) != "old" {
+		t.Errorf("the proto was replaced without overwrite: %q", contents)
+	}
+
+	if _, err = result.Save(dir, "/protos/echo.proto", nil, load, true); err != nil {
+		t.Fatal(err)
+	}
+	if contents, _ := os.ReadFile(filepath.Join(protoDir, "changed.proto")); string(contents) != "new" {
+		t.Errorf("the proto was not replaced with overwrite: %q", contents)
+	}
+}
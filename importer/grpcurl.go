@@ -0,0 +1,218 @@
+package importer
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"grpc_ui_tool/proto"
+	"grpc_ui_tool/shell"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// GrpcurlCommand is a request described by a grpcurl command line
+type GrpcurlCommand struct {
+	Hostname    string
+	Port        string
+	TLS         proto.TLSConfig
+	Tuning      proto.TuningConfig
+	Metadata    metadata.MD
+	Body        string
+	ImportPaths []string
+	ProtoFiles  []string
+	Protosets   []string
+	Service     string
+	Method      string
+	// Ignored lists the flags that have no equivalent in the tool
+	Ignored []string
+}
+
+// grpcurl flags that take a value but are not imported
+var ignoredValueFlags = map[string]bool{
+	"cert": true, "key": true, "servername": true, "connect-timeout": true, "max-time": true,
+	"format": true, "reflect-header": true,
+	"protoset-out": true, "proto-out-dir": true,
+}
+
+// ParseGrpcurl parses a grpcurl command as it would be typed in a shell, including quoting and line continuations
+func ParseGrpcurl(command string) (*GrpcurlCommand, error) {
+	args, err := shell.Split(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 0 && (args[0] == "grpcurl" || strings.HasSuffix(args[0], "/grpcurl")) {
+		args = args[1:]
+	}
+
+	cmd := &GrpcurlCommand{Metadata: metadata.MD{}, Body: "{}"}
+	var positional []string
+	plaintext, insecure, unix := false, false, false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			positional = append(positional, arg)
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		takeValue := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("flag -%s needs a value", name)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch name {
+		case "unix":
+			unix = !hasValue || value == "true"
+		case "plaintext", "insecure":
+			enabled := !hasValue || value == "true"
+			if name == "plaintext" {
+				plaintext = enabled
+			} else {
+				insecure = enabled
+			}
+		case "H", "rpc-header":
+			header, err := takeValue()
+			if err != nil {
+				return nil, err
+			}
+			key, val, found := strings.Cut(header, ":")
+			if !found {
+				return nil, fmt.Errorf("invalid header %q, expected 'key: value'", header)
+			}
+			key = proto.MetadataKey(strings.TrimSpace(key))
+			cmd.Metadata[key] = append(cmd.Metadata[key], strings.TrimSpace(val))
+		case "d":
+			if cmd.Body, err = takeValue(); err != nil {
+				return nil, err
+			}
+			if cmd.Body == "@" {
+				return nil, fmt.Errorf("-d @ reads the request from standard input, paste the request body instead")
+			}
+		case "import-path":
+			path, err := takeValue()
+			if err != nil {
+				return nil, err
+			}
+			cmd.ImportPaths = append(cmd.ImportPaths, path)
+		case "proto":
+			file, err := takeValue()
+			if err != nil {
+				return nil, err
+			}
+			cmd.ProtoFiles = append(cmd.ProtoFiles, file)
+		case "protoset":
+			file, err := takeValue()
+			if err != nil {
+				return nil, err
+			}
+			cmd.Protosets = append(cmd.Protosets, file)
+		case "authority":
+			if cmd.Tuning.Authority, err = takeValue(); err != nil {
+				return nil, err
+			}
+		case "user-agent":
+			if cmd.Tuning.UserAgent, err = takeValue(); err != nil {
+				return nil, err
+			}
+		case "max-msg-sz":
+			size, err := takeValue()
+			if err != nil {
+				return nil, err
+			}
+			if cmd.Tuning.MaxReceiveSize, err = strconv.Atoi(size); err != nil {
+				return nil, fmt.Errorf("invalid -max-msg-sz %q", size)
+			}
+		case "keepalive-time":
+			seconds, err := takeValue()
+			if err != nil {
+				return nil, err
+			}
+			value, err := strconv.ParseFloat(seconds, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid -keepalive-time %q, expected seconds", seconds)
+			}
+			cmd.Tuning.KeepaliveTime = time.Duration(value * float64(time.Second))
+		case "cacert":
+			if cmd.TLS.CACert, err = takeValue(); err != nil {
+				return nil, err
+			}
+		default:
+			if ignoredValueFlags[name] && !hasValue {
+				if _, err = takeValue(); err != nil {
+					return nil, err
+				}
+			}
+			cmd.Ignored = append(cmd.Ignored, "-"+name)
+		}
+	}
+
+	switch {
+	case plaintext:
+		cmd.TLS = proto.TLSConfig{Mode: proto.TLSPlaintext}
+	case insecure:
+		cmd.TLS = proto.TLSConfig{Mode: proto.TLSInsecure}
+	default:
+		cmd.TLS.Mode = proto.TLSVerify
+	}
+
+	for _, arg := range positional {
+		if arg == "list" || arg == "describe" {
+			return nil, fmt.Errorf("only grpcurl commands that invoke a method can be imported")
+		}
+	}
+	if len(positional) != 2 {
+		return nil, fmt.Errorf("expected an address and a method, found %q", positional)
+	}
+	if unix {
+		// the address is a socket path, kept as a unix target in the hostname
+		cmd.Hostname = "unix:" + positional[0]
+	} else if cmd.Hostname, cmd.Port, err = net.SplitHostPort(positional[0]); err != nil {
+		return nil, fmt.Errorf("address %q: %w", positional[0], err)
+	}
+
+	method := strings.TrimPrefix(positional[1], "/")
+	if service, name, found := strings.Cut(method, "/"); found {
+		cmd.Service, cmd.Method = service, name
+	} else if i := strings.LastIndex(method, "."); i > 0 {
+		cmd.Service, cmd.Method = method[:i], method[i+1:]
+	} else {
+		return nil, fmt.Errorf("invalid method %q, expected package.Service/Method", positional[1])
+	}
+
+	if len(cmd.ProtoFiles) == 0 && len(cmd.Protosets) == 0 {
+		return nil, fmt.Errorf("server reflection is not supported, the command needs -proto or -protoset")
+	}
+	return cmd, nil
+}
+
+// ResolvePaths makes the file paths of the command absolute, relative paths are taken from dir as they would be
+// from the directory grpcurl was run in. Proto files are left relative to the import paths when there are any
+func (cmd *GrpcurlCommand) ResolvePaths(dir string) {
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+	for i := range cmd.ImportPaths {
+		cmd.ImportPaths[i] = resolve(cmd.ImportPaths[i])
+	}
+	if len(cmd.ImportPaths) == 0 {
+		for i := range cmd.ProtoFiles {
+			cmd.ProtoFiles[i] = resolve(cmd.ProtoFiles[i])
+		}
+	}
+	for i := range cmd.Protosets {
+		cmd.Protosets[i] = resolve(cmd.Protosets[i])
+	}
+	cmd.TLS.CACert = resolve(cmd.TLS.CACert)
+}
@@ -0,0 +1,164 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	"grpc_ui_tool/config"
+	"grpc_ui_tool/proto"
+
+	"google.golang.org/grpc/metadata"
+)
+
+type insomniaExport struct {
+	Type      string              `json:"_type"`
+	Format    int                 `json:"__export_format"`
+	Resources []*insomniaResource `json:"resources"`
+}
+
+type insomniaResource struct {
+	ID              string         `json:"_id"`
+	ParentID        string         `json:"parentId"`
+	Type            string         `json:"_type"`
+	Name            string         `json:"name"`
+	URL             string         `json:"url"`
+	ProtoMethodName string         `json:"protoMethodName"`
+	ProtoFileID     string         `json:"protoFileId"`
+	ProtoText       string         `json:"protoText"`
+	Data            map[string]any `json:"data"`
+	Body            struct {
+		Text string `json:"text"`
+	} `json:"body"`
+	Metadata []struct {
+		Name     string `json:"name"`
+		Value    string `json:"value"`
+		Disabled bool   `json:"disabled"`
+	} `json:"metadata"`
+}
+
+// ParseInsomnia reads an Insomnia v4 JSON export, embedded proto files are kept so they can be written next to the
+// collection and the base environment becomes the variables
+func ParseInsomnia(contents []byte) (*Result, error) {
+	var export insomniaExport
+	if err := json.Unmarshal(contents, &export); err != nil {
+		return nil, fmt.Errorf("not an Insomnia export: %w", err)
+	}
+	if export.Type != "export" || export.Format != 4 {
+		return nil, fmt.Errorf("not an Insomnia v4 JSON export")
+	}
+
+	byID := map[string]*insomniaResource{}
+	for _, res := range export.Resources {
+		byID[res.ID] = res
+	}
+	result := &Result{Variables: map[string]string{}, ProtoFiles: map[string]string{}}
+	for _, res := range export.Resources {
+		switch res.Type {
+		case "workspace":
+			if result.Name == "" {
+				result.Name = res.Name
+			}
+		case "environment":
+			// the base environment belongs to the workspace, sub environments belong to it
+			if parent := byID[res.ParentID]; parent != nil && parent.Type == "workspace" {
+				flattenVariables("", res.Data, result.Variables)
+			}
+		case "proto_file":
+			result.ProtoFiles[insomniaProtoPath(res, byID)] = res.ProtoText
+		}
+	}
+
+	for _, res := range export.Resources {
+		switch res.Type {
+		case "grpc_request":
+			result.addInsomniaRequest(insomniaName(res, byID), res, byID)
+		case "request", "websocket_request":
+			result.skip(insomniaName(res, byID), "not a gRPC request")
+		}
+	}
+	return result, nil
+}
+
+func (r *Result) addInsomniaRequest(name string, res *insomniaResource, byID map[string]*insomniaResource) {
+	service, method, ok := splitMethodPath(res.ProtoMethodName)
+	if !ok {
+		r.skip(name, "no method selected")
+		return
+	}
+	protoPath := ""
+	if protoFile := byID[res.ProtoFileID]; protoFile != nil && protoFile.Type == "proto_file" {
+		protoPath = insomniaProtoPath(protoFile, byID)
+	} else {
+		r.skip(name, "its proto file is missing from the export, the collection uses the proto chosen for the import")
+	}
+
+	unsupported := func(tag string) {
+		r.skip(name, "template tag %s has no equivalent and was left as is", tag)
+	}
+	t, err := r.target(convertInsomnia(res.URL, unsupported), protoPath)
+	if err != nil {
+		r.skip(name, "%v", err)
+		return
+	}
+
+	body := res.Body.Text
+	if strings.TrimSpace(body) == "" {
+		body = "{}"
+	}
+	saved := &config.SavedRequest{
+		Name:     name,
+		Service:  service,
+		Method:   method,
+		Body:     convertInsomnia(body, unsupported),
+		Metadata: metadata.MD{},
+	}
+	for _, pair := range res.Metadata {
+		if pair.Disabled || pair.Name == "" {
+			continue
+		}
+		key := proto.MetadataKey(convertInsomnia(pair.Name, unsupported))
+		saved.Metadata[key] = append(saved.Metadata[key], convertInsomnia(pair.Value, unsupported))
+	}
+	t.Requests = append(t.Requests, saved)
+}
+
+// insomniaName prefixes a request with the folders it is in
+func insomniaName(res *insomniaResource, byID map[string]*insomniaResource) string {
+	name := res.Name
+	for parent := byID[res.ParentID]; parent != nil && parent.Type == "request_group"; parent = byID[parent.ParentID] {
+		name = parent.Name + "/" + name
+	}
+	return name
+}
+
+// insomniaProtoPath is the path of a proto file within the proto directories it was imported with
+func insomniaProtoPath(res *insomniaResource, byID map[string]*insomniaResource) string {
+	parts := []string{res.Name}
+	for parent := byID[res.ParentID]; parent != nil && parent.Type == "proto_directory"; parent = byID[parent.ParentID] {
+		parts = append([]string{parent.Name}, parts...)
+	}
+	// the top directory is the one chosen in Insomnia, imports are relative to it
+	if len(parts) > 1 {
+		parts = parts[1:]
+	}
+	return path.Join(parts...)
+}
+
+// flattenVariables turns nested environment data into variables named by their path
+func flattenVariables(prefix string, data map[string]any, variables map[string]string) {
+	for _, key := range slices.Sorted(maps.Keys(data)) {
+		switch value := data[key].(type) {
+		case map[string]any:
+			flattenVariables(prefix+key+".", value, variables)
+		case string:
+			variables[prefix+key] = value
+		default:
+			encoded, _ := json.Marshal(value)
+			variables[prefix+key] = string(encoded)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"grpc_ui_tool/config"
+	"grpc_ui_tool/proto"
+
+	"google.golang.org/grpc/metadata"
+)
+
+type postmanCollection struct {
+	Info struct {
+		Name string `json:"name"`
+	} `json:"info"`
+	Item     []*postmanItem    `json:"item"`
+	Variable []postmanKeyValue `json:"variable"`
+}
+
+type postmanItem struct {
+	Name    string          `json:"name"`
+	Item    []*postmanItem  `json:"item"`
+	Request *postmanRequest `json:"request"`
+}
+
+type postmanRequest struct {
+	URL        json.RawMessage   `json:"url"`
+	MethodPath string            `json:"methodPath"`
+	Method     string            `json:"method"`
+	Message    json.RawMessage   `json:"message"`
+	Metadata   []postmanKeyValue `json:"metadata"`
+	Header     []postmanKeyValue `json:"header"`
+	Body       struct {
+		Raw string `json:"raw"`
+	} `json:"body"`
+}
+
+type postmanKeyValue struct {
+	Key      string `json:"key"`
+	Value    any    `json:"value"`
+	Disabled bool   `json:"disabled"`
+}
+
+// ParsePostman reads a Postman collection export, folders are flattened in order and HTTP requests are skipped
+func ParsePostman(contents []byte) (*Result, error) {
+	var collection postmanCollection
+	if err := json.Unmarshal(contents, &collection); err != nil {
+		return nil, fmt.Errorf("not a Postman collection: %w", err)
+	}
+	if collection.Info.Name == "" && len(collection.Item) == 0 {
+		return nil, fmt.Errorf("not a Postman collection, it has no info or items")
+	}
+
+	result := &Result{Name: collection.Info.Name, Variables: map[string]string{}}
+	for _, variable := range collection.Variable {
+		if !variable.Disabled && variable.Key != "" {
+			result.Variables[variable.Key] = fmt.Sprint(variable.Value)
+		}
+	}
+	var walk func(prefix string, items []*postmanItem)
+	walk = func(prefix string, items []*postmanItem) {
+		for _, item := range items {
+			name := prefix + item.Name
+			if item.Request == nil {
+				walk(name+"/", item.Item)
+				continue
+			}
+			result.addPostmanRequest(name, item.Request)
+		}
+	}
+	walk("", collection.Item)
+	return result, nil
+}
+
+func (r *Result) addPostmanRequest(name string, req *postmanRequest) {
+	methodPath := req.MethodPath
+	if methodPath == "" && strings.Contains(req.Method, "/") {
+		methodPath = req.Method
+	}
+	if methodPath == "" {
+		r.skip(name, "not a gRPC request")
+		return
+	}
+	service, method, ok := splitMethodPath(methodPath)
+	if !ok {
+		r.skip(name, "invalid method %q", methodPath)
+		return
+	}
+
+	address := rawText(req.URL, "raw")
+	if address == "" {
+		r.skip(name, "no server address")
+		return
+	}
+	unsupported := func(variable string) {
+		r.skip(name, "dynamic variable %s has no equivalent and was left as is", variable)
+	}
+	t, err := r.target(convertPostman(address, unsupported), "")
+	if err != nil {
+		r.skip(name, "%v", err)
+		return
+	}
+
+	body := rawText(req.Message, "content")
+	if body == "" {
+		body = req.Body.Raw
+	}
+	if strings.TrimSpace(body) == "" {
+		body = "{}"
+	}
+	saved := &config.SavedRequest{
+		Name:     name,
+		Service:  service,
+		Method:   method,
+		Body:     convertPostman(body, unsupported),
+		Metadata: metadata.MD{},
+	}
+	pairs := req.Metadata
+	if len(pairs) == 0 {
+		pairs = req.Header
+	}
+	for _, pair := range pairs {
+		if pair.Disabled || pair.Key == "" {
+			continue
+		}
+		key := proto.MetadataKey(convertPostman(pair.Key, unsupported))
+		saved.Metadata[key] = append(saved.Metadata[key], convertPostman(fmt.Sprint(pair.Value), unsupported))
+	}
+	t.Requests = append(t.Requests, saved)
+}
+
+// rawText reads a value exported either as a plain string, a JSON document or an object holding the text in field
+func rawText(raw json.RawMessage, field string) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err == nil {
+		if inner, ok := object[field]; ok {
+			return rawText(inner, field)
+		}
+	}
+	return string(raw)
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"os"
+
+	"grpc_ui_tool/cli"
 	"grpc_ui_tool/proto"
 	"grpc_ui_tool/ui"
 )
@@ -9,6 +12,9 @@ var grpcConn *proto.GrpcConnection
 
 func main() {
 	grpcConn = proto.NewGrpcConnection()
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(grpcConn, os.Args[1:]))
+	}
 	_ = ui.CreateUI(grpcConn)
 
 }
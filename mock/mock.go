@@ -0,0 +1,369 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"grpc_ui_tool/assert"
+	"grpc_ui_tool/config"
+	"grpc_ui_tool/expand"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Options configures how the mock server listens
+type Options struct {
+	Address    string
+	Reflection bool
+	Plaintext  bool
+}
+
+// Call is a request handled by the mock server
+type Call struct {
+	Time     time.Time
+	Method   string
+	Metadata metadata.MD
+	Request  string
+	Response string
+	Err      error
+}
+
+// Server implements every service in a file registry, replying to each method with its configured response or an
+// empty message when it has none
+type Server struct {
+	files  *protoregistry.Files
+	onCall func(Call)
+
+	mu        sync.Mutex
+	mocks     *config.Mocks
+	server    *grpc.Server
+	listener  net.Listener
+	variables map[string]string
+}
+
+// New returns a stopped mock server for the services in files, onCall is told about every request and may be nil
+func New(files *protoregistry.Files, mocks *config.Mocks, onCall func(Call)) *Server {
+	if mocks == nil {
+		mocks = &config.Mocks{}
+	}
+	return &Server{files: files, mocks: mocks, onCall: onCall}
+}
+
+// SetMocks replaces the canned responses, it takes effect for the next request
+func (s *Server) SetMocks(mocks *config.Mocks) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mocks = mocks
+}
+
+// SetVariables sets extra variables available to response templates alongside the request fields
+func (s *Server) SetVariables(variables map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.variables = variables
+}
+
+// Start listens on opts.Address and serves in the background, it returns the address actually listened on.
+// Unless Plaintext is set the server uses a self signed certificate, which the tool's own client accepts
+func (s *Server) Start(opts Options) (net.Addr, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.server != nil {
+		return nil, fmt.Errorf("mock server is already running on %s", s.listener.Addr())
+	}
+
+	serverOpts := []grpc.ServerOption{grpc.UnknownServiceHandler(s.handle)}
+	if !opts.Plaintext {
+		cert, err := selfSignedCertificate()
+		if err != nil {
+			return nil, err
+		}
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewServerTLSFromCert(&cert)))
+	}
+	listener, err := net.Listen("tcp", opts.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	server := grpc.NewServer(serverOpts...)
+	if opts.Reflection {
+		reflectionOpts := reflection.ServerOptions{
+			Services:           serviceInfo{s.files},
+			DescriptorResolver: s.files,
+			ExtensionResolver:  extensionTypes(s.files),
+		}
+		reflectionv1.RegisterServerReflectionServer(server, reflection.NewServerV1(reflectionOpts))
+		reflectionv1alpha.RegisterServerReflectionServer(server, reflection.NewServer(reflectionOpts))
+	}
+	s.server = server
+	s.listener = listener
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	return listener.Addr(), nil
+}
+
+// Stop closes the listener and ends any calls in progress
+func (s *Server) Stop() {
+	s.mu.Lock()
+	server := s.server
+	s.server = nil
+	s.listener = nil
+	s.mu.Unlock()
+	if server != nil {
+		server.Stop()
+	}
+}
+
+// Running reports the address the server is listening on, or nil when it is stopped
+func (s *Server) Running() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+func (s *Server) handle(_ any, stream grpc.ServerStream) error {
+	fullMethod, _ := grpc.MethodFromServerStream(stream)
+	method := strings.TrimPrefix(fullMethod, "/")
+	methodDesc, err := s.findMethod(method)
+	if err != nil {
+		return status.Error(codes.Unimplemented, err.Error())
+	}
+	md, _ := metadata.FromIncomingContext(stream.Context())
+
+	// bidirectional streams reply to each message as it arrives, other methods read the whole request first
+	if methodDesc.IsStreamingClient() && methodDesc.IsStreamingServer() {
+		for {
+			request := dynamicpb.NewMessage(methodDesc.Input())
+			if err = stream.RecvMsg(request); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if err = s.reply(stream, method, methodDesc, md, request); err != nil {
+				return err
+			}
+		}
+	}
+
+	request := dynamicpb.NewMessage(methodDesc.Input())
+	for {
+		message := dynamicpb.NewMessage(methodDesc.Input())
+		if err = stream.RecvMsg(message); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		request = message
+		if !methodDesc.IsStreamingClient() {
+			break
+		}
+	}
+	return s.reply(stream, method, methodDesc, md, request)
+}
+
+func (s *Server) findMethod(method string) (protoreflect.MethodDescriptor, error) {
+	service, name, found := strings.Cut(method, "/")
+	if !found {
+		return nil, fmt.Errorf("invalid method %q", method)
+	}
+	desc, err := s.files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("unknown service %s", service)
+	}
+	serviceDesc, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", service)
+	}
+	methodDesc := serviceDesc.Methods().ByName(protoreflect.Name(name))
+	if methodDesc == nil {
+		return nil, fmt.Errorf("unknown method %s in service %s", name, service)
+	}
+	return methodDesc, nil
+}
+
+// reply sends the configured response for one request and reports the call
+func (s *Server) reply(stream grpc.ServerStream, method string, methodDesc protoreflect.MethodDescriptor,
+	md metadata.MD, request *dynamicpb.Message) error {
+	s.mu.Lock()
+	resp := s.mocks.Get(method)
+	variables := s.variables
+	s.mu.Unlock()
+
+	requestJson, _ := protojson.Marshal(request)
+	call := Call{Time: time.Now(), Method: method, Metadata: md, Request: string(requestJson)}
+	call.Response, call.Err = s.respond(stream.Context(), stream, methodDesc, resp, requestJson, variables)
+	if s.onCall != nil {
+		s.onCall(call)
+	}
+	return call.Err
+}
+
+func (s *Server) respond(ctx context.Context, stream grpc.ServerStream, methodDesc protoreflect.MethodDescriptor,
+	resp *config.MockResponse, requestJson []byte, variables map[string]string) (string, error) {
+	if resp == nil {
+		resp = &config.MockResponse{}
+	}
+
+	delay, err := resp.DelayDuration()
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "mock delay: %v", err)
+	}
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return "", status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	code, err := assert.ParseCode(resp.Code)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "mock code: %v", err)
+	}
+	if code != codes.OK {
+		return "", status.Error(code, resp.Message)
+	}
+
+	body := resp.Body
+	if strings.TrimSpace(body) == "" {
+		body = "{}"
+	}
+	body, err = expand.JSON(body, requestVariables(requestJson, variables))
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "mock response template: %v", err)
+	}
+
+	bodies := []json.RawMessage{json.RawMessage(body)}
+	if methodDesc.IsStreamingServer() && strings.HasPrefix(strings.TrimSpace(body), "[") {
+		bodies = nil
+		if err = json.Unmarshal([]byte(body), &bodies); err != nil {
+			return body, status.Errorf(codes.Internal, "mock response: %v", err)
+		}
+	}
+	for _, b := range bodies {
+		message := dynamicpb.NewMessage(methodDesc.Output())
+		if err = protojson.Unmarshal(b, message); err != nil {
+			return body, status.Errorf(codes.Internal, "mock response for %s: %v", methodDesc.Output().FullName(), err)
+		}
+		if err = stream.SendMsg(message); err != nil {
+			return body, err
+		}
+	}
+	return body, nil
+}
+
+// requestVariables flattens the request JSON into variables named by their path such as user.id or items.0.name,
+// the whole request is available as request
+func requestVariables(requestJson []byte, variables map[string]string) map[string]string {
+	vars := make(map[string]string, len(variables)+1)
+	for key, value := range variables {
+		vars[key] = value
+	}
+	vars["request"] = string(requestJson)
+
+	var document any
+	if err := json.Unmarshal(requestJson, &document); err != nil {
+		return vars
+	}
+	var flatten func(prefix string, value any)
+	flatten = func(prefix string, value any) {
+		switch v := value.(type) {
+		case string:
+			vars[prefix] = v
+			return
+		case map[string]any:
+			for key, child := range v {
+				flatten(join(prefix, key), child)
+			}
+		case []any:
+			for i, child := range v {
+				flatten(join(prefix, strconv.Itoa(i)), child)
+			}
+		}
+		if prefix != "" {
+			encoded, _ := json.Marshal(value)
+			vars[prefix] = string(encoded)
+		}
+	}
+	flatten("", document)
+	return vars
+}
+
+func join(prefix string, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// serviceInfo lists the registry's services for the reflection service
+type serviceInfo struct {
+	files *protoregistry.Files
+}
+
+func (si serviceInfo) GetServiceInfo() map[string]grpc.ServiceInfo {
+	services := map[string]grpc.ServiceInfo{}
+	si.files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		for i := 0; i < fd.Services().Len(); i++ {
+			sd := fd.Services().Get(i)
+			var methods []grpc.MethodInfo
+			for j := 0; j < sd.Methods().Len(); j++ {
+				m := sd.Methods().Get(j)
+				methods = append(methods, grpc.MethodInfo{
+					Name:           string(m.Name()),
+					IsClientStream: m.IsStreamingClient(),
+					IsServerStream: m.IsStreamingServer(),
+				})
+			}
+			services[string(sd.FullName())] = grpc.ServiceInfo{Methods: methods, Metadata: fd.Path()}
+		}
+		return true
+	})
+	return services
+}
+
+// extensionTypes collects the extensions declared in the registry for the reflection service
+func extensionTypes(files *protoregistry.Files) *protoregistry.Types {
+	types := &protoregistry.Types{}
+	var register func(extensions protoreflect.ExtensionDescriptors)
+	register = func(extensions protoreflect.ExtensionDescriptors) {
+		for i := 0; i < extensions.Len(); i++ {
+			_ = types.RegisterExtension(dynamicpb.NewExtensionType(extensions.Get(i)))
+		}
+	}
+	var walk func(messages protoreflect.MessageDescriptors)
+	walk = func(messages protoreflect.MessageDescriptors) {
+		for i := 0; i < messages.Len(); i++ {
+			register(messages.Get(i).Extensions())
+			walk(messages.Get(i).Messages())
+		}
+	}
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		register(fd.Extensions())
+		walk(fd.Messages())
+		return true
+	})
+	return types
+}
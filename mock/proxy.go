@@ -0,0 +1,256 @@
+package mock
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"grpc_ui_tool/config"
+	grpcproto "grpc_ui_tool/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Exchange is one call passing through the proxy, requests and responses are JSON when the method is in the
+// registry and base64 encoded otherwise. Metadata holds what the client sent apart from the headers grpc sets itself,
+// with -bin values base64 encoded as in a saved request
+type Exchange struct {
+	ID        int
+	Time      time.Time
+	Method    string
+	Metadata  metadata.MD
+	Requests  []string
+	Responses []string
+	Headers   metadata.MD
+	Trailers  metadata.MD
+	Err       error
+	Duration  time.Duration
+	Done      bool
+}
+
+// Proxy forwards every call it receives to an upstream server unchanged, decoding the messages as they pass
+type Proxy struct {
+	files    *protoregistry.Files
+	upstream *grpc.ClientConn
+	onChange func(Exchange)
+
+	mu       sync.Mutex
+	server   *grpc.Server
+	listener net.Listener
+	nextID   int
+}
+
+// rawCodec passes messages through as bytes so they are forwarded exactly as received
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	return *(v.(*[]byte)), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	*(v.(*[]byte)) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+// NewProxy returns a stopped proxy to the upstream connection, onChange receives a copy of an exchange whenever a
+// message passes or the call ends
+func NewProxy(files *protoregistry.Files, upstream *grpc.ClientConn, onChange func(Exchange)) *Proxy {
+	return &Proxy{files: files, upstream: upstream, onChange: onChange}
+}
+
+// Start listens on opts.Address and serves in the background, reflection is not offered by the proxy
+func (p *Proxy) Start(opts Options) (net.Addr, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.server != nil {
+		return nil, fmt.Errorf("proxy is already running on %s", p.listener.Addr())
+	}
+
+	serverOpts := []grpc.ServerOption{grpc.UnknownServiceHandler(p.handle), grpc.ForceServerCodec(rawCodec{})}
+	if !opts.Plaintext {
+		cert, err := selfSignedCertificate()
+		if err != nil {
+			return nil, err
+		}
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewServerTLSFromCert(&cert)))
+	}
+	listener, err := net.Listen("tcp", opts.Address)
+	if err != nil {
+		return nil, err
+	}
+	p.server = grpc.NewServer(serverOpts...)
+	p.listener = listener
+	go func(server *grpc.Server) {
+		_ = server.Serve(listener)
+	}(p.server)
+	return listener.Addr(), nil
+}
+
+// Stop closes the listener and the upstream connection, a stopped proxy cannot be started again
+func (p *Proxy) Stop() {
+	p.mu.Lock()
+	server := p.server
+	p.server = nil
+	p.listener = nil
+	p.mu.Unlock()
+	if server != nil {
+		server.Stop()
+	}
+	_ = p.upstream.Close()
+}
+
+// Running reports the address the proxy is listening on, or nil when it is stopped
+func (p *Proxy) Running() net.Addr {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.listener == nil {
+		return nil
+	}
+	return p.listener.Addr()
+}
+
+func (p *Proxy) handle(_ any, stream grpc.ServerStream) error {
+	fullMethod, _ := grpc.MethodFromServerStream(stream)
+	md, _ := metadata.FromIncomingContext(stream.Context())
+
+	p.mu.Lock()
+	p.nextID++
+	exchange := &Exchange{ID: p.nextID, Time: time.Now(), Method: strings.TrimPrefix(fullMethod, "/"),
+		Metadata: grpcproto.DisplayMetadata(forwardedMetadata(md))}
+	p.mu.Unlock()
+	var exchangeMu sync.Mutex
+	update := func(change func(ex *Exchange)) {
+		exchangeMu.Lock()
+		change(exchange)
+		snapshot := *exchange
+		snapshot.Requests = append([]string(nil), exchange.Requests...)
+		snapshot.Responses = append([]string(nil), exchange.Responses...)
+		exchangeMu.Unlock()
+		if p.onChange != nil {
+			p.onChange(snapshot)
+		}
+	}
+	update(func(*Exchange) {})
+
+	var methodDesc protoreflect.MethodDescriptor
+	if service, name, found := strings.Cut(exchange.Method, "/"); found {
+		if desc, err := p.files.FindDescriptorByName(protoreflect.FullName(service + "." + name)); err == nil {
+			methodDesc, _ = desc.(protoreflect.MethodDescriptor)
+		}
+	}
+
+	ctx := metadata.NewOutgoingContext(stream.Context(), forwardedMetadata(md))
+	upstream, err := p.upstream.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, fullMethod,
+		grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		update(func(ex *Exchange) { ex.Err, ex.Done, ex.Duration = err, true, time.Since(ex.Time) })
+		return err
+	}
+
+	go func() {
+		for {
+			var message []byte
+			if err := stream.RecvMsg(&message); err != nil {
+				_ = upstream.CloseSend()
+				return
+			}
+			update(func(ex *Exchange) { ex.Requests = append(ex.Requests, decodeMessage(methodDesc, true, message)) })
+			if err := upstream.SendMsg(&message); err != nil {
+				return
+			}
+		}
+	}()
+
+	if header, err := upstream.Header(); err == nil {
+		header = forwardedMetadata(header)
+		update(func(ex *Exchange) { ex.Headers = grpcproto.DisplayMetadata(header) })
+		_ = stream.SendHeader(header)
+	}
+	for {
+		var message []byte
+		if err = upstream.RecvMsg(&message); err != nil {
+			break
+		}
+		update(func(ex *Exchange) { ex.Responses = append(ex.Responses, decodeMessage(methodDesc, false, message)) })
+		if err = stream.SendMsg(&message); err != nil {
+			break
+		}
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	trailer := upstream.Trailer()
+	stream.SetTrailer(trailer)
+	update(func(ex *Exchange) {
+		ex.Trailers, ex.Err, ex.Done, ex.Duration = grpcproto.DisplayMetadata(trailer), err, true, time.Since(ex.Time)
+	})
+	if err != nil {
+		return status.Convert(err).Err()
+	}
+	return nil
+}
+
+// forwardedMetadata drops the headers grpc sets itself on each side of the proxy
+func forwardedMetadata(md metadata.MD) metadata.MD {
+	forwarded := metadata.MD{}
+	for key, values := range md {
+		switch {
+		case strings.HasPrefix(key, ":"), key == "content-type", key == "user-agent", key == "te":
+		case strings.HasPrefix(key, "grpc-") && key != "grpc-trace-bin":
+		default:
+			forwarded[key] = values
+		}
+	}
+	return forwarded
+}
+
+func decodeMessage(methodDesc protoreflect.MethodDescriptor, request bool, data []byte) string {
+	if methodDesc != nil {
+		desc := methodDesc.Output()
+		if request {
+			desc = methodDesc.Input()
+		}
+		message := dynamicpb.NewMessage(desc)
+		if err := proto.Unmarshal(data, message); err == nil {
+			if decoded, err := (protojson.MarshalOptions{Multiline: true, Indent: "  "}).Marshal(message); err == nil {
+				return string(decoded)
+			}
+		}
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// Fixture returns a mock response reproducing the exchange, several responses become a JSON array
+func Fixture(exchange Exchange) *config.MockResponse {
+	fixture := &config.MockResponse{Method: exchange.Method}
+	if exchange.Err != nil {
+		st := status.Convert(exchange.Err)
+		fixture.Code = st.Code().String()
+		fixture.Message = st.Message()
+		return fixture
+	}
+	switch len(exchange.Responses) {
+	case 0:
+	case 1:
+		fixture.Body = exchange.Responses[0]
+	default:
+		fixture.Body = "[\n" + strings.Join(exchange.Responses, ",\n") + "\n]"
+	}
+	return fixture
+}
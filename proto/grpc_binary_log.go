@@ -0,0 +1,382 @@
+package proto
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	binlogpb "google.golang.org/grpc/binarylog/grpc_binarylog_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// BinaryLog writes the calls made on connections it is set on as grpc.binarylog.v1.GrpcLogEntry messages, each
+// prefixed with its length as a 4 byte big endian integer like the file sink of grpc-go
+type BinaryLog struct {
+	mu        sync.Mutex
+	w         io.WriteCloser
+	err       error
+	nextID    atomic.Uint64
+	redactors []redactor
+}
+
+// CreateBinaryLog creates or truncates the file at path, readable only by the user, and logs calls to it until
+// closed with the values of sensitive headers hidden by rules. Entries are written as soon as they happen
+func CreateBinaryLog(path string, rules []RedactRule) (*BinaryLog, error) {
+	redactors, err := compileRedactRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	// an existing file keeps its mode when truncated
+	if err = file.Chmod(0600); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &BinaryLog{w: file, redactors: redactors}, nil
+}
+
+// SetBinaryLog sets the binary log that calls on new connections are written to, nil writes nothing
+func (gcd *GrpcConnection) SetBinaryLog(log *BinaryLog) {
+	gcd.BinaryLog = log
+}
+
+// Close stops logging and closes the file, returning the first error writing to it
+func (l *BinaryLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.w == nil {
+		return l.err
+	}
+	if err := l.w.Close(); err != nil && l.err == nil {
+		l.err = err
+	}
+	l.w = nil
+	return l.err
+}
+
+func (l *BinaryLog) write(entry *binlogpb.GrpcLogEntry) {
+	data, err := proto.Marshal(entry)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// after the first failure the log is left as it was rather than written with gaps
+	if l.w == nil || l.err != nil {
+		return
+	}
+	if err == nil {
+		framed := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(data)), uint32(len(data)))
+		_, err = l.w.Write(append(framed, data...))
+	}
+	l.err = err
+}
+
+// ReadBinaryLog parses a binary log file, entries prefixed with a 4 byte big endian length as written by grpc-go
+// and this tool, or with a varint length as written by grpc-java
+func ReadBinaryLog(data []byte) ([]*binlogpb.GrpcLogEntry, error) {
+	entries, err := readBinaryLog(data, func(data []byte) (uint64, int) {
+		if len(data) < 4 {
+			return 0, -1
+		}
+		return uint64(binary.BigEndian.Uint32(data)), 4
+	})
+	if err == nil {
+		return entries, nil
+	}
+	if varintEntries, varintErr := readBinaryLog(data, protowire.ConsumeVarint); varintErr == nil {
+		return varintEntries, nil
+	}
+	return nil, err
+}
+
+func readBinaryLog(data []byte, consumeLength func([]byte) (uint64, int)) ([]*binlogpb.GrpcLogEntry, error) {
+	var entries []*binlogpb.GrpcLogEntry
+	for offset := 0; offset < len(data); {
+		length, n := consumeLength(data[offset:])
+		if n < 0 {
+			return nil, fmt.Errorf("entry %d: truncated length at byte %d", len(entries)+1, offset)
+		}
+		offset += n
+		if length > uint64(len(data)-offset) {
+			return nil, fmt.Errorf("entry %d: length %d runs past the end of the file", len(entries)+1, length)
+		}
+		entry := &binlogpb.GrpcLogEntry{}
+		if err := proto.Unmarshal(data[offset:offset+int(length)], entry); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", len(entries)+1, err)
+		}
+		entries = append(entries, entry)
+		offset += int(length)
+	}
+	return entries, nil
+}
+
+// BinaryLogMetadata converts logged metadata back, binary values are kept in the -bin keys as grpc does
+func BinaryLogMetadata(md *binlogpb.Metadata) metadata.MD {
+	converted := metadata.MD{}
+	for _, entry := range md.GetEntry() {
+		converted[entry.GetKey()] = append(converted[entry.GetKey()], string(entry.GetValue()))
+	}
+	return converted
+}
+
+// metadata converts metadata to be logged with sensitive values hidden, leaving out the grpc- headers as the binary
+// log specification requires apart from the trace context
+func (l *BinaryLog) metadata(md metadata.MD) *binlogpb.Metadata {
+	md = redactMetadata(l.redactors, md)
+	logged := &binlogpb.Metadata{}
+	for _, key := range slices.Sorted(maps.Keys(md)) {
+		if strings.HasPrefix(key, "grpc-") && key != "grpc-trace-bin" {
+			continue
+		}
+		for _, value := range md[key] {
+			logged.Entry = append(logged.Entry, &binlogpb.MetadataEntry{Key: key, Value: []byte(value)})
+		}
+	}
+	return logged
+}
+
+func binaryLogAddress(addr net.Addr) *binlogpb.Address {
+	if addr == nil {
+		return nil
+	}
+	if addr.Network() == "unix" {
+		return &binlogpb.Address{Type: binlogpb.Address_TYPE_UNIX, Address: addr.String()}
+	}
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return &binlogpb.Address{Type: binlogpb.Address_TYPE_UNKNOWN, Address: addr.String()}
+	}
+	ip := net.ParseIP(host)
+	address := &binlogpb.Address{Type: binlogpb.Address_TYPE_UNKNOWN, Address: host}
+	switch {
+	case ip == nil:
+	case ip.To4() != nil:
+		address.Type = binlogpb.Address_TYPE_IPV4
+	default:
+		address.Type = binlogpb.Address_TYPE_IPV6
+	}
+	if portNumber, err := strconv.ParseUint(port, 10, 32); err == nil {
+		address.IpPort = uint32(portNumber)
+	}
+	return address
+}
+
+// binaryLogCall writes the entries of one call in order, the peer is set on the first entry from the server
+type binaryLogCall struct {
+	log      *BinaryLog
+	id       uint64
+	mu       sync.Mutex
+	sequence uint64
+	peer     net.Addr
+	header   bool
+	ended    bool
+}
+
+func (l *BinaryLog) begin(ctx context.Context, method string, authority string) *binaryLogCall {
+	call := &binaryLogCall{log: l, id: l.nextID.Add(1)}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	clientHeader := &binlogpb.ClientHeader{Metadata: l.metadata(md), MethodName: method, Authority: authority}
+	if deadline, ok := ctx.Deadline(); ok {
+		clientHeader.Timeout = durationpb.New(time.Until(deadline))
+	}
+	call.write(&binlogpb.GrpcLogEntry{Type: binlogpb.GrpcLogEntry_EVENT_TYPE_CLIENT_HEADER,
+		Payload: &binlogpb.GrpcLogEntry_ClientHeader{ClientHeader: clientHeader}})
+	return call
+}
+
+// write fills in the call and sequence of an entry and logs it, nothing is logged after the call ended
+func (c *binaryLogCall) write(entry *binlogpb.GrpcLogEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ended {
+		return
+	}
+	c.sequence++
+	entry.Timestamp = timestamppb.Now()
+	entry.CallId = c.id
+	entry.SequenceIdWithinCall = c.sequence
+	entry.Logger = binlogpb.GrpcLogEntry_LOGGER_CLIENT
+	switch entry.Type {
+	case binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_HEADER, binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_TRAILER:
+		if !c.header {
+			entry.Peer = binaryLogAddress(c.peer)
+		}
+		c.header = true
+	}
+	switch entry.Type {
+	case binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_TRAILER, binlogpb.GrpcLogEntry_EVENT_TYPE_CANCEL:
+		c.ended = true
+	}
+	c.log.write(entry)
+}
+
+func (c *binaryLogCall) setPeer(addr net.Addr) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.peer = addr
+}
+
+func (c *binaryLogCall) message(eventType binlogpb.GrpcLogEntry_EventType, m any) {
+	var data []byte
+	switch message := m.(type) {
+	case *[]byte:
+		data = *message
+	case proto.Message:
+		// the codec marshals the same way, so these are the bytes that went over the wire before compression
+		data, _ = proto.Marshal(message)
+	}
+	c.write(&binlogpb.GrpcLogEntry{Type: eventType,
+		Payload: &binlogpb.GrpcLogEntry_Message{Message: &binlogpb.Message{Length: uint32(len(data)), Data: data}}})
+}
+
+func (c *binaryLogCall) serverHeader(header metadata.MD) {
+	c.mu.Lock()
+	written := c.header
+	c.mu.Unlock()
+	if !written {
+		c.write(&binlogpb.GrpcLogEntry{Type: binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_HEADER,
+			Payload: &binlogpb.GrpcLogEntry_ServerHeader{ServerHeader: &binlogpb.ServerHeader{Metadata: c.log.metadata(header)}}})
+	}
+}
+
+// end writes the trailer, or a cancel entry when the client gave up on the call
+func (c *binaryLogCall) end(trailer metadata.MD, err error) {
+	if errors.Is(err, context.Canceled) || status.Code(err) == codes.Canceled {
+		c.write(&binlogpb.GrpcLogEntry{Type: binlogpb.GrpcLogEntry_EVENT_TYPE_CANCEL})
+		return
+	}
+	st := status.Convert(err)
+	logged := &binlogpb.Trailer{Metadata: c.log.metadata(trailer), StatusCode: uint32(st.Code()), StatusMessage: st.Message()}
+	if len(st.Details()) > 0 {
+		logged.StatusDetails, _ = proto.Marshal(st.Proto())
+	}
+	c.write(&binlogpb.GrpcLogEntry{Type: binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_TRAILER,
+		Payload: &binlogpb.GrpcLogEntry_Trailer{Trailer: logged}})
+}
+
+// binaryLogInterceptor logs calls to the binary log, the authority is the one the connection sends
+type binaryLogInterceptor struct {
+	log       *BinaryLog
+	authority string
+}
+
+// interceptors returns the interceptors writing unary calls and streams to the binary log, nil when not logging
+func (l *BinaryLog) interceptors(authority string) (grpc.UnaryClientInterceptor, grpc.StreamClientInterceptor) {
+	if l == nil {
+		return nil, nil
+	}
+	i := binaryLogInterceptor{log: l, authority: authority}
+	return i.unary, i.stream
+}
+
+func (i binaryLogInterceptor) unary(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	call := i.log.begin(ctx, method, i.authority)
+	call.message(binlogpb.GrpcLogEntry_EVENT_TYPE_CLIENT_MESSAGE, req)
+	call.write(&binlogpb.GrpcLogEntry{Type: binlogpb.GrpcLogEntry_EVENT_TYPE_CLIENT_HALF_CLOSE})
+
+	var header, trailer metadata.MD
+	var p peer.Peer
+	opts = append(opts[:len(opts):len(opts)], grpc.Header(&header), grpc.Trailer(&trailer), grpc.Peer(&p))
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	call.setPeer(p.Addr)
+	// a trailers only response has no header
+	if header != nil {
+		call.serverHeader(header)
+	}
+	if err == nil {
+		call.message(binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_MESSAGE, reply)
+	}
+	call.end(trailer, err)
+	return err
+}
+
+func (i binaryLogInterceptor) stream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+	streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	call := i.log.begin(ctx, method, i.authority)
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		call.end(nil, err)
+		return nil, err
+	}
+	// the stream's context knows the server once its transport stream is created
+	if p, ok := peer.FromContext(stream.Context()); ok {
+		call.setPeer(p.Addr)
+	}
+	return &binaryLogStream{ClientStream: stream, call: call, serverStreams: desc.ServerStreams}, nil
+}
+
+// binaryLogStream logs the events of a stream as they happen
+type binaryLogStream struct {
+	grpc.ClientStream
+	call          *binaryLogCall
+	serverStreams bool
+}
+
+func (s *binaryLogStream) Header() (metadata.MD, error) {
+	header, err := s.ClientStream.Header()
+	if err == nil {
+		s.call.serverHeader(header)
+	}
+	return header, err
+}
+
+func (s *binaryLogStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.call.message(binlogpb.GrpcLogEntry_EVENT_TYPE_CLIENT_MESSAGE, m)
+	}
+	return err
+}
+
+func (s *binaryLogStream) CloseSend() error {
+	err := s.ClientStream.CloseSend()
+	if err == nil {
+		s.call.write(&binlogpb.GrpcLogEntry{Type: binlogpb.GrpcLogEntry_EVENT_TYPE_CLIENT_HALF_CLOSE})
+	}
+	return err
+}
+
+func (s *binaryLogStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		if header, headerErr := s.ClientStream.Header(); headerErr == nil {
+			s.call.serverHeader(header)
+		}
+		s.call.message(binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_MESSAGE, m)
+		// a stream without server streaming ends with its only response
+		if !s.serverStreams {
+			s.call.end(s.ClientStream.Trailer(), nil)
+		}
+		return nil
+	}
+	if header, headerErr := s.ClientStream.Header(); headerErr == nil && header != nil {
+		s.call.serverHeader(header)
+	}
+	if err == io.EOF {
+		s.call.end(s.ClientStream.Trailer(), nil)
+	} else {
+		s.call.end(s.ClientStream.Trailer(), err)
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package proto
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestBinaryLogRedactsHeaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calls.binlog")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	log, err := CreateBinaryLog(path, DefaultRedactRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer abc", "x-tenant", "acme")
+	call := log.begin(ctx, "/demo.Echo/Say", "localhost")
+	call.end(metadata.Pairs("x-session-token", "xyz"), nil)
+	if err = log.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("the binary log has mode %v, want 0600", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ReadBinaryLog(data)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("read %d entries, %v", len(entries), err)
+	}
+	header := BinaryLogMetadata(entries[0].GetClientHeader().GetMetadata())
+	if got := header.Get("authorization"); len(got) != 1 || got[0] != "Bearer REDACTED" {
+		t.Errorf("authorization logged as %q", got)
+	}
+	if got := header.Get("x-tenant"); len(got) != 1 || got[0] != "acme" {
+		t.Errorf("x-tenant logged as %q", got)
+	}
+	trailer := BinaryLogMetadata(entries[1].GetTrailer().GetMetadata())
+	if got := trailer.Get("x-session-token"); len(got) != 1 || got[0] != "REDACTED" {
+		t.Errorf("x-session-token logged as %q", got)
+	}
+}
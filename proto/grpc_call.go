@@ -0,0 +1,444 @@
+package proto
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"grpc_ui_tool/auth"
+	"grpc_ui_tool/expand"
+	"grpc_ui_tool/route"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Response holds the result of a client call along with the values that were actually sent after expansion
+type Response struct {
+	Target   string
+	Metadata metadata.MD
+	Request  string
+	Body     string
+	Headers  metadata.MD
+	Trailers metadata.MD
+	Duration time.Duration
+	Attempts int
+	Timing   *Timing
+	Peer     *PeerInfo
+	// Trace is the client span of the call, its trace context was sent in the request metadata
+	Trace *TraceContext
+	// SpanError is why the span could not be exported, it does not fail the call
+	SpanError error
+}
+
+// Call is a request with variables and template functions already evaluated, ready to be invoked any number of times
+type Call struct {
+	Target   string
+	Method   string
+	Metadata metadata.MD
+	Request  string
+
+	methodDesc protoreflect.MethodDescriptor
+	message    *dynamicpb.Message
+	outgoing   metadata.MD
+	// prepare evaluates the request again, it is only set when template actions give a different call each time
+	prepare func() (*Call, error)
+}
+
+// Send will connect to the grpc server and send a grpc request, variables and template functions are evaluated
+// immediately before sending and the returned Response is populated as far as the call got even on error.
+// requestMetadata replaces any server metadata with the same keys for this call only
+func (gcd *GrpcConnection) Send(serviceName string, methodName string, jsonRequest string, requestMetadata metadata.MD) (*Response, error) {
+	response := &Response{}
+
+	call, err := gcd.Prepare(serviceName, methodName, jsonRequest, requestMetadata)
+	if call != nil {
+		response.Target = call.Target
+		response.Metadata = call.Metadata
+		response.Request = call.Request
+	}
+	if err != nil {
+		return response, err
+	}
+	trace, err := call.startTrace(gcd.Trace)
+	if err != nil {
+		return response, err
+	}
+	response.Metadata = call.Metadata
+	response.Trace = &trace
+
+	response.Timing = newTiming()
+	conn, err := gcd.dial(call.Target, response.Timing)
+	if err != nil {
+		return response, err
+	}
+	defer conn.Close()
+
+	ctx, attempts := CountAttempts(context.Background())
+	start := time.Now()
+	var p peer.Peer
+	resp, header, trailer, err := call.Invoke(ctx, conn, grpc.Peer(&p))
+	response.Duration = time.Since(start)
+	response.Attempts = int(attempts.Load())
+	// the dialed server is all that is known when the call failed before grpc had a transport, or used HTTP
+	if response.Peer = peerInfo(&p); response.Peer == nil {
+		response.Peer = response.Timing.dialedPeer()
+	}
+	response.SpanError = gcd.exportSpan(call, response, start, err)
+	if pinErr := response.Timing.pinMismatch(); pinErr != nil {
+		return response, pinErr
+	}
+	response.Headers = DisplayMetadata(header)
+	response.Trailers = DisplayMetadata(trailer)
+	if err != nil {
+		return response, err
+	}
+
+	prettified, err := protojson.MarshalOptions{Multiline: true, Indent: "  ", EmitUnpopulated: true}.Marshal(resp)
+	if err != nil {
+		return response, err
+	}
+	response.Body = string(prettified)
+
+	return response, nil
+}
+
+// Prepare evaluates variables and template functions and parses the request so it can be invoked,
+// the returned Call holds whatever was resolved even when an error is returned
+func (gcd *GrpcConnection) Prepare(serviceName string, methodName string, jsonRequest string, requestMetadata metadata.MD) (*Call, error) {
+	call := &Call{Method: "/" + serviceName + "/" + methodName}
+	merged := MergeMetadata(gcd.Metadata, requestMetadata)
+	if hasActions(jsonRequest, merged) {
+		call.prepare = func() (*Call, error) {
+			return gcd.Prepare(serviceName, methodName, jsonRequest, requestMetadata)
+		}
+	}
+
+	var err error
+	call.Target, err = gcd.Target()
+	if err != nil {
+		return call, err
+	}
+	call.Metadata, err = expand.Metadata(merged, gcd.Variables)
+	if err != nil {
+		return call, err
+	}
+	call.outgoing, err = outgoingMetadata(call.Metadata)
+	if err != nil {
+		return call, err
+	}
+	call.Request, err = expand.JSON(jsonRequest, gcd.Variables)
+	if err != nil {
+		return call, fmt.Errorf("request: %w", err)
+	}
+
+	call.methodDesc, err = gcd.getMethodDesc(serviceName + "." + methodName)
+	if err != nil {
+		return call, err
+	}
+	call.message = dynamicpb.NewMessage(call.methodDesc.Input())
+	if err = (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal([]byte(call.Request), call.message); err != nil {
+		return call, err
+	}
+
+	return call, nil
+}
+
+// Next returns the call to invoke next when sending it repeatedly, the call itself or, when the request or metadata use
+// template actions, the call prepared again so that functions such as {{uuid}} and {{now}} give new values
+func (call *Call) Next() (*Call, error) {
+	if call.prepare == nil {
+		return call, nil
+	}
+	return call.prepare()
+}
+
+// hasActions reports whether the request or any metadata key or value contains a template action
+func hasActions(jsonRequest string, md metadata.MD) bool {
+	if strings.Contains(jsonRequest, "{{") {
+		return true
+	}
+	for key, values := range md {
+		if strings.Contains(key, "{{") {
+			return true
+		}
+		for _, value := range values {
+			if strings.Contains(value, "{{") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Dial creates a connection to target using the connection's settings and protocol, native gRPC connections start
+// connecting immediately. The caller must close it
+func (gcd *GrpcConnection) Dial(target string) (Conn, error) {
+	return gcd.dial(target, nil)
+}
+
+// dial creates a connection like Dial, recording the phases of its calls in timing when it is not nil
+func (gcd *GrpcConnection) dial(target string, timing *Timing) (Conn, error) {
+	provider, err := gcd.getAuthProvider()
+	if err != nil {
+		return nil, err
+	}
+	provider = gcd.DebugLog.credentials(provider)
+	if gcd.Transport.Protocol != ProtocolGRPC {
+		conn, err := gcd.dialHTTP(target, provider, timing)
+		if err != nil {
+			return nil, err
+		}
+		if unary, _ := gcd.interceptors(target); len(unary) > 0 {
+			conn = interceptedConn{Conn: conn, interceptors: unary}
+		}
+		return conn, nil
+	}
+
+	opts, err := gcd.dialOptions(target, timing)
+	if err != nil {
+		return nil, err
+	}
+	if provider != nil {
+		opts = append(opts, grpc.WithPerRPCCredentials(provider))
+	}
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	conn.Connect()
+	return conn, nil
+}
+
+// DialTransparent creates a client connection like Dial without adding the connection's credentials,
+// used when forwarding calls that carry their own
+func (gcd *GrpcConnection) DialTransparent(target string) (*grpc.ClientConn, error) {
+	opts, err := gcd.dialOptions(target, nil)
+	if err != nil {
+		return nil, err
+	}
+	return grpc.NewClient(target, opts...)
+}
+
+func (gcd *GrpcConnection) dialOptions(target string, timing *Timing) ([]grpc.DialOption, error) {
+	creds, err := gcd.transportCredentials()
+	if err != nil {
+		return nil, err
+	}
+	opts, err := gcd.tuningOptions()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, gcd.targetOptions(target)...)
+	// the logs are outermost so hedged attempts are logged as one call
+	unary, stream := gcd.interceptors(target)
+	opts = append(opts, grpc.WithChainUnaryInterceptor(unary...), grpc.WithChainStreamInterceptor(stream...))
+	serviceConfigOpts, err := gcd.serviceConfigOptions()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, serviceConfigOpts...)
+
+	dialer, err := gcd.getRouteDialer()
+	if err != nil {
+		return nil, err
+	}
+	var dial func(ctx context.Context, network string, address string) (net.Conn, error)
+	if dialer != nil {
+		dial = dialer.DialContext
+	}
+	if timing != nil {
+		creds = timedCredentials{TransportCredentials: creds, timing: timing}
+		opts = append(opts, grpc.WithStatsHandler(timingHandler{timing}))
+		if !strings.HasPrefix(target, staticScheme+":///") {
+			opts = append(opts, grpc.WithResolvers(timedResolver{Builder: targetResolver(target), timing: timing}))
+		}
+		// a dialer of our own stops grpc using a proxy from the environment, the connect time is not measured then
+		if dial == nil && !proxyFromEnvironment() {
+			dial = (&net.Dialer{}).DialContext
+		}
+		if dial != nil {
+			routeDial := dial
+			dial = func(ctx context.Context, network string, address string) (net.Conn, error) {
+				timing.mark(connectStart)
+				conn, err := routeDial(ctx, network, address)
+				timing.mark(connected)
+				if err == nil {
+					timing.setPeerAddress(conn.RemoteAddr().String())
+				}
+				return conn, err
+			}
+		}
+	}
+	if dial != nil {
+		opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+			network, address := route.SplitAddress(address)
+			return dial(ctx, network, address)
+		}))
+	}
+	opts = append(opts, grpc.WithTransportCredentials(creds))
+	return opts, nil
+}
+
+// interceptors returns the interceptors of the debug and binary logs that are set, outermost first
+func (gcd *GrpcConnection) interceptors(target string) ([]grpc.UnaryClientInterceptor, []grpc.StreamClientInterceptor) {
+	var unary []grpc.UnaryClientInterceptor
+	var stream []grpc.StreamClientInterceptor
+	add := func(u grpc.UnaryClientInterceptor, s grpc.StreamClientInterceptor) {
+		if u != nil {
+			unary = append(unary, u)
+			stream = append(stream, s)
+		}
+	}
+	add(gcd.DebugLog.interceptors(gcd.FileRegistry))
+	add(gcd.BinaryLog.interceptors(gcd.authority(target)))
+	return unary, stream
+}
+
+// Descriptor returns the descriptor of the called method
+func (call *Call) Descriptor() protoreflect.MethodDescriptor {
+	return call.methodDesc
+}
+
+// Invoke sends the call on conn and returns the response message along with the received headers and trailers,
+// opts are passed on to the connection
+func (call *Call) Invoke(ctx context.Context, conn Conn, opts ...grpc.CallOption) (*dynamicpb.Message, metadata.MD, metadata.MD, error) {
+	ctx = metadata.NewOutgoingContext(ctx, call.outgoing)
+	resp := dynamicpb.NewMessage(call.methodDesc.Output())
+	var header, trailer metadata.MD
+	opts = append(opts, grpc.Header(&header), grpc.Trailer(&trailer))
+	err := conn.Invoke(ctx, call.Method, call.message, resp, opts...)
+	return resp, header, trailer, err
+}
+
+// getRouteDialer returns the dialer for the expanded route settings, like the auth provider it is kept between calls
+// so that an SSH tunnel stays open until the settings change and the connections it made are closed
+func (gcd *GrpcConnection) getRouteDialer() (route.Dialer, error) {
+	cfg := gcd.Route
+	for _, field := range []*string{&cfg.Address, &cfg.Username, &cfg.Password, &cfg.KeyFile, &cfg.KnownHosts} {
+		expanded, err := expand.String(*field, gcd.Variables)
+		if err != nil {
+			return nil, fmt.Errorf("route: %w", err)
+		}
+		*field = expanded
+	}
+
+	cache := gcd.routeCache
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.dialer != nil && cfg == cache.config {
+		return cache.dialer, nil
+	}
+	dialer, err := route.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cache.dialer != nil {
+		cache.dialer.retire()
+	}
+	cache.config = cfg
+	cache.dialer = nil
+	if dialer == nil {
+		return nil, nil
+	}
+	cache.dialer = &sharedDialer{dialer: dialer}
+	return cache.dialer, nil
+}
+
+// sharedDialer counts the connections made by a route dialer and the dials in progress, so that a dialer replaced
+// while connections still use it is only closed when the last of them closes
+type sharedDialer struct {
+	dialer  route.Dialer
+	mu      sync.Mutex
+	active  int
+	retired bool
+	closed  bool
+}
+
+func (d *sharedDialer) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	d.mu.Lock()
+	d.active++
+	d.mu.Unlock()
+	conn, err := d.dialer.DialContext(ctx, network, address)
+	if err != nil {
+		d.release()
+		return nil, err
+	}
+	return &routedConn{Conn: conn, release: d.release}, nil
+}
+
+// Close closes the dialer once it is idle
+func (d *sharedDialer) Close() error {
+	d.retire()
+	return nil
+}
+
+// retire marks the dialer as replaced and closes it if no connection uses it
+func (d *sharedDialer) retire() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.retired = true
+	d.closeIfIdle()
+}
+
+func (d *sharedDialer) release() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.active--
+	d.closeIfIdle()
+}
+
+func (d *sharedDialer) closeIfIdle() {
+	if d.retired && d.active == 0 && !d.closed {
+		d.closed = true
+		_ = d.dialer.Close()
+	}
+}
+
+// routedConn releases its dialer when it is closed
+type routedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *routedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+// getAuthProvider returns the credential provider for the expanded auth settings, the provider is kept between calls
+// so that tokens are cached until they expire and only recreated when the settings change
+func (gcd *GrpcConnection) getAuthProvider() (auth.Provider, error) {
+	cfg := gcd.Auth
+	for _, field := range []*string{&cfg.Header, &cfg.Scheme, &cfg.Token, &cfg.File, &cfg.Command,
+		&cfg.TokenURL, &cfg.ClientID, &cfg.ClientSecret, &cfg.Scopes, &cfg.Audience} {
+		expanded, err := expand.String(*field, gcd.Variables)
+		if err != nil {
+			return nil, fmt.Errorf("credentials: %w", err)
+		}
+		*field = expanded
+	}
+
+	cache := gcd.authCache
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.provider != nil && cfg == cache.config {
+		return cache.provider, nil
+	}
+	provider, err := auth.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cache.config = cfg
+	cache.provider = provider
+	return provider, nil
+}
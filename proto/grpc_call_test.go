@@ -0,0 +1,123 @@
+package proto
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"grpc_ui_tool/auth"
+	"grpc_ui_tool/route"
+)
+
+// pipeDialer hands out one end of a pipe for every dial and counts how often it is closed
+type pipeDialer struct {
+	closed int
+}
+
+func (d *pipeDialer) DialContext(context.Context, string, string) (net.Conn, error) {
+	client, server := net.Pipe()
+	_ = server.Close()
+	return client, nil
+}
+
+func (d *pipeDialer) Close() error {
+	d.closed++
+	return nil
+}
+
+func TestSharedDialerClosesWhenIdle(t *testing.T) {
+	underlying := &pipeDialer{}
+	d := &sharedDialer{dialer: underlying}
+	first, err := d.DialContext(context.Background(), "tcp", "a:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := d.DialContext(context.Background(), "tcp", "a:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.retire()
+	if underlying.closed != 0 {
+		t.Fatal("a dialer in use was closed when it was replaced")
+	}
+	_ = first.Close()
+	_ = first.Close()
+	if underlying.closed != 0 {
+		t.Fatal("the dialer was closed while a connection still used it")
+	}
+	_ = second.Close()
+	if underlying.closed != 1 {
+		t.Fatalf("the dialer was closed %d times after its last connection, want 1", underlying.closed)
+	}
+}
+
+func TestSharedDialerIdleIsClosedOnRetire(t *testing.T) {
+	underlying := &pipeDialer{}
+	d := &sharedDialer{dialer: underlying}
+	d.retire()
+	d.retire()
+	if underlying.closed != 1 {
+		t.Fatalf("closed %d times, want 1", underlying.closed)
+	}
+}
+
+func TestRouteCacheIsSharedByCopies(t *testing.T) {
+	gcd := NewGrpcConnection()
+	gcd.SetRoute(route.Config{Type: route.HTTPConnect, Address: "{{proxy}}"})
+	gcd.SetVariables(map[string]string{"proxy": "proxy-a:3128"})
+	step := gcd.WithVariables(map[string]string{"extra": "1"})
+
+	parent, err := gcd.getRouteDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, err := step.getRouteDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent != copied {
+		t.Error("a copy with the same route settings created a second dialer")
+	}
+
+	other := gcd.WithVariables(map[string]string{"proxy": "proxy-b:3128"})
+	replaced, err := other.getRouteDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gcd.routeCache.dialer != replaced {
+		t.Error("the dialer created by a copy is not kept by the shared cache")
+	}
+	if !parent.(*sharedDialer).retired {
+		t.Error("the replaced dialer was not retired")
+	}
+
+	gcd.SetRoute(route.Config{})
+	if direct, err := gcd.getRouteDialer(); err != nil || direct != nil {
+		t.Errorf("direct route gave %v, %v", direct, err)
+	}
+}
+
+func TestAuthCacheIsSharedByCopies(t *testing.T) {
+	gcd := NewGrpcConnection()
+	gcd.SetAuth(auth.Config{Type: auth.StaticToken, Token: "{{token}}"})
+	gcd.SetVariables(map[string]string{"token": "abc"})
+
+	parent, err := gcd.getAuthProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, err := gcd.WithVariables(map[string]string{"step": "1"}).getAuthProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent != copied {
+		t.Error("a copy with the same credentials created a second provider")
+	}
+	if _, err = gcd.WithVariables(map[string]string{"token": "def"}).getAuthProvider(); err != nil {
+		t.Fatal(err)
+	}
+	if gcd.authCache.provider == parent {
+		t.Error("the provider created by a copy is not kept by the shared cache")
+	}
+}
@@ -0,0 +1,389 @@
+package proto
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"grpc_ui_tool/auth"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// debugLogSize is how many calls the debug log keeps, the oldest are dropped first
+const debugLogSize = 1000
+
+// redactedValue replaces the sensitive parts of header values in the debug log
+const redactedValue = "REDACTED"
+
+// RedactRule hides the values of headers whose name matches Header, a glob pattern matched case insensitively.
+// When Pattern is set only the parts of the value matching the regular expression are hidden, otherwise all of it
+type RedactRule struct {
+	Header  string
+	Pattern string
+}
+
+// DefaultRedactRules hide credentials, cookies, tokens and secrets, keeping the scheme of authorization headers
+var DefaultRedactRules = []RedactRule{
+	{Header: "authorization", Pattern: `[^ ]+$`},
+	{Header: "proxy-authorization", Pattern: `[^ ]+$`},
+	{Header: "cookie"},
+	{Header: "*api-key*"},
+	{Header: "*token*"},
+	{Header: "*secret*"},
+	{Header: "*password*"},
+}
+
+// Check reports whether the header glob and value pattern are valid
+func (r RedactRule) Check() error {
+	_, err := r.compile()
+	return err
+}
+
+type redactor struct {
+	header  string
+	pattern *regexp.Regexp
+}
+
+func (r RedactRule) compile() (redactor, error) {
+	if r.Header == "" {
+		return redactor{}, fmt.Errorf("empty header pattern")
+	}
+	header := strings.ToLower(r.Header)
+	if _, err := path.Match(header, ""); err != nil {
+		return redactor{}, fmt.Errorf("header pattern %q: %w", r.Header, err)
+	}
+	compiled := redactor{header: header}
+	if r.Pattern != "" {
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return redactor{}, fmt.Errorf("value pattern %q: %w", r.Pattern, err)
+		}
+		compiled.pattern = pattern
+	}
+	return compiled, nil
+}
+
+// DebugEntry is one call as the client sent and received it, the metadata includes the credentials added by the
+// server's auth settings and has already been redacted. Messages are JSON, a stream has one per message sent or
+// received
+type DebugEntry struct {
+	Time      time.Time
+	Method    string
+	Stream    bool
+	Metadata  metadata.MD
+	Requests  []string
+	Responses []string
+	Code      codes.Code
+	Message   string
+	Duration  time.Duration
+}
+
+// DebugLog records every call made on connections it is set on, redacting sensitive header values
+type DebugLog struct {
+	mu        sync.Mutex
+	entries   []DebugEntry
+	redactors []redactor
+	onEntry   func()
+}
+
+// NewDebugLog creates an empty log that redacts headers with rules
+func NewDebugLog(rules []RedactRule) (*DebugLog, error) {
+	l := &DebugLog{}
+	if err := l.SetRedactRules(rules); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// SetDebugLog sets the log that calls on new connections are recorded in, nil records nothing
+func (gcd *GrpcConnection) SetDebugLog(log *DebugLog) {
+	gcd.DebugLog = log
+}
+
+// SetRedactRules replaces the rules applied to calls recorded from now on
+func (l *DebugLog) SetRedactRules(rules []RedactRule) error {
+	redactors, err := compileRedactRules(rules)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.redactors = redactors
+	return nil
+}
+
+// OnEntry sets a function called after each call is recorded, nil stops notifications
+func (l *DebugLog) OnEntry(onEntry func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onEntry = onEntry
+}
+
+// Entries returns the recorded calls, oldest first
+func (l *DebugLog) Entries() []DebugEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]DebugEntry(nil), l.entries...)
+}
+
+// Clear drops every recorded call
+func (l *DebugLog) Clear() {
+	l.mu.Lock()
+	l.entries = nil
+	onEntry := l.onEntry
+	l.mu.Unlock()
+	if onEntry != nil {
+		onEntry()
+	}
+}
+
+func compileRedactRules(rules []RedactRule) ([]redactor, error) {
+	redactors := make([]redactor, 0, len(rules))
+	for _, rule := range rules {
+		compiled, err := rule.compile()
+		if err != nil {
+			return nil, err
+		}
+		redactors = append(redactors, compiled)
+	}
+	return redactors, nil
+}
+
+// Redact returns a copy of md with the values of sensitive headers hidden
+func (l *DebugLog) Redact(md metadata.MD) metadata.MD {
+	l.mu.Lock()
+	redactors := l.redactors
+	l.mu.Unlock()
+	return redactMetadata(redactors, md)
+}
+
+func redactMetadata(redactors []redactor, md metadata.MD) metadata.MD {
+	redacted := make(metadata.MD, len(md))
+	for key, values := range md {
+		redacted[key] = append([]string(nil), values...)
+		for _, r := range redactors {
+			if matched, _ := path.Match(r.header, strings.ToLower(key)); !matched {
+				continue
+			}
+			for i, value := range redacted[key] {
+				if r.pattern == nil {
+					redacted[key][i] = redactedValue
+				} else {
+					redacted[key][i] = r.pattern.ReplaceAllLiteralString(value, redactedValue)
+				}
+			}
+		}
+	}
+	return redacted
+}
+
+// Sensitive reports whether a redaction rule hides the values of the header key
+func (l *DebugLog) Sensitive(key string) bool {
+	l.mu.Lock()
+	redactors := l.redactors
+	l.mu.Unlock()
+	for _, r := range redactors {
+		if matched, _ := path.Match(r.header, strings.ToLower(key)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *DebugLog) add(entry DebugEntry) {
+	l.mu.Lock()
+	l.entries = append(l.entries, entry)
+	if len(l.entries) > debugLogSize {
+		l.entries = append([]DebugEntry(nil), l.entries[len(l.entries)-debugLogSize:]...)
+	}
+	onEntry := l.onEntry
+	l.mu.Unlock()
+	if onEntry != nil {
+		onEntry()
+	}
+}
+
+// debugCall collects a call in progress, the credentials are added from another goroutine by grpc
+type debugCall struct {
+	mu    sync.Mutex
+	start time.Time
+	entry DebugEntry
+}
+
+type debugCallKey struct{}
+
+func (c *debugCall) addMetadata(md map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, value := range md {
+		c.entry.Metadata.Set(key, value)
+	}
+}
+
+func (c *debugCall) addMessage(request bool, message string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if request {
+		c.entry.Requests = append(c.entry.Requests, message)
+	} else {
+		c.entry.Responses = append(c.entry.Responses, message)
+	}
+}
+
+// debugInterceptor records calls in the log, decoding messages forwarded as raw bytes with the files
+type debugInterceptor struct {
+	log   *DebugLog
+	files *protoregistry.Files
+}
+
+// interceptors returns the interceptors recording unary calls and streams, nil when not logging
+func (l *DebugLog) interceptors(files *protoregistry.Files) (grpc.UnaryClientInterceptor, grpc.StreamClientInterceptor) {
+	if l == nil {
+		return nil, nil
+	}
+	i := debugInterceptor{log: l, files: files}
+	return i.unary, i.stream
+}
+
+// credentials records the metadata added by the auth settings along with the call's own
+func (l *DebugLog) credentials(provider auth.Provider) auth.Provider {
+	if l == nil || provider == nil {
+		return provider
+	}
+	return loggedCredentials{Provider: provider}
+}
+
+func (i debugInterceptor) begin(ctx context.Context, method string, stream bool) (context.Context, *debugCall) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	call := &debugCall{start: time.Now(), entry: DebugEntry{Method: method, Stream: stream, Metadata: md.Copy()}}
+	call.entry.Time = call.start
+	return context.WithValue(ctx, debugCallKey{}, call), call
+}
+
+func (i debugInterceptor) finish(call *debugCall, err error) {
+	call.mu.Lock()
+	entry := call.entry
+	call.mu.Unlock()
+	entry.Duration = time.Since(call.start)
+	entry.Metadata = i.log.Redact(entry.Metadata)
+	st := status.Convert(err)
+	entry.Code = st.Code()
+	entry.Message = st.Message()
+	i.log.add(entry)
+}
+
+func (i debugInterceptor) unary(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx, call := i.begin(ctx, method, false)
+	call.addMessage(true, i.messageJSON(method, true, req))
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err == nil {
+		call.addMessage(false, i.messageJSON(method, false, reply))
+	}
+	i.finish(call, err)
+	return err
+}
+
+func (i debugInterceptor) stream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+	streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	ctx, call := i.begin(ctx, method, true)
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		i.finish(call, err)
+		return nil, err
+	}
+	return &loggedStream{ClientStream: stream, interceptor: i, call: call, method: method,
+		serverStreams: desc.ServerStreams}, nil
+}
+
+// messageJSON formats a message for the log, messages forwarded as raw bytes are decoded with the method's types
+func (i debugInterceptor) messageJSON(method string, request bool, m any) string {
+	if data, ok := m.(*[]byte); ok {
+		decoded, err := decodeMethodMessage(i.files, method, request, *data)
+		if err != nil {
+			return fmt.Sprintf("%d bytes, %v", len(*data), err)
+		}
+		m = decoded
+	}
+	message, ok := m.(proto.Message)
+	if !ok {
+		return fmt.Sprintf("%v", m)
+	}
+	opts := protojson.MarshalOptions{}
+	if i.files != nil {
+		opts.Resolver = dynamicpb.NewTypes(i.files)
+	}
+	data, err := opts.Marshal(message)
+	if err != nil {
+		return fmt.Sprintf("unprintable %s: %v", message.ProtoReflect().Descriptor().FullName(), err)
+	}
+	return string(data)
+}
+
+// loggedStream records the messages of a stream, the call is logged once the stream ends
+type loggedStream struct {
+	grpc.ClientStream
+	interceptor   debugInterceptor
+	call          *debugCall
+	method        string
+	serverStreams bool
+	once          sync.Once
+}
+
+func (s *loggedStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.call.addMessage(true, s.interceptor.messageJSON(s.method, true, m))
+	}
+	return err
+}
+
+func (s *loggedStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		s.call.addMessage(false, s.interceptor.messageJSON(s.method, false, m))
+		// a stream without server streaming ends with its only response
+		if !s.serverStreams {
+			s.end(nil)
+		}
+		return nil
+	}
+	if err == io.EOF {
+		s.end(nil)
+	} else {
+		s.end(err)
+	}
+	return err
+}
+
+func (s *loggedStream) end(err error) {
+	s.once.Do(func() {
+		s.interceptor.finish(s.call, err)
+	})
+}
+
+// loggedCredentials adds the credentials sent with a call to its debug log entry
+type loggedCredentials struct {
+	auth.Provider
+}
+
+func (c loggedCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	md, err := c.Provider.GetRequestMetadata(ctx, uri...)
+	if call, ok := ctx.Value(debugCallKey{}).(*debugCall); ok && err == nil {
+		call.addMetadata(md)
+	}
+	return md, err
+}
@@ -1,43 +1,111 @@
 package proto
 
 import (
-	"context"
-	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+
+	"grpc_ui_tool/auth"
+	"grpc_ui_tool/route"
 
 	"github.com/jhump/protoreflect/desc"
 	"github.com/jhump/protoreflect/desc/protoparse"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
-	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protodesc"
 	"google.golang.org/protobuf/reflect/protoregistry"
 	"google.golang.org/protobuf/types/descriptorpb"
-	"google.golang.org/protobuf/types/dynamicpb"
 )
 
 type GrpcConnection struct {
-	Hostname     string
-	Port         string
-	Metadata     map[string]string
-	FileRegistry *protoregistry.Files
+	Hostname      string
+	Port          string
+	Addresses     []string
+	LoadBalancing string
+	Metadata      metadata.MD
+	Variables     map[string]string
+	Auth          auth.Config
+	TLS           TLSConfig
+	Transport     TransportConfig
+	Route         route.Config
+	Tuning        TuningConfig
+	Trace         TraceConfig
+	ServiceConfig string
+	FileRegistry  *protoregistry.Files
+	DebugLog      *DebugLog
+	BinaryLog     *BinaryLog
+
+	// OnPin is called with the fingerprint of a certificate pinned on first use by any connection, so that it can be
+	// saved with the server. It runs during the TLS handshake
+	OnPin func(pin string)
+
+	authCache  *authCache
+	routeCache *routeCache
+	pins       *pinStore
+}
+
+// authCache holds the credential provider kept between calls, copies of a connection share it and calls on
+// several goroutines use it at once
+type authCache struct {
+	mu       sync.Mutex
+	config   auth.Config
+	provider auth.Provider
+}
+
+// routeCache holds the route dialer kept between calls so that an SSH tunnel stays open, copies of a connection
+// share it. A dialer replaced after the settings changed is closed once the connections it made are closed
+type routeCache struct {
+	mu     sync.Mutex
+	config route.Config
+	dialer *sharedDialer
 }
 
 func NewGrpcConnection() *GrpcConnection {
-	return &GrpcConnection{}
+	return &GrpcConnection{authCache: &authCache{}, routeCache: &routeCache{}, pins: &pinStore{}}
 }
 
 // SetConnectionDetails sets the grpc server details to be used for a client connection to the server
-func (gcd *GrpcConnection) SetConnectionDetails(hostname string, port string, metadata map[string]string) {
+func (gcd *GrpcConnection) SetConnectionDetails(hostname string, port string, md metadata.MD) {
 	gcd.Hostname = hostname
 	gcd.Port = port
-	gcd.Metadata = metadata
+	gcd.Metadata = md
+}
+
+// SetAuth sets the credential provider used to authenticate every call
+func (gcd *GrpcConnection) SetAuth(cfg auth.Config) {
+	gcd.Auth = cfg
+}
+
+// SetRoute sets how connections reach the server, directly or through a proxy or SSH tunnel
+func (gcd *GrpcConnection) SetRoute(cfg route.Config) {
+	gcd.Route = cfg
+}
+
+// SetVariables sets the environment variables substituted into the connection details and requests at send time
+func (gcd *GrpcConnection) SetVariables(variables map[string]string) {
+	gcd.Variables = variables
+}
+
+// WithVariables returns a copy of the connection with extra variables layered over the environment,
+// used to pass values captured from earlier responses into later requests. The copy shares the cached credential
+// provider and route dialer, so tokens and tunnels are reused by every step, and the certificate pinned on first use
+func (gcd *GrpcConnection) WithVariables(variables map[string]string) *GrpcConnection {
+	merged := make(map[string]string, len(gcd.Variables)+len(variables))
+	for key, value := range gcd.Variables {
+		merged[key] = value
+	}
+	for key, value := range variables {
+		merged[key] = value
+	}
+	withVars := *gcd
+	withVars.Variables = merged
+	return &withVars
 }
 
-// LoadRegistry takes a .proto file and loads the file and associated imports/paths into a grpc file registry
-func (gcd *GrpcConnection) LoadRegistry(importPaths []string, protoFile string) error {
+// LoadRegistry takes .proto files and loads the files and associated imports/paths into a grpc file registry
+func (gcd *GrpcConnection) LoadRegistry(importPaths []string, protoFiles ...string) error {
 
-	f, err := protoparse.ResolveFilenames(importPaths, protoFile)
+	f, err := protoparse.ResolveFilenames(importPaths, protoFiles...)
 	if err != nil {
 		return err
 	}
@@ -67,56 +135,34 @@ func (gcd *GrpcConnection) LoadRegistry(importPaths []string, protoFile string)
 	return nil
 }
 
-// Send will connect to the grpc server and send a grpc request
-func (gcd *GrpcConnection) Send(serviceName string, methodName string, jsonRequest string) (string, error) {
-	tlsCfg := &tls.Config{
-		MinVersion:         tls.VersionTLS12,
-		InsecureSkipVerify: true,
-	}
-
-	opts := []grpc.DialOption{
-		grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)),
-		grpc.WithUserAgent("grpc-tool/1.0"),
-	}
-
-	conn, err := grpc.NewClient(gcd.Hostname+":"+gcd.Port, opts...)
-	if err != nil {
-		return "", err
-	}
-
-	messageDesc, err := gcd.getMethodDesc(serviceName + "." + methodName)
-	if err != nil {
-		return "", err
-	}
-
-	req := dynamicpb.NewMessage(messageDesc.Input())
-	if err = (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal([]byte(jsonRequest), req); err != nil {
-		return "", err
-	}
-	ctx := metadata.NewOutgoingContext(context.Background(), nil)
-	for key, value := range gcd.Metadata {
-		ctx = metadata.AppendToOutgoingContext(ctx, key, value)
-	}
-
-	resp := dynamicpb.NewMessage(messageDesc.Output())
-	conn.Connect()
-	formatMethodName := "/" + serviceName + "/" + string(messageDesc.Name())
-	err = conn.Invoke(ctx, formatMethodName, req, resp)
-	if err != nil {
-		return "", err
-	}
-
-	prettified, err := protojson.MarshalOptions{Multiline: true, Indent: "  ", EmitUnpopulated: true}.Marshal(resp)
-	if err != nil {
-		return "", err
-	}
-
-	err = conn.Close()
+// LoadProtosets loads compiled FileDescriptorSet files, as written by protoc --descriptor_set_out with
+// --include_imports, into a grpc file registry
+func (gcd *GrpcConnection) LoadProtosets(paths ...string) error {
+	fdSet := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]struct{})
+	for _, path := range paths {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		set := &descriptorpb.FileDescriptorSet{}
+		if err = proto.Unmarshal(contents, set); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for _, fd := range set.File {
+			if _, ok := seen[fd.GetName()]; !ok {
+				seen[fd.GetName()] = struct{}{}
+				fdSet.File = append(fdSet.File, fd)
+			}
+		}
+	}
+
+	files, err := protodesc.NewFiles(fdSet)
 	if err != nil {
-		return string(prettified), err
+		return err
 	}
-
-	return string(prettified), nil
+	gcd.FileRegistry = files
+	return nil
 }
 
 func (gcd *GrpcConnection) walkFileDescriptors(seen map[string]struct{}, fd *desc.FileDescriptor) []*descriptorpb.FileDescriptorProto {
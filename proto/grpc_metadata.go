@@ -0,0 +1,118 @@
+package proto
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataKey normalises a metadata key as entered to lower case, keys using template actions keep their case so
+// names such as {{secret "ApiKey"}} still resolve and are lowercased once expanded
+func MetadataKey(key string) string {
+	if strings.Contains(key, "{{") {
+		return key
+	}
+	return strings.ToLower(key)
+}
+
+// MergeMetadata returns the server defaults with every key present in overrides replaced by the override values
+func MergeMetadata(defaults metadata.MD, overrides metadata.MD) metadata.MD {
+	merged := defaults.Copy()
+	if merged == nil {
+		merged = metadata.MD{}
+	}
+	for key, values := range overrides {
+		merged[MetadataKey(key)] = append([]string{}, values...)
+	}
+	return merged
+}
+
+// ValidateMetadata checks header names and values against the gRPC rules, -bin values must be base64
+func ValidateMetadata(md metadata.MD) error {
+	for key, values := range md {
+		if err := validateMetadataKey(key); err != nil {
+			return err
+		}
+		for _, value := range values {
+			if strings.HasSuffix(key, "-bin") {
+				if _, err := DecodeBinaryValue(value); err != nil {
+					return fmt.Errorf("metadata %q: binary values must be base64: %w", key, err)
+				}
+				continue
+			}
+			for i := 0; i < len(value); i++ {
+				if value[i] < 0x20 || value[i] > 0x7E {
+					return fmt.Errorf("metadata %q: value contains non printable ASCII character %q, use a -bin key for binary data", key, value[i])
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validateMetadataKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("metadata key is empty")
+	}
+	if strings.HasPrefix(key, ":") {
+		return fmt.Errorf("metadata %q: pseudo headers cannot be set", key)
+	}
+	if strings.HasPrefix(strings.ToLower(key), "grpc-") {
+		return fmt.Errorf("metadata %q: the grpc- prefix is reserved", key)
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return fmt.Errorf("metadata %q: key may only contain letters, digits, '-', '_' and '.'", key)
+		}
+	}
+	return nil
+}
+
+// outgoingMetadata converts metadata as entered into what is sent on the wire, -bin values are decoded from base64
+// to raw bytes which grpc then encodes itself
+func outgoingMetadata(md metadata.MD) (metadata.MD, error) {
+	if err := ValidateMetadata(md); err != nil {
+		return nil, err
+	}
+	outgoing := metadata.MD{}
+	for key, values := range md {
+		key = strings.ToLower(key)
+		for _, value := range values {
+			if strings.HasSuffix(key, "-bin") {
+				decoded, err := DecodeBinaryValue(value)
+				if err != nil {
+					return nil, err
+				}
+				value = string(decoded)
+			}
+			outgoing[key] = append(outgoing[key], value)
+		}
+	}
+	return outgoing, nil
+}
+
+// DisplayMetadata converts received metadata into a printable form, -bin values are base64 encoded
+func DisplayMetadata(md metadata.MD) metadata.MD {
+	display := metadata.MD{}
+	for key, values := range md {
+		for _, value := range values {
+			if strings.HasSuffix(key, "-bin") {
+				value = base64.StdEncoding.EncodeToString([]byte(value))
+			}
+			display[key] = append(display[key], value)
+		}
+	}
+	return display
+}
+
+// DecodeBinaryValue decodes the value of a -bin metadata key, it accepts standard or URL safe base64 with or without
+// padding
+func DecodeBinaryValue(value string) ([]byte, error) {
+	trimmed := strings.TrimRight(value, "=")
+	if decoded, err := base64.RawStdEncoding.DecodeString(trimmed); err == nil {
+		return decoded, nil
+	}
+	return base64.RawURLEncoding.DecodeString(trimmed)
+}
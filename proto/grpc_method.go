@@ -3,8 +3,13 @@ package proto
 import (
 	"fmt"
 	"sort"
+	"strings"
 
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
 )
 
 // GetMethods returns a list of grpc methods associated with a grpc service
@@ -41,3 +46,42 @@ func (gcd *GrpcConnection) getMethodDesc(methodName string) (protoreflect.Method
 
 	return methodDesc, nil
 }
+
+// DecodeMessage decodes a serialized request or response of a method given as /package.Service/Method and returns
+// it as indented JSON, used to show messages that were recorded outside the tool
+func (gcd *GrpcConnection) DecodeMessage(method string, request bool, data []byte) (string, error) {
+	message, err := decodeMethodMessage(gcd.FileRegistry, method, request, data)
+	if err != nil {
+		return "", err
+	}
+	opts := protojson.MarshalOptions{Multiline: true, Indent: "  ", Resolver: dynamicpb.NewTypes(gcd.FileRegistry)}
+	decoded, err := opts.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+func decodeMethodMessage(files *protoregistry.Files, method string, request bool, data []byte) (proto.Message, error) {
+	if files == nil {
+		return nil, fmt.Errorf("no proto files loaded")
+	}
+	service, name, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(service + "." + name))
+	if err != nil {
+		return nil, err
+	}
+	methodDesc, ok := desc.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a method", desc.FullName())
+	}
+	messageDesc := methodDesc.Output()
+	if request {
+		messageDesc = methodDesc.Input()
+	}
+	message := dynamicpb.NewMessage(messageDesc)
+	if err = proto.Unmarshal(data, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
@@ -0,0 +1,85 @@
+package proto
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// certificateExpiryWarning is how long before it expires a certificate is reported as expiring soon
+const certificateExpiryWarning = 30 * 24 * time.Hour
+
+// PeerInfo describes the server a call talked to, TLS is nil for plaintext connections
+type PeerInfo struct {
+	Address string
+	TLS     *tls.ConnectionState
+}
+
+// peerInfo converts the peer grpc reports for a call, nil when the call never reached a server
+func peerInfo(p *peer.Peer) *PeerInfo {
+	if p.Addr == nil {
+		return nil
+	}
+	info := &PeerInfo{Address: p.Addr.String()}
+	if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		info.TLS = &tlsInfo.State
+	}
+	return info
+}
+
+// Certificates returns the certificate chain the server sent, leaf first
+func (p *PeerInfo) Certificates() []*x509.Certificate {
+	if p.TLS == nil {
+		return nil
+	}
+	return p.TLS.PeerCertificates
+}
+
+// CertificateWarnings returns a warning for every certificate in the chain that has expired, is not valid yet or
+// expires within 30 days of now
+func (p *PeerInfo) CertificateWarnings(now time.Time) []string {
+	var warnings []string
+	for _, cert := range p.Certificates() {
+		name := cert.Subject.String()
+		switch {
+		case now.After(cert.NotAfter):
+			warnings = append(warnings, fmt.Sprintf("%s expired on %s", name, cert.NotAfter.Format(time.DateOnly)))
+		case now.Before(cert.NotBefore):
+			warnings = append(warnings, fmt.Sprintf("%s is not valid until %s", name, cert.NotBefore.Format(time.DateOnly)))
+		case cert.NotAfter.Sub(now) < certificateExpiryWarning:
+			days := int(cert.NotAfter.Sub(now).Hours() / 24)
+			warnings = append(warnings, fmt.Sprintf("%s expires in %d days, on %s", name, days, cert.NotAfter.Format(time.DateOnly)))
+		}
+	}
+	return warnings
+}
+
+// SubjectAltNames returns the DNS names, IP addresses, URIs and email addresses a certificate is valid for
+func SubjectAltNames(cert *x509.Certificate) []string {
+	names := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	return append(names, cert.EmailAddresses...)
+}
+
+// CertificateFingerprint returns the SHA-256 fingerprint of a certificate as colon separated hex
+func CertificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	hexSum := strings.ToUpper(hex.EncodeToString(sum[:]))
+	pairs := make([]string, 0, len(sum))
+	for i := 0; i < len(hexSum); i += 2 {
+		pairs = append(pairs, hexSum[i:i+2])
+	}
+	return strings.Join(pairs, ":")
+}
@@ -0,0 +1,349 @@
+package proto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// serviceConfig holds the parts of a gRPC service config the tool reads itself, the full JSON is handed to grpc
+type serviceConfig struct {
+	MethodConfig []struct {
+		Name []struct {
+			Service string `json:"service"`
+			Method  string `json:"method"`
+		} `json:"name"`
+		Timeout       string          `json:"timeout"`
+		RetryPolicy   json.RawMessage `json:"retryPolicy"`
+		HedgingPolicy *hedgingPolicy  `json:"hedgingPolicy"`
+	} `json:"methodConfig"`
+}
+
+// hedgingPolicy is not implemented by grpc-go, hedged calls are sent by an interceptor instead
+type hedgingPolicy struct {
+	MaxAttempts         int          `json:"maxAttempts"`
+	HedgingDelay        string       `json:"hedgingDelay"`
+	NonFatalStatusCodes []codes.Code `json:"nonFatalStatusCodes"`
+
+	delay   time.Duration
+	timeout time.Duration
+}
+
+// SetServiceConfig sets the gRPC service config JSON used by native gRPC connections, with retry and hedging
+// policies, timeouts and load balancing config. An empty string leaves grpc's defaults
+func (gcd *GrpcConnection) SetServiceConfig(serviceConfig string) {
+	gcd.ServiceConfig = serviceConfig
+}
+
+// CheckServiceConfig validates the service config the way grpc will when dialing, and when proto files are loaded
+// reports any method config naming a service or method that is not in them
+func (gcd *GrpcConnection) CheckServiceConfig() error {
+	js, err := gcd.serviceConfigJSON()
+	if err != nil || js == "" {
+		return err
+	}
+	sc, err := parseServiceConfig(js)
+	if err != nil {
+		return err
+	}
+	// grpc only parses the default service config when a client is created, nothing is dialed until first use
+	conn, err := grpc.NewClient("passthrough:///service-config", grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(js))
+	if err != nil {
+		return err
+	}
+	_ = conn.Close()
+
+	if gcd.FileRegistry == nil {
+		return nil
+	}
+	var unknown []string
+	for _, mc := range sc.MethodConfig {
+		for _, name := range mc.Name {
+			if name.Service == "" {
+				continue
+			}
+			desc, err := gcd.FileRegistry.FindDescriptorByName(protoreflect.FullName(name.Service))
+			service, ok := desc.(protoreflect.ServiceDescriptor)
+			if err != nil || !ok {
+				unknown = append(unknown, "service "+name.Service)
+			} else if name.Method != "" && service.Methods().ByName(protoreflect.Name(name.Method)) == nil {
+				unknown = append(unknown, "method "+name.Service+"/"+name.Method)
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("service config names %s, not in the loaded proto files", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// serviceConfigJSON returns the service config to dial with, the server's load balancing policy replaces any
+// policy set in the service config
+func (gcd *GrpcConnection) serviceConfigJSON() (string, error) {
+	if strings.TrimSpace(gcd.ServiceConfig) == "" {
+		if gcd.LoadBalancing == "" {
+			return "", nil
+		}
+		return fmt.Sprintf(`{"loadBalancingConfig": [{%q: {}}]}`, gcd.LoadBalancing), nil
+	}
+	if gcd.LoadBalancing == "" {
+		return gcd.ServiceConfig, nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(gcd.ServiceConfig), &fields); err != nil {
+		return "", fmt.Errorf("service config: %w", err)
+	}
+	delete(fields, "loadBalancingPolicy")
+	fields["loadBalancingConfig"] = []any{map[string]any{gcd.LoadBalancing: map[string]any{}}}
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("service config: %w", err)
+	}
+	return string(merged), nil
+}
+
+// parseServiceConfig reads the method configs and checks the hedging policies, which grpc ignores
+func parseServiceConfig(js string) (*serviceConfig, error) {
+	sc := &serviceConfig{}
+	if err := json.Unmarshal([]byte(js), sc); err != nil {
+		return nil, fmt.Errorf("service config: %w", err)
+	}
+	for i, mc := range sc.MethodConfig {
+		policy := mc.HedgingPolicy
+		if policy == nil {
+			continue
+		}
+		if len(mc.RetryPolicy) > 0 && string(mc.RetryPolicy) != "null" {
+			return nil, fmt.Errorf("service config: method config %d has both a retryPolicy and a hedgingPolicy", i)
+		}
+		if policy.MaxAttempts < 2 {
+			return nil, fmt.Errorf("service config: hedgingPolicy maxAttempts must be at least 2")
+		}
+		var err error
+		if policy.delay, err = parseJSONDuration(policy.HedgingDelay); err != nil {
+			return nil, fmt.Errorf("service config: hedgingDelay: %w", err)
+		}
+		if policy.timeout, err = parseJSONDuration(mc.Timeout); err != nil {
+			return nil, fmt.Errorf("service config: timeout: %w", err)
+		}
+	}
+	return sc, nil
+}
+
+// parseJSONDuration parses a protobuf JSON duration such as "1.5s", an empty value is zero
+func parseJSONDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	seconds, ok := strings.CutSuffix(value, "s")
+	if !ok {
+		return 0, fmt.Errorf("invalid duration %q, expected seconds such as \"0.5s\"", value)
+	}
+	parsed, err := strconv.ParseFloat(seconds, 64)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid duration %q, expected seconds such as \"0.5s\"", value)
+	}
+	return time.Duration(parsed * float64(time.Second)), nil
+}
+
+// serviceConfigOptions returns the dial options for the service config, adding the hedging interceptor when any
+// method is hedged and counting the attempts made for every call
+func (gcd *GrpcConnection) serviceConfigOptions() ([]grpc.DialOption, error) {
+	opts := []grpc.DialOption{grpc.WithStatsHandler(attemptHandler{})}
+	js, err := gcd.serviceConfigJSON()
+	if err != nil || js == "" {
+		return opts, err
+	}
+	sc, err := parseServiceConfig(js)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, grpc.WithDefaultServiceConfig(js))
+
+	// methods match on service/method first, then on the service, then the config without a name
+	hedged := make(map[string]*hedgingPolicy)
+	hasHedging := false
+	for _, mc := range sc.MethodConfig {
+		for _, name := range mc.Name {
+			hedged[name.Service+"/"+name.Method] = mc.HedgingPolicy
+		}
+		hasHedging = hasHedging || mc.HedgingPolicy != nil
+	}
+	if hasHedging {
+		opts = append(opts, grpc.WithChainUnaryInterceptor((&hedger{policies: hedged}).intercept))
+	}
+	return opts, nil
+}
+
+type hedger struct {
+	policies map[string]*hedgingPolicy
+}
+
+func (h *hedger) policy(method string) *hedgingPolicy {
+	service, name, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	for _, key := range []string{service + "/" + name, service + "/", "/"} {
+		if policy, ok := h.policies[key]; ok {
+			return policy
+		}
+	}
+	return nil
+}
+
+type hedgeResult struct {
+	reply   proto.Message
+	header  metadata.MD
+	trailer metadata.MD
+	peer    peer.Peer
+	err     error
+}
+
+// intercept sends up to maxAttempts copies of a unary call, one every hedgingDelay. The first response or fatal
+// error is used and the other attempts are cancelled, a non fatal error starts the next attempt straight away
+func (h *hedger) intercept(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	policy := h.policy(method)
+	replyMsg, ok := reply.(proto.Message)
+	if policy == nil || !ok {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	if policy.timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, policy.timeout)
+		defer cancelTimeout()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// each attempt has its own reply and metadata, the header, trailer and peer options are filled from the one used
+	var attemptOpts []grpc.CallOption
+	var headers []*metadata.MD
+	var trailers []*metadata.MD
+	var peers []*peer.Peer
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case grpc.HeaderCallOption:
+			headers = append(headers, o.HeaderAddr)
+		case grpc.TrailerCallOption:
+			trailers = append(trailers, o.TrailerAddr)
+		case grpc.PeerCallOption:
+			peers = append(peers, o.PeerAddr)
+		default:
+			attemptOpts = append(attemptOpts, opt)
+		}
+	}
+
+	results := make(chan hedgeResult, policy.MaxAttempts)
+	started := 0
+	// grpc drops reserved grpc- headers from call metadata, so unlike its retries hedged attempts cannot send
+	// grpc-previous-rpc-attempts
+	attempt := func() {
+		started++
+		go func() {
+			r := hedgeResult{reply: replyMsg.ProtoReflect().New().Interface()}
+			callOpts := append(attemptOpts[:len(attemptOpts):len(attemptOpts)], grpc.Header(&r.header), grpc.Trailer(&r.trailer),
+				grpc.Peer(&r.peer))
+			r.err = invoker(ctx, method, req, r.reply, cc, callOpts...)
+			results <- r
+		}()
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	var last hedgeResult
+	for done := 0; done < policy.MaxAttempts; {
+		next := timer.C
+		if started == policy.MaxAttempts {
+			next = nil
+		}
+		select {
+		case <-next:
+			attempt()
+			timer.Reset(policy.delay)
+		case r := <-results:
+			done++
+			last = r
+			if r.err == nil || !policy.nonFatal(r.err) {
+				done = policy.MaxAttempts
+			} else if started < policy.MaxAttempts {
+				attempt()
+				timer.Reset(policy.delay)
+			}
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	for _, header := range headers {
+		*header = last.header
+	}
+	for _, trailer := range trailers {
+		*trailer = last.trailer
+	}
+	for _, p := range peers {
+		*p = last.peer
+	}
+	if last.err == nil {
+		proto.Reset(replyMsg)
+		proto.Merge(replyMsg, last.reply)
+	}
+	return last.err
+}
+
+func (p *hedgingPolicy) nonFatal(err error) bool {
+	code := status.Code(err)
+	for _, nonFatal := range p.NonFatalStatusCodes {
+		if code == nonFatal {
+			return true
+		}
+	}
+	return false
+}
+
+type attemptsKey struct{}
+
+// CountAttempts returns a context that counts the attempts made by calls using it, including retries and hedged
+// copies. Attempts grpc retries transparently because they never reached the server are not counted
+func CountAttempts(ctx context.Context) (context.Context, *atomic.Int32) {
+	attempts := &atomic.Int32{}
+	return context.WithValue(ctx, attemptsKey{}, attempts), attempts
+}
+
+func recordAttempt(ctx context.Context) {
+	if attempts, ok := ctx.Value(attemptsKey{}).(*atomic.Int32); ok {
+		attempts.Add(1)
+	}
+}
+
+// attemptHandler sees the start of every attempt grpc makes for a call
+type attemptHandler struct{}
+
+func (attemptHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (attemptHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if begin, ok := s.(*stats.Begin); ok && begin.Client && !begin.IsTransparentRetryAttempt {
+		recordAttempt(ctx)
+	}
+}
+
+func (attemptHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (attemptHandler) HandleConn(context.Context, stats.ConnStats) {}
@@ -0,0 +1,190 @@
+package proto
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"grpc_ui_tool/expand"
+	"grpc_ui_tool/route"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+// staticScheme is the target scheme used for a static list of addresses, they are handed to grpc by a manual resolver
+const staticScheme = "static"
+
+// LoadBalancingPolicies lists the policies that can be chosen for a server, the empty policy leaves grpc's default
+// of pick_first
+var LoadBalancingPolicies = []string{"", "pick_first", "round_robin"}
+
+// SetAddresses sets a static list of host:port addresses used instead of the hostname and port, and the load
+// balancing policy that spreads calls over them or over the addresses a dns:/// target resolves to
+func (gcd *GrpcConnection) SetAddresses(addresses []string, policy string) {
+	gcd.Addresses = addresses
+	gcd.LoadBalancing = policy
+}
+
+// Target returns the expanded target the connection dials. The hostname may be a full gRPC target such as
+// unix:///path, unix-abstract:name or dns:///host:port, in which case the port is ignored, or a host:port that
+// must agree with any port set. IPv6 literals are bracketed. A static address list is returned as static:///a,b
+func (gcd *GrpcConnection) Target() (string, error) {
+	if len(gcd.Addresses) > 0 {
+		addresses := make([]string, 0, len(gcd.Addresses))
+		for _, address := range gcd.Addresses {
+			expanded, err := expand.String(address, gcd.Variables)
+			if err != nil {
+				return "", fmt.Errorf("address: %w", err)
+			}
+			if _, _, err = net.SplitHostPort(expanded); err != nil {
+				return "", fmt.Errorf("address %q: %w", expanded, err)
+			}
+			addresses = append(addresses, expanded)
+		}
+		return staticScheme + ":///" + strings.Join(addresses, ","), nil
+	}
+
+	hostname, err := expand.String(gcd.Hostname, gcd.Variables)
+	if err != nil {
+		return "", fmt.Errorf("hostname: %w", err)
+	}
+	port, err := expand.String(gcd.Port, gcd.Variables)
+	if err != nil {
+		return "", fmt.Errorf("port: %w", err)
+	}
+	if isTargetURI(hostname) || port == "" {
+		return hostname, nil
+	}
+	// a hostname with its own port, such as [::1]:50051, is used as it is when the port agrees with it
+	if _, hostPort, err := net.SplitHostPort(hostname); err == nil {
+		if hostPort != port {
+			return "", fmt.Errorf("hostname %q has port %s but the port is set to %s", hostname, hostPort, port)
+		}
+		return hostname, nil
+	}
+	return net.JoinHostPort(strings.TrimSuffix(strings.TrimPrefix(hostname, "["), "]"), port), nil
+}
+
+// isTargetURI reports whether hostname is a target with a resolver scheme rather than a host name or address
+func isTargetURI(hostname string) bool {
+	if strings.Contains(hostname, "://") {
+		return true
+	}
+	for _, scheme := range []string{"unix:", "unix-abstract:", "dns:", "passthrough:"} {
+		if strings.HasPrefix(hostname, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// targetOptions returns the dial options needed for target, a manual resolver for static address lists. The load
+// balancing policy is part of the service config
+func (gcd *GrpcConnection) targetOptions(target string) []grpc.DialOption {
+	var opts []grpc.DialOption
+	if list, ok := strings.CutPrefix(target, staticScheme+":///"); ok {
+		var addresses []resolver.Address
+		for _, address := range strings.Split(list, ",") {
+			addresses = append(addresses, resolver.Address{Addr: address})
+		}
+		r := manual.NewBuilderWithScheme(staticScheme)
+		r.InitialState(resolver.State{Addresses: addresses})
+		opts = append(opts, grpc.WithResolvers(r))
+		// grpc would send the whole list as the authority and check certificates against it
+		if gcd.Tuning.Authority == "" {
+			opts = append(opts, grpc.WithAuthority(targetAuthority(target)))
+		}
+	}
+	return opts
+}
+
+// authority returns the authority calls to target are sent with and certificates are checked against, the override
+// or the target's endpoint. A static address list uses its first address, set an authority override when the
+// addresses are different hosts behind one certificate name
+func (gcd *GrpcConnection) authority(target string) string {
+	if gcd.Tuning.Authority != "" {
+		return gcd.Tuning.Authority
+	}
+	return targetAuthority(target)
+}
+
+// targetAuthority returns the authority grpc derives from target, except that a static address list gives its first
+// address rather than the list
+func targetAuthority(target string) string {
+	if list, ok := strings.CutPrefix(target, staticScheme+":///"); ok {
+		first, _, _ := strings.Cut(list, ",")
+		return first
+	}
+	if _, endpoint, found := strings.Cut(target, ":///"); found {
+		return endpoint
+	}
+	return target
+}
+
+// httpTarget returns the network, the addresses and the host to put in URLs for a target when calling it over
+// net/http, which has no resolvers of its own
+func httpTarget(target string) (string, []string, string, error) {
+	switch {
+	case strings.HasPrefix(target, staticScheme+":///"):
+		addresses := strings.Split(strings.TrimPrefix(target, staticScheme+":///"), ",")
+		return "tcp", addresses, addresses[0], nil
+	case strings.HasPrefix(target, "unix-abstract:"):
+		name := strings.TrimPrefix(strings.TrimPrefix(target, "unix-abstract:"), "//")
+		return "unix", []string{"@" + name}, "localhost", nil
+	case strings.HasPrefix(target, "unix:"):
+		path := strings.TrimPrefix(target, "unix:")
+		if strings.HasPrefix(path, "//") {
+			path = strings.TrimPrefix(path, "//")
+			if !strings.HasPrefix(path, "/") {
+				return "", nil, "", fmt.Errorf("target %q: unix:// targets need an absolute path", target)
+			}
+		}
+		return "unix", []string{path}, "localhost", nil
+	case strings.HasPrefix(target, "dns:"), strings.HasPrefix(target, "passthrough:"):
+		_, address, _ := strings.Cut(target, ":")
+		if strings.HasPrefix(address, "//") {
+			// skip the optional authority of the DNS server
+			address = address[2:]
+			address = address[strings.Index(address, "/")+1:]
+		}
+		return "tcp", []string{address}, address, nil
+	case strings.Contains(target, "://"):
+		return "", nil, "", fmt.Errorf("target %q: unsupported scheme for gRPC-Web and Connect", target)
+	}
+	return "tcp", []string{target}, target, nil
+}
+
+// addressDialer dials one of several addresses, round_robin starts each new connection at the next address while
+// other policies try them in order. Connections go through route when it is set
+type addressDialer struct {
+	network    string
+	addresses  []string
+	roundRobin bool
+	route      route.Dialer
+	next       atomic.Uint32
+	dialer     net.Dialer
+}
+
+func (d *addressDialer) DialContext(ctx context.Context, _ string, _ string) (net.Conn, error) {
+	start := 0
+	if d.roundRobin {
+		start = int(d.next.Add(1)-1) % len(d.addresses)
+	}
+	var lastErr error
+	dial := d.dialer.DialContext
+	if d.route != nil {
+		dial = d.route.DialContext
+	}
+	for i := range d.addresses {
+		conn, err := dial(ctx, d.network, d.addresses[(start+i)%len(d.addresses)])
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
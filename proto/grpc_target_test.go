@@ -0,0 +1,36 @@
+package proto
+
+import "testing"
+
+func TestTarget(t *testing.T) {
+	tests := []struct {
+		hostname string
+		port     string
+		want     string
+	}{
+		{"localhost", "50051", "localhost:50051"},
+		{"::1", "50051", "[::1]:50051"},
+		{"[::1]", "50051", "[::1]:50051"},
+		{"[::1]:50051", "", "[::1]:50051"},
+		{"[::1]:50051", "50051", "[::1]:50051"},
+		{"localhost:50051", "50051", "localhost:50051"},
+		{"dns:///host:443", "50051", "dns:///host:443"},
+		{"unix:///run/app.sock", "", "unix:///run/app.sock"},
+		{"[::1]:50051", "50052", ""},
+		{"localhost:50051", "50052", ""},
+	}
+	for _, tt := range tests {
+		gcd := NewGrpcConnection()
+		gcd.SetConnectionDetails(tt.hostname, tt.port, nil)
+		got, err := gcd.Target()
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Target(%q, %q) = %q, expected a conflicting port error", tt.hostname, tt.port, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Target(%q, %q) = %q, %v, want %q", tt.hostname, tt.port, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package proto
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/stats"
+)
+
+// Timing records when each phase of a call happened on the connection dialed for it, along with the sizes of the
+// request and response messages. Phases that did not happen, such as the TLS handshake of a plaintext call, are
+// left out. The server dialed and its handshake are kept too, for when the call fails before grpc knows its peer
+type Timing struct {
+	mu     sync.Mutex
+	start  time.Time
+	events map[string]time.Time
+	peer   PeerInfo
+	pinErr *PinMismatchError
+
+	Request  WireSize
+	Response WireSize
+}
+
+// WireSize is the size of a message serialized, after compression and as framed on the wire, the last includes
+// the gRPC message prefix and for gRPC-Web text the base64 encoding
+type WireSize struct {
+	Uncompressed int
+	Compressed   int
+	Wire         int
+}
+
+// Phase is a span of the call measured from the moment it started
+type Phase struct {
+	Name  string
+	Start time.Duration
+	End   time.Duration
+}
+
+const (
+	resolveStart   = "resolve start"
+	resolved       = "resolved"
+	connectStart   = "connect start"
+	connected      = "connected"
+	handshakeStart = "handshake start"
+	handshakeDone  = "handshake done"
+	requestStart   = "request start"
+	headersSent    = "headers sent"
+	firstByte      = "first byte"
+	callEnd        = "end"
+)
+
+func newTiming() *Timing {
+	return &Timing{start: time.Now(), events: make(map[string]time.Time)}
+}
+
+// mark records the first time an event happens, retries and hedged attempts repeat them
+func (t *Timing) mark(event string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.events[event]; !ok {
+		t.events[event] = time.Now()
+	}
+}
+
+// markLast records the latest time an event happens
+func (t *Timing) markLast(event string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events[event] = time.Now()
+}
+
+func (t *Timing) setSize(size *WireSize, uncompressed int, compressed int, wire int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*size = WireSize{Uncompressed: uncompressed, Compressed: compressed, Wire: wire}
+}
+
+func (t *Timing) setPeerAddress(address string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.peer.Address = address
+}
+
+// setHandshake records the TLS connection, when verification failed only the certificates the server sent are known
+func (t *Timing) setHandshake(state tls.ConnectionState, err error) {
+	var verifyErr *tls.CertificateVerificationError
+	if errors.As(err, &verifyErr) {
+		state.PeerCertificates = verifyErr.UnverifiedCertificates
+	} else if err != nil {
+		return
+	}
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.peer.TLS = &state
+	var pinErr *PinMismatchError
+	if errors.As(err, &pinErr) {
+		t.pinErr = pinErr
+	}
+}
+
+// pinMismatch returns the error of a handshake refused because the pinned certificate changed, which grpc only
+// reports as text
+func (t *Timing) pinMismatch() *PinMismatchError {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pinErr
+}
+
+// dialedPeer returns the server dialed for the call, nil when no connection was made
+func (t *Timing) dialedPeer() *PeerInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.peer.Address == "" && t.peer.TLS == nil {
+		return nil
+	}
+	dialed := t.peer
+	return &dialed
+}
+
+// Phases returns the waterfall of the call: name resolution, connecting, the TLS handshake, sending the request
+// headers, waiting for the first response byte and reading the rest of the response
+func (t *Timing) Phases() []Phase {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var phases []Phase
+	add := func(name string, from string, to string) {
+		start, ok := t.events[from]
+		end, ended := t.events[to]
+		if ok && ended {
+			phases = append(phases, Phase{Name: name, Start: start.Sub(t.start), End: end.Sub(t.start)})
+		}
+	}
+	add("Name resolution", resolveStart, resolved)
+	add("Connect", connectStart, connected)
+	add("TLS handshake", handshakeStart, handshakeDone)
+
+	// the request waits for the connection before its headers can be written
+	sendFrom := requestStart
+	for _, event := range []string{connected, handshakeDone} {
+		if at, ok := t.events[event]; ok && at.After(t.events[sendFrom]) {
+			sendFrom = event
+		}
+	}
+	add("Headers sent", sendFrom, headersSent)
+	add("First response byte", headersSent, firstByte)
+	add("End", firstByte, callEnd)
+	return phases
+}
+
+// Total returns the time from the start of dialing to the end of the call
+func (t *Timing) Total() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	end, ok := t.events[callEnd]
+	if !ok {
+		return 0
+	}
+	return end.Sub(t.start)
+}
+
+// timingHandler follows the calls on a native gRPC connection
+type timingHandler struct {
+	timing *Timing
+}
+
+func (h timingHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h timingHandler) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if !s.IsClient() {
+		return
+	}
+	switch event := s.(type) {
+	case *stats.Begin:
+		h.timing.mark(requestStart)
+	case *stats.OutHeader:
+		h.timing.mark(headersSent)
+	case *stats.OutPayload:
+		h.timing.setSize(&h.timing.Request, event.Length, event.CompressedLength, event.WireLength)
+	case *stats.InHeader:
+		h.timing.markLast(firstByte)
+	case *stats.InPayload:
+		h.timing.setSize(&h.timing.Response, event.Length, event.CompressedLength, event.WireLength)
+	case *stats.End:
+		h.timing.markLast(callEnd)
+	}
+}
+
+func (h timingHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h timingHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+// timedCredentials times the TLS handshake of the transport credentials
+type timedCredentials struct {
+	credentials.TransportCredentials
+	timing *Timing
+}
+
+func (c timedCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	if c.Info().SecurityProtocol != "tls" {
+		return c.TransportCredentials.ClientHandshake(ctx, authority, conn)
+	}
+	c.timing.mark(handshakeStart)
+	conn, info, err := c.TransportCredentials.ClientHandshake(ctx, authority, conn)
+	c.timing.mark(handshakeDone)
+	if tlsInfo, ok := info.(credentials.TLSInfo); ok {
+		c.timing.setHandshake(tlsInfo.State, nil)
+	} else {
+		c.timing.setHandshake(tls.ConnectionState{}, err)
+	}
+	return conn, info, err
+}
+
+func (c timedCredentials) Clone() credentials.TransportCredentials {
+	return timedCredentials{TransportCredentials: c.TransportCredentials.Clone(), timing: c.timing}
+}
+
+// timedResolver times the first resolution of the target's name
+type timedResolver struct {
+	resolver.Builder
+	timing *Timing
+}
+
+func (r timedResolver) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	r.timing.mark(resolveStart)
+	return r.Builder.Build(target, timedResolverConn{ClientConn: cc, timing: r.timing}, opts)
+}
+
+type timedResolverConn struct {
+	resolver.ClientConn
+	timing *Timing
+}
+
+func (c timedResolverConn) UpdateState(state resolver.State) error {
+	c.timing.mark(resolved)
+	return c.ClientConn.UpdateState(state)
+}
+
+// targetResolver returns the resolver grpc picks for target, which falls back to dns for targets without a
+// registered scheme
+func targetResolver(target string) resolver.Builder {
+	if u, err := url.Parse(target); err == nil {
+		if builder := resolver.Get(u.Scheme); builder != nil {
+			return builder
+		}
+	}
+	return resolver.Get("dns")
+}
+
+// proxyFromEnvironment reports whether grpc would dial through a proxy from the environment, which it skips when
+// given a dialer of its own
+func proxyFromEnvironment() bool {
+	return os.Getenv("HTTPS_PROXY") != "" || os.Getenv("https_proxy") != ""
+}
+
+// clientTrace times the phases of a gRPC-Web or Connect request
+func (t *Timing) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:     func(httptrace.DNSStartInfo) { t.mark(resolveStart) },
+		DNSDone:      func(httptrace.DNSDoneInfo) { t.mark(resolved) },
+		ConnectStart: func(string, string) { t.mark(connectStart) },
+		ConnectDone: func(_ string, address string, err error) {
+			t.mark(connected)
+			if err == nil {
+				t.setPeerAddress(address)
+			}
+		},
+		TLSHandshakeStart: func() { t.mark(handshakeStart) },
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			t.mark(handshakeDone)
+			t.setHandshake(state, err)
+		},
+		WroteHeaders:         func() { t.mark(headersSent) },
+		GotFirstResponseByte: func() { t.mark(firstByte) },
+	}
+}
@@ -0,0 +1,176 @@
+package proto
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+
+	"grpc_ui_tool/expand"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// TLSMode selects how the connection to the server is secured
+type TLSMode string
+
+const (
+	// TLSInsecure uses TLS without verifying the server certificate, it is the default for saved servers
+	TLSInsecure TLSMode = ""
+	// TLSVerify verifies the server certificate against the system roots, or CACert when it is set
+	TLSVerify TLSMode = "verify"
+	// TLSTrustOnFirstUse pins the server certificate seen on the first call and refuses any other afterwards
+	TLSTrustOnFirstUse TLSMode = "tofu"
+	// TLSPlaintext connects without TLS
+	TLSPlaintext TLSMode = "plaintext"
+)
+
+// TLSModes lists the modes in the order they are offered to the user
+var TLSModes = []TLSMode{TLSInsecure, TLSVerify, TLSTrustOnFirstUse, TLSPlaintext}
+
+// String returns a user facing name for the mode
+func (m TLSMode) String() string {
+	switch m {
+	case TLSInsecure:
+		return "TLS (skip verification)"
+	case TLSVerify:
+		return "TLS"
+	case TLSTrustOnFirstUse:
+		return "TLS (trust on first use)"
+	case TLSPlaintext:
+		return "Plaintext"
+	}
+	return string(m)
+}
+
+// ParseTLSMode parses the value stored in a server configuration
+func ParseTLSMode(value string) (TLSMode, error) {
+	for _, m := range TLSModes {
+		if string(m) == value {
+			return m, nil
+		}
+	}
+	return TLSInsecure, fmt.Errorf("unknown TLS mode %q", value)
+}
+
+// TLSConfig holds the transport security settings of a server, Pin is the fingerprint of the certificate trusted
+// on first use
+type TLSConfig struct {
+	Mode   TLSMode
+	CACert string
+	Pin    string
+}
+
+// PinMismatchError is returned when a server presents a different certificate from the one pinned on first use
+type PinMismatchError struct {
+	Pinned    string
+	Presented string
+}
+
+func (e *PinMismatchError) Error() string {
+	return fmt.Sprintf("the server certificate changed since it was trusted on first use, pinned %s but presented %s",
+		e.Pinned, e.Presented)
+}
+
+// SetTLS sets the transport security used for new connections
+func (gcd *GrpcConnection) SetTLS(cfg TLSConfig) {
+	gcd.TLS = cfg
+	gcd.pins.set(cfg.Pin)
+}
+
+// Pin trusts the certificate with the given fingerprint from now on, when trusting on first use
+func (gcd *GrpcConnection) Pin(pin string) {
+	gcd.TLS.Pin = pin
+	gcd.pins.set(pin)
+}
+
+// pinStore holds the fingerprint of the certificate trusted on first use, copies of a connection share it so the
+// first handshake of any connection, from a call, a benchmark, a collection run or the proxy, pins it for all of them
+type pinStore struct {
+	mu  sync.Mutex
+	pin string
+}
+
+func (s *pinStore) set(pin string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pin = pin
+}
+
+// trust returns the pinned fingerprint, pinning presented when nothing is pinned yet and reporting whether it did
+func (s *pinStore) trust(presented string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pin == "" {
+		s.pin = presented
+		return presented, true
+	}
+	return s.pin, false
+}
+
+func (gcd *GrpcConnection) transportCredentials() (credentials.TransportCredentials, error) {
+	tlsCfg, err := gcd.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsCfg == nil {
+		return insecure.NewCredentials(), nil
+	}
+	return credentials.NewTLS(tlsCfg), nil
+}
+
+// tlsConfig returns the TLS settings for the mode, nil for plaintext
+func (gcd *GrpcConnection) tlsConfig() (*tls.Config, error) {
+	switch gcd.TLS.Mode {
+	case TLSPlaintext:
+		return nil, nil
+	case TLSVerify:
+		tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+		if gcd.TLS.CACert != "" {
+			path, err := expand.String(gcd.TLS.CACert, gcd.Variables)
+			if err != nil {
+				return nil, fmt.Errorf("CA certificate: %w", err)
+			}
+			pem, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("CA certificate: %w", err)
+			}
+			tlsCfg.RootCAs = x509.NewCertPool()
+			if !tlsCfg.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("CA certificate %s contains no PEM certificates", path)
+			}
+		}
+		return tlsCfg, nil
+	case TLSTrustOnFirstUse:
+		pins, onPin := gcd.pins, gcd.OnPin
+		return &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: true,
+			// VerifyConnection still runs when verification is skipped, the first certificate seen is pinned
+			VerifyConnection: func(state tls.ConnectionState) error {
+				if len(state.PeerCertificates) == 0 {
+					return fmt.Errorf("the server presented no certificate to pin")
+				}
+				presented := CertificateFingerprint(state.PeerCertificates[0])
+				pin, first := pins.trust(presented)
+				if first && onPin != nil {
+					onPin(pin)
+				}
+				if presented != pin {
+					return &tls.CertificateVerificationError{
+						UnverifiedCertificates: state.PeerCertificates,
+						Err:                    &PinMismatchError{Pinned: pin, Presented: presented},
+					}
+				}
+				return nil
+			},
+		}, nil
+	default:
+		return &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: true,
+		}, nil
+	}
+}
@@ -0,0 +1,25 @@
+package proto
+
+import "testing"
+
+func TestPinStoreIsSharedByCopies(t *testing.T) {
+	gcd := NewGrpcConnection()
+	gcd.SetTLS(TLSConfig{Mode: TLSTrustOnFirstUse})
+	step := gcd.WithVariables(map[string]string{"step": "1"})
+
+	if pin, first := step.pins.trust("AA"); pin != "AA" || !first {
+		t.Fatalf("first certificate gave %q, %v", pin, first)
+	}
+	if pin, first := gcd.pins.trust("BB"); pin != "AA" || first {
+		t.Errorf("a certificate pinned by a copy was not kept, got %q, %v", pin, first)
+	}
+
+	gcd.Pin("CC")
+	if pin, _ := step.pins.trust("AA"); pin != "CC" {
+		t.Errorf("a trusted new certificate was not shared, got %q", pin)
+	}
+	gcd.SetTLS(TLSConfig{Mode: TLSTrustOnFirstUse})
+	if _, first := gcd.pins.trust("DD"); !first {
+		t.Error("new TLS settings kept the previous pin")
+	}
+}
@@ -0,0 +1,395 @@
+package proto
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"grpc_ui_tool/expand"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// B3Mode selects whether Zipkin B3 headers are sent alongside the W3C trace context
+type B3Mode string
+
+const (
+	// B3None sends only the W3C traceparent and tracestate headers, it is the default
+	B3None B3Mode = ""
+	// B3Single adds the single b3 header
+	B3Single B3Mode = "single"
+	// B3Multi adds the X-B3-TraceId, X-B3-SpanId, X-B3-ParentSpanId and X-B3-Sampled headers
+	B3Multi B3Mode = "multi"
+)
+
+// B3Modes lists the B3 modes in the order they are offered to the user
+var B3Modes = []B3Mode{B3None, B3Single, B3Multi}
+
+// String returns a user facing name for the B3 mode
+func (m B3Mode) String() string {
+	switch m {
+	case B3None:
+		return "None"
+	case B3Single:
+		return "Single header (b3)"
+	case B3Multi:
+		return "Multiple headers (X-B3-*)"
+	}
+	return string(m)
+}
+
+// ParseB3Mode parses the value stored in a server configuration
+func ParseB3Mode(value string) (B3Mode, error) {
+	for _, m := range B3Modes {
+		if string(m) == value {
+			return m, nil
+		}
+	}
+	return B3None, fmt.Errorf("unknown B3 mode %q", value)
+}
+
+// SpanExport selects where the client span of each call is written
+type SpanExport string
+
+const (
+	// ExportNone keeps spans in the tool, it is the default
+	ExportNone SpanExport = ""
+	// ExportFile appends each span to a file as an OTLP JSON line, as the collector's file exporter writes them
+	ExportFile SpanExport = "file"
+	// ExportCollector posts each span to an OTLP/HTTP traces endpoint as JSON
+	ExportCollector SpanExport = "collector"
+)
+
+// SpanExports lists the span exports in the order they are offered to the user
+var SpanExports = []SpanExport{ExportNone, ExportFile, ExportCollector}
+
+// String returns a user facing name for the span export
+func (e SpanExport) String() string {
+	switch e {
+	case ExportNone:
+		return "None"
+	case ExportFile:
+		return "OTLP JSON file"
+	case ExportCollector:
+		return "OTLP/HTTP collector"
+	}
+	return string(e)
+}
+
+// ParseSpanExport parses the value stored in a server configuration
+func ParseSpanExport(value string) (SpanExport, error) {
+	for _, e := range SpanExports {
+		if string(e) == value {
+			return e, nil
+		}
+	}
+	return ExportNone, fmt.Errorf("unknown span export %q", value)
+}
+
+// DefaultCollector is the traces endpoint of an OpenTelemetry collector running locally with its default ports
+const DefaultCollector = "http://localhost:4318/v1/traces"
+
+// collectorTimeout bounds how long a call waits for the collector, a slow collector must not hold up the result
+const collectorTimeout = 5 * time.Second
+
+// TraceConfig holds how calls to a server are traced. Every call sent from the request view or a collection
+// starts a span whose W3C trace context is sent in the traceparent header
+type TraceConfig struct {
+	B3 B3Mode
+	// TraceState is sent as the tracestate header of calls that start a new trace
+	TraceState string
+	Export     SpanExport
+	// Destination is the file or collector URL spans are exported to, variables are expanded.
+	// An empty collector URL uses DefaultCollector
+	Destination string
+}
+
+// SetTrace sets how calls are traced and where their spans are exported
+func (gcd *GrpcConnection) SetTrace(cfg TraceConfig) {
+	gcd.Trace = cfg
+}
+
+// TraceContext identifies the client span of a call, IDs are lower case hex as they appear in traceparent
+type TraceContext struct {
+	TraceID string
+	SpanID  string
+	// ParentSpanID is set when the request metadata already carried a traceparent, the call then joins that trace
+	ParentSpanID string
+	TraceState   string
+	Sampled      bool
+}
+
+// traceparentPattern matches the fields of a traceparent header that every version shares
+var traceparentPattern = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})(-.*)?$`)
+
+// newTraceContext starts the client span of a call, as a child of the traceparent in md when it holds a valid one
+func newTraceContext(md metadata.MD, cfg TraceConfig) (TraceContext, error) {
+	spanID, err := randomHex(8)
+	if err != nil {
+		return TraceContext{}, err
+	}
+	trace := TraceContext{SpanID: spanID, Sampled: true}
+	if parent := md.Get("traceparent"); len(parent) == 1 {
+		if fields := traceparentPattern.FindStringSubmatch(strings.TrimSpace(parent[0])); fields != nil &&
+			fields[1] != "ff" && (fields[1] != "00" || fields[5] == "") && !allZero(fields[2]) && !allZero(fields[3]) {
+			flags, _ := strconv.ParseUint(fields[4], 16, 8)
+			trace.TraceID = fields[2]
+			trace.ParentSpanID = fields[3]
+			trace.Sampled = flags&1 == 1
+			trace.TraceState = strings.Join(md.Get("tracestate"), ",")
+			return trace, nil
+		}
+	}
+	if trace.TraceID, err = randomHex(16); err != nil {
+		return TraceContext{}, err
+	}
+	trace.TraceState = cfg.TraceState
+	return trace, nil
+}
+
+// traceparent formats the W3C traceparent header of the span
+func (t TraceContext) traceparent() string {
+	flags := "00"
+	if t.Sampled {
+		flags = "01"
+	}
+	return "00-" + t.TraceID + "-" + t.SpanID + "-" + flags
+}
+
+// inject sets the trace headers of the span in md, replacing any the request already had
+func (t TraceContext) inject(md metadata.MD, mode B3Mode) {
+	md.Set("traceparent", t.traceparent())
+	if t.TraceState != "" {
+		md.Set("tracestate", t.TraceState)
+	} else {
+		delete(md, "tracestate")
+	}
+	sampled := "0"
+	if t.Sampled {
+		sampled = "1"
+	}
+	switch mode {
+	case B3Single:
+		b3 := t.TraceID + "-" + t.SpanID + "-" + sampled
+		if t.ParentSpanID != "" {
+			b3 += "-" + t.ParentSpanID
+		}
+		md.Set("b3", b3)
+	case B3Multi:
+		md.Set("x-b3-traceid", t.TraceID)
+		md.Set("x-b3-spanid", t.SpanID)
+		if t.ParentSpanID != "" {
+			md.Set("x-b3-parentspanid", t.ParentSpanID)
+		}
+		md.Set("x-b3-sampled", sampled)
+	}
+}
+
+// startTrace starts the client span of the call and adds its headers to the metadata that is shown and sent
+func (call *Call) startTrace(cfg TraceConfig) (TraceContext, error) {
+	trace, err := newTraceContext(call.Metadata, cfg)
+	if err != nil {
+		return TraceContext{}, err
+	}
+	md := call.Metadata.Copy()
+	trace.inject(md, cfg.B3)
+	outgoing := call.outgoing.Copy()
+	trace.inject(outgoing, cfg.B3)
+	call.Metadata, call.outgoing = md, outgoing
+	return trace, nil
+}
+
+func randomHex(size int) (string, error) {
+	for {
+		id := make([]byte, size)
+		if _, err := rand.Read(id); err != nil {
+			return "", err
+		}
+		// an all zero ID is invalid in both W3C and B3
+		if encoded := hex.EncodeToString(id); !allZero(encoded) {
+			return encoded, nil
+		}
+	}
+}
+
+func allZero(id string) bool {
+	return strings.Trim(id, "0") == ""
+}
+
+// exportSpan writes the client span of a finished call to the configured file or collector
+func (gcd *GrpcConnection) exportSpan(call *Call, response *Response, start time.Time, callErr error) error {
+	cfg := gcd.Trace
+	if cfg.Export == ExportNone || response.Trace == nil || !response.Trace.Sampled {
+		return nil
+	}
+	destination, err := expand.String(cfg.Destination, gcd.Variables)
+	if err != nil {
+		return fmt.Errorf("span destination: %w", err)
+	}
+	data, err := json.Marshal(gcd.otlpSpans(call, response, start, callErr))
+	if err != nil {
+		return err
+	}
+
+	switch cfg.Export {
+	case ExportFile:
+		if destination == "" {
+			return fmt.Errorf("no span file set")
+		}
+		file, err := os.OpenFile(destination, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		if _, err = file.Write(append(data, '\n')); err != nil {
+			_ = file.Close()
+			return err
+		}
+		return file.Close()
+	case ExportCollector:
+		if destination == "" {
+			destination = DefaultCollector
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), collectorTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, destination, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			return fmt.Errorf("collector %s: %s %s", destination, resp.Status, strings.TrimSpace(string(body)))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown span export %q", cfg.Export)
+}
+
+// otlpExport is an ExportTraceServiceRequest in the OTLP JSON encoding, IDs are hex and 64 bit integers strings
+type otlpExport struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	TraceState        string          `json:"traceState,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	otlpSpanKindClient  = 3
+	otlpStatusCodeError = 2
+)
+
+func stringAttribute(key string, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: &value}}
+}
+
+func intAttribute(key string, value int64) otlpAttribute {
+	text := strconv.FormatInt(value, 10)
+	return otlpAttribute{Key: key, Value: otlpValue{IntValue: &text}}
+}
+
+// otlpSpans describes the call as a client span with the OpenTelemetry RPC semantic conventions
+func (gcd *GrpcConnection) otlpSpans(call *Call, response *Response, start time.Time, callErr error) otlpExport {
+	trace := response.Trace
+	name := strings.TrimPrefix(call.Method, "/")
+	service, method, _ := strings.Cut(name, "/")
+	code := status.Code(callErr)
+	attributes := []otlpAttribute{
+		stringAttribute("rpc.system", "grpc"),
+		stringAttribute("rpc.service", service),
+		stringAttribute("rpc.method", method),
+		intAttribute("rpc.grpc.status_code", int64(code)),
+	}
+	if host, port, err := net.SplitHostPort(gcd.authority(call.Target)); err == nil {
+		attributes = append(attributes, stringAttribute("server.address", host))
+		if number, err := strconv.ParseInt(port, 10, 64); err == nil {
+			attributes = append(attributes, intAttribute("server.port", number))
+		}
+	}
+	if response.Peer != nil {
+		if host, port, err := net.SplitHostPort(response.Peer.Address); err == nil {
+			attributes = append(attributes, stringAttribute("network.peer.address", host))
+			if number, err := strconv.ParseInt(port, 10, 64); err == nil {
+				attributes = append(attributes, intAttribute("network.peer.port", number))
+			}
+		}
+	}
+
+	span := otlpSpan{
+		TraceID:           trace.TraceID,
+		SpanID:            trace.SpanID,
+		ParentSpanID:      trace.ParentSpanID,
+		TraceState:        trace.TraceState,
+		Name:              name,
+		Kind:              otlpSpanKindClient,
+		StartTimeUnixNano: strconv.FormatInt(start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(start.Add(response.Duration).UnixNano(), 10),
+		Attributes:        attributes,
+	}
+	if callErr != nil {
+		span.Status = otlpStatus{Code: otlpStatusCodeError, Message: status.Convert(callErr).Message()}
+	}
+	return otlpExport{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttribute{stringAttribute("service.name", "grpc_ui_tool")}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "grpc_ui_tool"}, Spans: []otlpSpan{span}}},
+	}}}
+}
@@ -0,0 +1,763 @@
+package proto
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Protocol selects the wire protocol calls are sent with
+type Protocol string
+
+const (
+	// ProtocolGRPC is native gRPC over HTTP/2, it is the default for saved servers
+	ProtocolGRPC Protocol = ""
+	// ProtocolGRPCWeb is gRPC-Web with binary framing, as served by Envoy's grpc_web filter
+	ProtocolGRPCWeb Protocol = "grpc-web"
+	// ProtocolGRPCWebText is gRPC-Web with base64 encoded framing
+	ProtocolGRPCWebText Protocol = "grpc-web-text"
+	// ProtocolConnect is the Connect protocol's unary form, a plain HTTP POST of the message
+	ProtocolConnect Protocol = "connect"
+	// ProtocolConnectStream is the Connect protocol's streaming form with enveloped messages, usable for every method
+	ProtocolConnectStream Protocol = "connect-stream"
+)
+
+// Protocols lists the protocols in the order they are offered to the user
+var Protocols = []Protocol{ProtocolGRPC, ProtocolGRPCWeb, ProtocolGRPCWebText, ProtocolConnect, ProtocolConnectStream}
+
+// String returns a user facing name for the protocol
+func (p Protocol) String() string {
+	switch p {
+	case ProtocolGRPC:
+		return "gRPC"
+	case ProtocolGRPCWeb:
+		return "gRPC-Web"
+	case ProtocolGRPCWebText:
+		return "gRPC-Web (text)"
+	case ProtocolConnect:
+		return "Connect (unary)"
+	case ProtocolConnectStream:
+		return "Connect (streaming)"
+	}
+	return string(p)
+}
+
+// ParseProtocol parses the value stored in a server configuration
+func ParseProtocol(value string) (Protocol, error) {
+	for _, p := range Protocols {
+		if string(p) == value {
+			return p, nil
+		}
+	}
+	return ProtocolGRPC, fmt.Errorf("unknown protocol %q", value)
+}
+
+// Codec selects how messages are encoded by the Connect protocol
+type Codec string
+
+const (
+	// CodecProto encodes messages in the protobuf binary format, it is the default
+	CodecProto Codec = ""
+	// CodecJSON encodes messages as protobuf JSON
+	CodecJSON Codec = "json"
+)
+
+// Codecs lists the codecs in the order they are offered to the user
+var Codecs = []Codec{CodecProto, CodecJSON}
+
+// String returns a user facing name for the codec
+func (c Codec) String() string {
+	switch c {
+	case CodecProto:
+		return "Protobuf"
+	case CodecJSON:
+		return "JSON"
+	}
+	return string(c)
+}
+
+// ParseCodec parses the value stored in a server configuration
+func ParseCodec(value string) (Codec, error) {
+	for _, c := range Codecs {
+		if string(c) == value {
+			return c, nil
+		}
+	}
+	return CodecProto, fmt.Errorf("unknown codec %q", value)
+}
+
+// TransportConfig holds the protocol settings of a server, Codec only applies to the Connect protocols
+type TransportConfig struct {
+	Protocol Protocol
+	Codec    Codec
+}
+
+// SetTransport sets the protocol used for new connections
+func (gcd *GrpcConnection) SetTransport(cfg TransportConfig) {
+	gcd.Transport = cfg
+}
+
+// NativeOnlySettings names the settings that are set but ignored because the protocol is not native gRPC
+func (gcd *GrpcConnection) NativeOnlySettings() []string {
+	if gcd.Transport.Protocol == ProtocolGRPC {
+		return nil
+	}
+	var settings []string
+	if gcd.ServiceConfig != "" {
+		settings = append(settings, "the service config's retry and hedging policies, timeouts and load balancing")
+	}
+	tuning := gcd.Tuning
+	if tuning.KeepaliveTime > 0 || tuning.KeepaliveTimeout > 0 || tuning.KeepaliveWithoutCalls {
+		settings = append(settings, "keepalive pings")
+	}
+	if tuning.InitialWindowSize > 0 || tuning.InitialConnWindowSize > 0 {
+		settings = append(settings, "initial flow control window sizes")
+	}
+	if tuning.WaitForReady {
+		settings = append(settings, "wait for ready")
+	}
+	return settings
+}
+
+// Conn sends unary calls to a server, it is either a native gRPC client connection or an HTTP client speaking
+// gRPC-Web or Connect. Headers and trailers are returned through grpc.Header and grpc.Trailer call options
+type Conn interface {
+	Invoke(ctx context.Context, method string, args any, reply any, opts ...grpc.CallOption) error
+	Close() error
+}
+
+// interceptedConn runs unary interceptors around the calls of a connection that has none of its own
+type interceptedConn struct {
+	Conn
+	interceptors []grpc.UnaryClientInterceptor
+}
+
+func (c interceptedConn) Invoke(ctx context.Context, method string, args any, reply any, opts ...grpc.CallOption) error {
+	return c.invoke(0, ctx, method, args, reply, opts...)
+}
+
+func (c interceptedConn) invoke(i int, ctx context.Context, method string, args any, reply any, opts ...grpc.CallOption) error {
+	if i == len(c.interceptors) {
+		return c.Conn.Invoke(ctx, method, args, reply, opts...)
+	}
+	return c.interceptors[i](ctx, method, args, reply, nil,
+		func(ctx context.Context, method string, req any, reply any, _ *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return c.invoke(i+1, ctx, method, req, reply, opts...)
+		}, opts...)
+}
+
+// httpConn sends calls with the gRPC-Web or Connect protocols over net/http, which also works through HTTP/1.1
+// proxies that cannot carry native gRPC
+type httpConn struct {
+	baseURL  string
+	protocol Protocol
+	codec    Codec
+	client   *http.Client
+	creds    grpcCredentials
+	resolver *protoregistry.Files
+	tuning   TuningConfig
+	timing   *Timing
+}
+
+// grpcCredentials is the part of credentials.PerRPCCredentials used to add authentication headers
+type grpcCredentials interface {
+	GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error)
+}
+
+// dialHTTP creates a connection for the gRPC-Web and Connect protocols
+func (gcd *GrpcConnection) dialHTTP(target string, creds grpcCredentials, timing *Timing) (Conn, error) {
+	tlsCfg, err := gcd.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	network, addresses, host, err := httpTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	dialer, err := gcd.getRouteDialer()
+	if err != nil {
+		return nil, err
+	}
+	scheme := "https"
+	if tlsCfg == nil {
+		scheme = "http"
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsCfg
+	if dialer != nil {
+		// the route replaces any proxy from the environment
+		transport.Proxy = nil
+	}
+	transport.DialContext = (&addressDialer{
+		network:    network,
+		addresses:  addresses,
+		roundRobin: gcd.LoadBalancing == "round_robin",
+		route:      dialer,
+	}).DialContext
+	return &httpConn{
+		baseURL:  scheme + "://" + host,
+		protocol: gcd.Transport.Protocol,
+		codec:    gcd.Transport.Codec,
+		client:   &http.Client{Transport: transport},
+		creds:    creds,
+		resolver: gcd.FileRegistry,
+		tuning:   gcd.Tuning,
+		timing:   timing,
+	}, nil
+}
+
+// Close releases idle connections
+func (c *httpConn) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+// Invoke sends one request message and reads one response message
+func (c *httpConn) Invoke(ctx context.Context, method string, args any, reply any, opts ...grpc.CallOption) error {
+	recordAttempt(ctx)
+	// like grpc every header and trailer option is filled, the logs add their own to the caller's
+	var header, trailer []*metadata.MD
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case grpc.HeaderCallOption:
+			header = append(header, o.HeaderAddr)
+		case grpc.TrailerCallOption:
+			trailer = append(trailer, o.TrailerAddr)
+		}
+	}
+	req, ok := args.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "request is not a protobuf message")
+	}
+	resp, ok := reply.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "response is not a protobuf message")
+	}
+
+	body, err := c.marshal(req)
+	if err != nil {
+		return status.Errorf(codes.Internal, "marshal request: %v", err)
+	}
+	uncompressed := len(body)
+	if c.tuning.MaxSendSize > 0 && len(body) > c.tuning.MaxSendSize {
+		return status.Errorf(codes.ResourceExhausted, "trying to send message larger than max (%d vs. %d)", len(body), c.tuning.MaxSendSize)
+	}
+	var flags byte
+	if c.tuning.Compression == "gzip" {
+		if body, err = gzipCompress(body); err != nil {
+			return status.Errorf(codes.Internal, "compress request: %v", err)
+		}
+		flags = 0x01
+	}
+	compressed := len(body)
+	if c.protocol != ProtocolConnect {
+		body = frame(flags, body)
+	}
+	if c.protocol == ProtocolGRPCWebText {
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+	if c.timing != nil {
+		c.timing.setSize(&c.timing.Request, uncompressed, compressed, len(body))
+		ctx = httptrace.WithClientTrace(ctx, c.timing.clientTrace())
+		c.timing.mark(requestStart)
+		defer c.timing.markLast(callEnd)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+method, bytes.NewReader(body))
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if err = c.setHeaders(ctx, httpReq, method); err != nil {
+		return err
+	}
+	if c.tuning.Authority != "" {
+		httpReq.Host = c.tuning.Authority
+	}
+
+	httpResp, err := c.client.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	defer httpResp.Body.Close()
+	// the body is read whole, so like grpc a response larger than the receive limit is refused before it is all read
+	bodyLimit := int64(c.receiveLimit()) + responseOverhead
+	if c.protocol == ProtocolGRPCWebText {
+		// base64 is a third larger, with room for padded chunks and line breaks
+		bodyLimit *= 2
+	}
+	respBody, err := io.ReadAll(io.LimitReader(httpResp.Body, bodyLimit+1))
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	if int64(len(respBody)) > bodyLimit {
+		return status.Errorf(codes.ResourceExhausted, "grpc: received response larger than max (more than %d bytes)", bodyLimit)
+	}
+	if c.timing != nil {
+		c.timing.setSize(&c.timing.Response, 0, 0, len(respBody))
+	}
+
+	headers, trailers := headerMetadata(httpResp.Header)
+	for _, h := range header {
+		*h = headers
+	}
+	switch c.protocol {
+	case ProtocolConnect:
+		err = c.readConnectUnary(httpResp, respBody, resp)
+	case ProtocolConnectStream:
+		err = c.readConnectStream(httpResp, respBody, resp, &trailers)
+	default:
+		err = c.readGRPCWeb(httpResp, respBody, resp, &trailers)
+	}
+	for _, t := range trailer {
+		*t = trailers
+	}
+	return err
+}
+
+func (c *httpConn) setHeaders(ctx context.Context, req *http.Request, method string) error {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for key, values := range md {
+		for _, value := range values {
+			if strings.HasSuffix(key, "-bin") {
+				value = base64.RawStdEncoding.EncodeToString([]byte(value))
+			}
+			req.Header.Add(key, value)
+		}
+	}
+	if c.creds != nil {
+		// the audience is the service URL, as grpc passes it to per call credentials
+		service := method[:strings.LastIndex(method, "/")]
+		authMD, err := c.creds.GetRequestMetadata(ctx, c.baseURL+service)
+		if err != nil {
+			return status.Errorf(codes.Unauthenticated, "credentials: %v", err)
+		}
+		for key, value := range authMD {
+			req.Header.Set(key, value)
+		}
+	}
+
+	userAgent := defaultUserAgent
+	if c.tuning.UserAgent != "" {
+		userAgent = c.tuning.UserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+	compressed := c.tuning.Compression != ""
+	deadline, hasDeadline := ctx.Deadline()
+	timeout := time.Until(deadline)
+	switch c.protocol {
+	case ProtocolGRPCWeb, ProtocolGRPCWebText:
+		contentType := "application/grpc-web+proto"
+		if c.protocol == ProtocolGRPCWebText {
+			contentType = "application/grpc-web-text+proto"
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", contentType)
+		req.Header.Set("X-Grpc-Web", "1")
+		req.Header.Set("X-User-Agent", userAgent)
+		req.Header.Set("Grpc-Accept-Encoding", "gzip")
+		if compressed {
+			req.Header.Set("Grpc-Encoding", c.tuning.Compression)
+		}
+		if hasDeadline {
+			req.Header.Set("Grpc-Timeout", strconv.FormatInt(max(timeout.Milliseconds(), 1), 10)+"m")
+		}
+	case ProtocolConnect:
+		req.Header.Set("Content-Type", "application/"+c.codecName())
+		req.Header.Set("Connect-Protocol-Version", "1")
+		// asking for gzip explicitly stops net/http from decompressing the response itself
+		req.Header.Set("Accept-Encoding", "gzip")
+		if compressed {
+			req.Header.Set("Content-Encoding", c.tuning.Compression)
+		}
+		if hasDeadline {
+			req.Header.Set("Connect-Timeout-Ms", strconv.FormatInt(max(timeout.Milliseconds(), 1), 10))
+		}
+	case ProtocolConnectStream:
+		req.Header.Set("Content-Type", "application/connect+"+c.codecName())
+		req.Header.Set("Connect-Protocol-Version", "1")
+		req.Header.Set("Connect-Accept-Encoding", "gzip")
+		if compressed {
+			req.Header.Set("Connect-Content-Encoding", c.tuning.Compression)
+		}
+		if hasDeadline {
+			req.Header.Set("Connect-Timeout-Ms", strconv.FormatInt(max(timeout.Milliseconds(), 1), 10))
+		}
+	}
+	return nil
+}
+
+func (c *httpConn) codecName() string {
+	if c.codec == CodecJSON {
+		return "json"
+	}
+	return "proto"
+}
+
+func (c *httpConn) marshal(m proto.Message) ([]byte, error) {
+	if c.codec == CodecJSON && (c.protocol == ProtocolConnect || c.protocol == ProtocolConnectStream) {
+		return protojson.MarshalOptions{Resolver: c.types()}.Marshal(m)
+	}
+	return proto.Marshal(m)
+}
+
+func (c *httpConn) unmarshal(data []byte, m proto.Message) error {
+	if c.codec == CodecJSON && (c.protocol == ProtocolConnect || c.protocol == ProtocolConnectStream) {
+		return protojson.UnmarshalOptions{DiscardUnknown: true, Resolver: c.types()}.Unmarshal(data, m)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// types resolves google.protobuf.Any contents from the loaded proto files
+func (c *httpConn) types() *dynamicpb.Types {
+	if c.resolver == nil {
+		return dynamicpb.NewTypes(protoregistry.GlobalFiles)
+	}
+	return dynamicpb.NewTypes(c.resolver)
+}
+
+// readGRPCWeb reads the message frames and the trailer frame of a gRPC-Web response, a trailers only response carries
+// its status in the headers instead
+func (c *httpConn) readGRPCWeb(httpResp *http.Response, body []byte, resp proto.Message, trailers *metadata.MD) error {
+	if c.protocol == ProtocolGRPCWebText {
+		var err error
+		if body, err = decodeWebText(body); err != nil {
+			return status.Errorf(codes.Internal, "decode grpc-web-text response: %v", err)
+		}
+	}
+	if httpResp.StatusCode != http.StatusOK && httpResp.Header.Get("Grpc-Status") == "" {
+		return httpStatusError(httpResp, body)
+	}
+
+	received := 0
+	for len(body) > 0 {
+		flags, message, rest, err := unframe(body)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		body = rest
+		if flags&0x80 != 0 {
+			// the trailer frame holds HTTP/1 style header lines
+			reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(message, '\r', '\n'))))
+			fields, err := reader.ReadMIMEHeader()
+			if err != nil && err != io.EOF {
+				return status.Errorf(codes.Internal, "read trailers: %v", err)
+			}
+			for key, values := range headerMD(http.Header(fields)) {
+				(*trailers)[key] = append((*trailers)[key], values...)
+			}
+			continue
+		}
+		if message, err = c.decode(flags, message, httpResp.Header.Get("Grpc-Encoding")); err != nil {
+			return err
+		}
+		received++
+		if received == 1 {
+			if err = proto.Unmarshal(message, resp); err != nil {
+				return status.Errorf(codes.Internal, "unmarshal response: %v", err)
+			}
+		}
+	}
+
+	if err := grpcStatus(*trailers); err != nil {
+		return err
+	}
+	return checkReceived(received)
+}
+
+// readConnectUnary reads a Connect unary response, errors are a JSON body with a non 200 status and trailers are
+// sent as Trailer- prefixed headers
+func (c *httpConn) readConnectUnary(httpResp *http.Response, body []byte, resp proto.Message) error {
+	if httpResp.StatusCode != http.StatusOK {
+		return connectError(httpResp, body)
+	}
+	var flags byte
+	encoding := httpResp.Header.Get("Content-Encoding")
+	if encoding != "" && encoding != "identity" {
+		flags = 0x01
+	}
+	body, err := c.decode(flags, body, encoding)
+	if err != nil {
+		return err
+	}
+	if err = c.unmarshal(body, resp); err != nil {
+		return status.Errorf(codes.Internal, "unmarshal response: %v", err)
+	}
+	return nil
+}
+
+// readConnectStream reads the enveloped messages of a Connect streaming response, the final envelope holds
+// the error and trailers as JSON
+func (c *httpConn) readConnectStream(httpResp *http.Response, body []byte, resp proto.Message, trailers *metadata.MD) error {
+	if httpResp.StatusCode != http.StatusOK {
+		return connectError(httpResp, body)
+	}
+	received := 0
+	for len(body) > 0 {
+		flags, message, rest, err := unframe(body)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		body = rest
+		if flags&0x02 != 0 {
+			var end struct {
+				Error    *connectErrorBody   `json:"error"`
+				Metadata map[string][]string `json:"metadata"`
+			}
+			if err = json.Unmarshal(message, &end); err != nil {
+				return status.Errorf(codes.Internal, "read end of stream: %v", err)
+			}
+			for key, values := range headerMD(end.Metadata) {
+				(*trailers)[key] = append((*trailers)[key], values...)
+			}
+			if end.Error != nil {
+				return end.Error.err()
+			}
+			return checkReceived(received)
+		}
+		if message, err = c.decode(flags, message, httpResp.Header.Get("Connect-Content-Encoding")); err != nil {
+			return err
+		}
+		received++
+		if received == 1 {
+			if err = c.unmarshal(message, resp); err != nil {
+				return status.Errorf(codes.Internal, "unmarshal response: %v", err)
+			}
+		}
+	}
+	return status.Errorf(codes.Internal, "stream ended without an end of stream message")
+}
+
+// decode decompresses a received message when its flags mark it compressed and checks it against the receive limit
+func (c *httpConn) decode(flags byte, message []byte, encoding string) ([]byte, error) {
+	limit := c.receiveLimit()
+	compressed := len(message)
+	if flags&0x01 != 0 {
+		if encoding != "gzip" {
+			return nil, status.Errorf(codes.Internal, "received a message compressed with unsupported encoding %q", encoding)
+		}
+		decompressed, err := gzipDecompress(message, limit)
+		if err != nil {
+			if _, ok := status.FromError(err); ok {
+				return nil, err
+			}
+			return nil, status.Errorf(codes.Internal, "decompress response: %v", err)
+		}
+		message = decompressed
+	}
+	if c.timing != nil {
+		c.timing.setSize(&c.timing.Response, len(message), compressed, c.timing.Response.Wire)
+	}
+	if len(message) > limit {
+		return nil, status.Errorf(codes.ResourceExhausted, "grpc: received message larger than max (%d vs. %d)", len(message), limit)
+	}
+	return message, nil
+}
+
+// receiveLimit is the largest response message accepted
+func (c *httpConn) receiveLimit() int {
+	if c.tuning.MaxReceiveSize > 0 {
+		return c.tuning.MaxReceiveSize
+	}
+	return defaultMaxReceiveSize
+}
+
+func checkReceived(received int) error {
+	switch {
+	case received == 0:
+		return status.Errorf(codes.Internal, "no response message received for a unary call")
+	case received > 1:
+		return status.Errorf(codes.Internal, "received %d response messages for a unary call", received)
+	}
+	return nil
+}
+
+// frame prefixes a message with its flags and length as gRPC-Web and Connect streaming do
+func frame(flags byte, message []byte) []byte {
+	framed := make([]byte, 5+len(message))
+	framed[0] = flags
+	binary.BigEndian.PutUint32(framed[1:5], uint32(len(message)))
+	copy(framed[5:], message)
+	return framed
+}
+
+func unframe(data []byte) (byte, []byte, []byte, error) {
+	if len(data) < 5 {
+		return 0, nil, nil, fmt.Errorf("truncated message frame")
+	}
+	length := binary.BigEndian.Uint32(data[1:5])
+	if uint64(len(data)-5) < uint64(length) {
+		return 0, nil, nil, fmt.Errorf("truncated message frame, expected %d bytes but %d remain", length, len(data)-5)
+	}
+	return data[0], data[5 : 5+length], data[5+length:], nil
+}
+
+// decodeWebText decodes a grpc-web-text body, servers may send several separately padded base64 chunks
+func decodeWebText(body []byte) ([]byte, error) {
+	text := strings.Join(strings.Fields(string(body)), "")
+	var decoded []byte
+	for text != "" {
+		end := len(text)
+		if i := strings.IndexByte(text, '='); i >= 0 {
+			end = i
+			for end < len(text) && text[end] == '=' {
+				end++
+			}
+		}
+		chunk, err := base64.StdEncoding.DecodeString(text[:end])
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, chunk...)
+		text = text[end:]
+	}
+	return decoded, nil
+}
+
+// headerMetadata splits response headers into metadata and trailers, which are the Trailer- prefixed headers of
+// Connect unary responses and the status of gRPC-Web trailers only responses. Transport headers are left out
+func headerMetadata(header http.Header) (metadata.MD, metadata.MD) {
+	headers, trailers := metadata.MD{}, metadata.MD{}
+	for key, values := range headerMD(header) {
+		if name, ok := strings.CutPrefix(key, "trailer-"); ok {
+			trailers[name] = append(trailers[name], values...)
+			continue
+		}
+		switch key {
+		case "grpc-status", "grpc-message", "grpc-status-details-bin":
+			trailers[key] = values
+			continue
+		case "content-length", "connection", "date", "transfer-encoding", "vary", "access-control-expose-headers":
+			continue
+		}
+		headers[key] = values
+	}
+	return headers, trailers
+}
+
+// headerMD converts HTTP headers into metadata, -bin values are decoded to the raw bytes grpc would return
+func headerMD(header map[string][]string) metadata.MD {
+	md := metadata.MD{}
+	for key, values := range header {
+		key = strings.ToLower(key)
+		for _, value := range values {
+			if strings.HasSuffix(key, "-bin") {
+				if decoded, err := DecodeBinaryValue(value); err == nil {
+					value = string(decoded)
+				}
+			}
+			md[key] = append(md[key], value)
+		}
+	}
+	return md
+}
+
+// grpcStatus returns the error described by grpc-status and grpc-message, which are removed from md
+func grpcStatus(md metadata.MD) error {
+	values := md.Get("grpc-status")
+	if len(values) == 0 {
+		return status.Errorf(codes.Internal, "response ended without a grpc-status")
+	}
+	code, err := strconv.Atoi(values[0])
+	if err != nil {
+		return status.Errorf(codes.Internal, "invalid grpc-status %q", values[0])
+	}
+	message := ""
+	if messages := md.Get("grpc-message"); len(messages) > 0 {
+		if message, err = url.PathUnescape(messages[0]); err != nil {
+			message = messages[0]
+		}
+	}
+	md.Delete("grpc-status")
+	md.Delete("grpc-message")
+	if code == int(codes.OK) {
+		return nil
+	}
+	return status.Error(codes.Code(code), message)
+}
+
+// httpStatusError maps an HTTP error without gRPC status, such as one from a proxy, to the code gRPC uses for it
+func httpStatusError(httpResp *http.Response, body []byte) error {
+	code := codes.Unknown
+	switch httpResp.StatusCode {
+	case http.StatusBadRequest:
+		code = codes.Internal
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		code = codes.Unavailable
+	}
+	message := httpResp.Status
+	if text := strings.TrimSpace(string(body)); text != "" && len(text) < 512 {
+		message += ": " + text
+	}
+	return status.Error(code, message)
+}
+
+type connectErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *connectErrorBody) err() error {
+	for code := codes.OK; code <= codes.Unauthenticated; code++ {
+		if connectCodeName(code) == e.Code {
+			return status.Error(code, e.Message)
+		}
+	}
+	return status.Error(codes.Unknown, e.Message)
+}
+
+// connectCodeName returns the Connect protocol's snake case name for a code
+func connectCodeName(code codes.Code) string {
+	var sb strings.Builder
+	previousUpper := false
+	for i, r := range code.String() {
+		upper := r >= 'A' && r <= 'Z'
+		if upper {
+			// a run of capitals such as the one of OK is a single word
+			if i > 0 && !previousUpper {
+				sb.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		sb.WriteRune(r)
+		previousUpper = upper
+	}
+	return sb.String()
+}
+
+// connectError reads the JSON error body of a failed Connect call, falling back to the HTTP status
+func connectError(httpResp *http.Response, body []byte) error {
+	var e connectErrorBody
+	if err := json.Unmarshal(body, &e); err != nil || e.Code == "" {
+		return httpStatusError(httpResp, body)
+	}
+	return e.err()
+}
@@ -0,0 +1,366 @@
+package proto
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestFraming(t *testing.T) {
+	framed := append(frame(0x01, []byte("abc")), frame(0x80, nil)...)
+	flags, message, rest, err := unframe(framed)
+	if err != nil || flags != 0x01 || string(message) != "abc" {
+		t.Fatalf("unframe gave %x, %q, %v", flags, message, err)
+	}
+	if flags, message, rest, err = unframe(rest); err != nil || flags != 0x80 || len(message) != 0 || len(rest) != 0 {
+		t.Fatalf("unframe of an empty frame gave %x, %q, %q, %v", flags, message, rest, err)
+	}
+	for _, truncated := range [][]byte{{0, 0, 0}, frame(0, []byte("abc"))[:7]} {
+		if _, _, _, err = unframe(truncated); err == nil {
+			t.Errorf("unframe(%x) accepted a truncated frame", truncated)
+		}
+	}
+}
+
+func TestDecodeWebText(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"", ""},
+		{base64.StdEncoding.EncodeToString([]byte("abcd")), "abcd"},
+		// servers flushing each frame separately pad every chunk
+		{base64.StdEncoding.EncodeToString([]byte("a")) + base64.StdEncoding.EncodeToString([]byte("bc")), "abc"},
+		{"YQ==\r\nYmM=\n", "abc"},
+		{"YWJj" + "ZA==", "abcd"},
+	}
+	for _, tt := range tests {
+		got, err := decodeWebText([]byte(tt.body))
+		if err != nil || string(got) != tt.want {
+			t.Errorf("decodeWebText(%q) = %q, %v, want %q", tt.body, got, err, tt.want)
+		}
+	}
+	if _, err := decodeWebText([]byte("YQ=!")); err == nil {
+		t.Error("decodeWebText accepted invalid base64")
+	}
+}
+
+func TestConnectCodeName(t *testing.T) {
+	tests := map[codes.Code]string{
+		codes.OK:                 "ok",
+		codes.NotFound:           "not_found",
+		codes.DeadlineExceeded:   "deadline_exceeded",
+		codes.ResourceExhausted:  "resource_exhausted",
+		codes.Unauthenticated:    "unauthenticated",
+		codes.FailedPrecondition: "failed_precondition",
+	}
+	for code, want := range tests {
+		if got := connectCodeName(code); got != want {
+			t.Errorf("connectCodeName(%v) = %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestConnectError(t *testing.T) {
+	tests := []struct {
+		status  int
+		body    string
+		code    codes.Code
+		message string
+	}{
+		{http.StatusNotFound, `{"code": "not_found", "message": "no such user"}`, codes.NotFound, "no such user"},
+		{http.StatusBadRequest, `{"code": "invalid_argument"}`, codes.InvalidArgument, ""},
+		{http.StatusInternalServerError, `{"code": "something_new", "message": "m"}`, codes.Unknown, "m"},
+		{http.StatusServiceUnavailable, "upstream connect error", codes.Unavailable, "503 Service Unavailable: upstream connect error"},
+		{http.StatusNotFound, `{"message": "no code"}`, codes.Unimplemented, `404 Not Found: {"message": "no code"}`},
+	}
+	for _, tt := range tests {
+		httpResp := &http.Response{StatusCode: tt.status, Status: fmt.Sprintf("%d %s", tt.status, http.StatusText(tt.status))}
+		err := status.Convert(connectError(httpResp, []byte(tt.body)))
+		if err.Code() != tt.code || err.Message() != tt.message {
+			t.Errorf("connectError(%d, %s) = %v, %q, want %v, %q", tt.status, tt.body, err.Code(), err.Message(), tt.code, tt.message)
+		}
+	}
+}
+
+func TestGrpcStatus(t *testing.T) {
+	md := metadata.Pairs("grpc-status", "5", "grpc-message", "user%20not%20found", "x-trailer", "t")
+	err := status.Convert(grpcStatus(md))
+	if err.Code() != codes.NotFound || err.Message() != "user not found" {
+		t.Errorf("grpcStatus = %v, %q", err.Code(), err.Message())
+	}
+	if len(md.Get("grpc-status")) != 0 || len(md.Get("grpc-message")) != 0 || md.Get("x-trailer")[0] != "t" {
+		t.Errorf("the status was not removed from the trailers: %v", md)
+	}
+	if err := grpcStatus(metadata.Pairs("grpc-status", "0")); err != nil {
+		t.Errorf("an OK status gave %v", err)
+	}
+	if status.Code(grpcStatus(metadata.MD{})) != codes.Internal {
+		t.Error("a missing status was not an error")
+	}
+}
+
+// dialTestServer connects to a plaintext test server with the given protocol
+func dialTestServer(t *testing.T, protocol Protocol, codec Codec, handler http.HandlerFunc) Conn {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	gcd := NewGrpcConnection()
+	gcd.SetTLS(TLSConfig{Mode: TLSPlaintext})
+	gcd.SetTransport(TransportConfig{Protocol: protocol, Codec: codec})
+	conn, err := gcd.dialHTTP(server.Listener.Addr().String(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// invoke sends "ping" and returns the reply, headers and trailers
+func invoke(conn Conn) (string, metadata.MD, metadata.MD, error) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request", "r")
+	var header, trailer metadata.MD
+	reply := &wrapperspb.StringValue{}
+	err := conn.Invoke(ctx, "/demo.Echo/Say", wrapperspb.String("ping"), reply, grpc.Header(&header), grpc.Trailer(&trailer))
+	return reply.GetValue(), header, trailer, err
+}
+
+// readRequest reads the single framed request of a gRPC-Web or Connect streaming call
+func readRequest(t *testing.T, r *http.Request, text bool) string {
+	t.Helper()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Error(err)
+		return ""
+	}
+	if text {
+		if body, err = base64.StdEncoding.DecodeString(string(body)); err != nil {
+			t.Error(err)
+			return ""
+		}
+	}
+	_, message, rest, err := unframe(body)
+	if err != nil || len(rest) != 0 {
+		t.Errorf("request frame: %v, %d bytes left", err, len(rest))
+		return ""
+	}
+	request := &wrapperspb.StringValue{}
+	if err = proto.Unmarshal(message, request); err != nil {
+		t.Error(err)
+	}
+	return request.GetValue()
+}
+
+func marshal(t *testing.T, value string) []byte {
+	t.Helper()
+	message, err := proto.Marshal(wrapperspb.String(value))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+func TestGRPCWebCall(t *testing.T) {
+	for _, protocol := range []Protocol{ProtocolGRPCWeb, ProtocolGRPCWebText} {
+		text := protocol == ProtocolGRPCWebText
+		conn := dialTestServer(t, protocol, CodecProto, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/demo.Echo/Say" || r.Header.Get("X-Grpc-Web") != "1" || r.Header.Get("X-Request") != "r" {
+				t.Errorf("%v: unexpected request %s %v", protocol, r.URL.Path, r.Header)
+			}
+			request := readRequest(t, r, text)
+			w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+			w.Header().Set("X-Header", "h")
+			frames := [][]byte{
+				frame(0, marshal(t, "pong "+request)),
+				frame(0x80, []byte("grpc-status: 0\r\nx-trailer: t\r\nx-trailer-bin: AP8\r\n")),
+			}
+			for _, f := range frames {
+				if text {
+					// each frame is encoded and padded on its own
+					f = []byte(base64.StdEncoding.EncodeToString(f))
+				}
+				_, _ = w.Write(f)
+			}
+		})
+		reply, header, trailer, err := invoke(conn)
+		if err != nil || reply != "pong ping" {
+			t.Fatalf("%v: reply %q, %v", protocol, reply, err)
+		}
+		if header.Get("x-header")[0] != "h" || len(header.Get("content-length")) != 0 {
+			t.Errorf("%v: headers %v", protocol, header)
+		}
+		if trailer.Get("x-trailer")[0] != "t" || trailer.Get("x-trailer-bin")[0] != "\x00\xff" || len(trailer.Get("grpc-status")) != 0 {
+			t.Errorf("%v: trailers %v", protocol, trailer)
+		}
+	}
+}
+
+func TestGRPCWebErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		code    codes.Code
+		message string
+	}{
+		{"trailers only", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "user%20not%20found")
+		}, codes.NotFound, "user not found"},
+		{"error trailer", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(frame(0x80, []byte("grpc-status: 7\r\ngrpc-message: denied\r\n")))
+		}, codes.PermissionDenied, "denied"},
+		{"proxy error", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "no healthy upstream", http.StatusServiceUnavailable)
+		}, codes.Unavailable, "503 Service Unavailable: no healthy upstream"},
+		{"no status", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(frame(0, marshal(t, "pong")))
+		}, codes.Internal, "response ended without a grpc-status"},
+		{"two messages", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(frame(0, marshal(t, "a")))
+			_, _ = w.Write(frame(0, marshal(t, "b")))
+			_, _ = w.Write(frame(0x80, []byte("grpc-status: 0\r\n")))
+		}, codes.Internal, "received 2 response messages for a unary call"},
+	}
+	for _, tt := range tests {
+		_, _, _, err := invoke(dialTestServer(t, ProtocolGRPCWeb, CodecProto, tt.handler))
+		if s := status.Convert(err); s.Code() != tt.code || s.Message() != tt.message {
+			t.Errorf("%s: got %v, %q, want %v, %q", tt.name, s.Code(), s.Message(), tt.code, tt.message)
+		}
+	}
+}
+
+func TestConnectUnaryCall(t *testing.T) {
+	for _, codec := range []Codec{CodecProto, CodecJSON} {
+		conn := dialTestServer(t, ProtocolConnect, codec, func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Connect-Protocol-Version") != "1" {
+				t.Errorf("%v: missing the protocol version header", codec)
+			}
+			body, _ := io.ReadAll(r.Body)
+			request := &wrapperspb.StringValue{}
+			reply := wrapperspb.String("pong")
+			var err error
+			if codec == CodecJSON {
+				if r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("content type %s", r.Header.Get("Content-Type"))
+				}
+				err = protojson.Unmarshal(body, request)
+				body, _ = protojson.Marshal(reply)
+			} else {
+				err = proto.Unmarshal(body, request)
+				body = marshal(t, "pong")
+			}
+			if err != nil || request.GetValue() != "ping" {
+				t.Errorf("%v: request %q, %v", codec, request.GetValue(), err)
+			}
+			w.Header().Set("Trailer-X-Trailer", "t")
+			_, _ = w.Write(body)
+		})
+		reply, _, trailer, err := invoke(conn)
+		if err != nil || reply != "pong" {
+			t.Fatalf("%v: reply %q, %v", codec, reply, err)
+		}
+		if got := trailer.Get("x-trailer"); len(got) != 1 || got[0] != "t" {
+			t.Errorf("%v: trailers %v", codec, trailer)
+		}
+	}
+
+	conn := dialTestServer(t, ProtocolConnect, CodecProto, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"code": "not_found", "message": "no such user"}`))
+	})
+	if _, _, _, err := invoke(conn); status.Code(err) != codes.NotFound || status.Convert(err).Message() != "no such user" {
+		t.Errorf("error response gave %v", err)
+	}
+}
+
+func TestConnectStreamCall(t *testing.T) {
+	end := func(w http.ResponseWriter, v any) {
+		message, _ := json.Marshal(v)
+		_, _ = w.Write(frame(0x02, message))
+	}
+	conn := dialTestServer(t, ProtocolConnectStream, CodecProto, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/connect+proto" {
+			t.Errorf("content type %s", r.Header.Get("Content-Type"))
+		}
+		request := readRequest(t, r, false)
+		_, _ = w.Write(frame(0, marshal(t, "pong "+request)))
+		end(w, map[string]any{"metadata": map[string][]string{"x-trailer": {"t"}}})
+	})
+	reply, _, trailer, err := invoke(conn)
+	if err != nil || reply != "pong ping" {
+		t.Fatalf("reply %q, %v", reply, err)
+	}
+	if got := trailer.Get("x-trailer"); len(got) != 1 || got[0] != "t" {
+		t.Errorf("trailers %v", trailer)
+	}
+
+	conn = dialTestServer(t, ProtocolConnectStream, CodecProto, func(w http.ResponseWriter, r *http.Request) {
+		end(w, map[string]any{"error": map[string]string{"code": "permission_denied", "message": "denied"}})
+	})
+	if _, _, _, err = invoke(conn); status.Code(err) != codes.PermissionDenied || status.Convert(err).Message() != "denied" {
+		t.Errorf("end of stream error gave %v", err)
+	}
+
+	conn = dialTestServer(t, ProtocolConnectStream, CodecProto, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(frame(0, marshal(t, "pong")))
+	})
+	if _, _, _, err = invoke(conn); status.Code(err) != codes.Internal || !strings.Contains(err.Error(), "without an end of stream") {
+		t.Errorf("a stream without an end gave %v", err)
+	}
+}
+
+func TestNativeOnlySettings(t *testing.T) {
+	gcd := NewGrpcConnection()
+	gcd.SetServiceConfig(`{"methodConfig": []}`)
+	gcd.SetTuning(TuningConfig{KeepaliveTime: time.Minute, InitialWindowSize: 1 << 20, Compression: "gzip"})
+	if ignored := gcd.NativeOnlySettings(); len(ignored) != 0 {
+		t.Errorf("native gRPC ignores %q", ignored)
+	}
+	gcd.SetTransport(TransportConfig{Protocol: ProtocolConnect})
+	want := []string{"the service config's retry and hedging policies, timeouts and load balancing", "keepalive pings",
+		"initial flow control window sizes"}
+	if ignored := gcd.NativeOnlySettings(); !slices.Equal(ignored, want) {
+		t.Errorf("Connect ignores %q, want %q", ignored, want)
+	}
+	gcd.SetServiceConfig("")
+	gcd.SetTuning(TuningConfig{WaitForReady: true})
+	if ignored := gcd.NativeOnlySettings(); !slices.Equal(ignored, []string{"wait for ready"}) {
+		t.Errorf("Connect ignores %q", ignored)
+	}
+}
+
+func TestResponseLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(frame(0, make([]byte, 1<<20)))
+	}))
+	t.Cleanup(server.Close)
+	gcd := NewGrpcConnection()
+	gcd.SetTLS(TLSConfig{Mode: TLSPlaintext})
+	gcd.SetTransport(TransportConfig{Protocol: ProtocolGRPCWeb})
+	gcd.SetTuning(TuningConfig{MaxReceiveSize: 1024})
+	conn, err := gcd.dialHTTP(server.Listener.Addr().String(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, _, _, err = invoke(conn); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("a response over the receive limit gave %v", err)
+	}
+}
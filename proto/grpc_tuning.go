@@ -0,0 +1,128 @@
+package proto
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultUserAgent is sent when a server does not set its own
+	defaultUserAgent = "grpc-tool/1.0"
+	// defaultMaxReceiveSize matches grpc's limit so the HTTP protocols fail on the same responses
+	defaultMaxReceiveSize = 4 * 1024 * 1024
+	// responseOverhead is the room left in gRPC-Web and Connect response bodies for framing, trailers and errors
+	responseOverhead = 64 * 1024
+)
+
+// Compressions lists the request compressions that can be chosen, responses are decompressed whatever is chosen
+var Compressions = []string{"", "gzip"}
+
+// TuningConfig holds the advanced connection settings of a server, zero values leave grpc's defaults.
+// Keepalive, window sizes and wait for ready only apply to native gRPC
+type TuningConfig struct {
+	Compression           string
+	MaxSendSize           int
+	MaxReceiveSize        int
+	KeepaliveTime         time.Duration
+	KeepaliveTimeout      time.Duration
+	KeepaliveWithoutCalls bool
+	Authority             string
+	UserAgent             string
+	InitialWindowSize     int32
+	InitialConnWindowSize int32
+	WaitForReady          bool
+}
+
+// SetTuning sets the advanced settings used for new connections
+func (gcd *GrpcConnection) SetTuning(cfg TuningConfig) {
+	gcd.Tuning = cfg
+}
+
+// tuningOptions returns the dial options for the advanced settings
+func (gcd *GrpcConnection) tuningOptions() ([]grpc.DialOption, error) {
+	cfg := gcd.Tuning
+	userAgent := defaultUserAgent
+	if cfg.UserAgent != "" {
+		userAgent = cfg.UserAgent
+	}
+	opts := []grpc.DialOption{grpc.WithUserAgent(userAgent)}
+	if cfg.Authority != "" {
+		opts = append(opts, grpc.WithAuthority(cfg.Authority))
+	}
+	if cfg.KeepaliveTime > 0 || cfg.KeepaliveTimeout > 0 || cfg.KeepaliveWithoutCalls {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                cfg.KeepaliveTime,
+			Timeout:             cfg.KeepaliveTimeout,
+			PermitWithoutStream: cfg.KeepaliveWithoutCalls,
+		}))
+	}
+	if cfg.InitialWindowSize > 0 {
+		opts = append(opts, grpc.WithInitialWindowSize(cfg.InitialWindowSize))
+	}
+	if cfg.InitialConnWindowSize > 0 {
+		opts = append(opts, grpc.WithInitialConnWindowSize(cfg.InitialConnWindowSize))
+	}
+
+	var callOpts []grpc.CallOption
+	switch cfg.Compression {
+	case "":
+	case "gzip":
+		callOpts = append(callOpts, grpc.UseCompressor("gzip"))
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", cfg.Compression)
+	}
+	if cfg.MaxSendSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallSendMsgSize(cfg.MaxSendSize))
+	}
+	if cfg.MaxReceiveSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(cfg.MaxReceiveSize))
+	}
+	if cfg.WaitForReady {
+		callOpts = append(callOpts, grpc.WaitForReady(true))
+	}
+	if len(callOpts) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+	return opts, nil
+}
+
+func gzipCompress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gzipDecompress decompresses data, failing once the result would exceed limit bytes when limit is positive
+func gzipDecompress(data []byte, limit int) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var src io.Reader = reader
+	if limit > 0 {
+		src = io.LimitReader(reader, int64(limit)+1)
+	}
+	decompressed, err := io.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(decompressed) > limit {
+		return nil, status.Errorf(codes.ResourceExhausted, "grpc: received message after decompression larger than max %d", limit)
+	}
+	return decompressed, nil
+}
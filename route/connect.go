@@ -0,0 +1,77 @@
+package route
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// connectDialer opens a tunnel through an HTTP proxy with the CONNECT method
+type connectDialer struct {
+	cfg    Config
+	dialer net.Dialer
+}
+
+func (d *connectDialer) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	if network != "tcp" {
+		return nil, fmt.Errorf("a %s socket cannot be reached through an HTTP proxy", network)
+	}
+	conn, err := d.dialer.DialContext(ctx, "tcp", d.cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP proxy: %w", err)
+	}
+	// the handshake is abandoned if the context ends first
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+	if d.cfg.Username != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(d.cfg.Username + ":" + d.cfg.Password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err = req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("HTTP proxy: %w", err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("HTTP proxy: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("HTTP proxy refused CONNECT to %s: %s", address, resp.Status)
+	}
+	if !stop() {
+		return nil, ctx.Err()
+	}
+	if reader.Buffered() > 0 {
+		// bytes the server sent straight after the proxy's reply are already in the reader
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+func (d *connectDialer) Close() error {
+	return nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
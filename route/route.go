@@ -0,0 +1,122 @@
+package route
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/proxy"
+)
+
+// Type selects how connections to a server are routed
+type Type string
+
+const (
+	Direct      Type = ""
+	HTTPConnect Type = "http"
+	SOCKS5      Type = "socks5"
+	SSHTunnel   Type = "ssh"
+)
+
+// Types lists every route in the order they are offered to the user
+var Types = []Type{Direct, HTTPConnect, SOCKS5, SSHTunnel}
+
+// Config holds the settings for every route type, only the fields used by Type are relevant.
+// Password is the proxy password, or for SSH the login password or the passphrase of KeyFile
+type Config struct {
+	Type     Type
+	Address  string
+	Username string
+	Password string
+
+	KeyFile    string
+	KnownHosts string
+}
+
+// String returns a user facing name for the route type
+func (t Type) String() string {
+	switch t {
+	case Direct:
+		return "Direct"
+	case HTTPConnect:
+		return "HTTP CONNECT Proxy"
+	case SOCKS5:
+		return "SOCKS5 Proxy"
+	case SSHTunnel:
+		return "SSH Tunnel"
+	}
+	return string(t)
+}
+
+// ParseType returns the type with the given name as used in configuration files
+func ParseType(name string) (Type, error) {
+	for _, t := range Types {
+		if string(t) == name {
+			return t, nil
+		}
+	}
+	return Direct, fmt.Errorf("unknown route %q", name)
+}
+
+// Dialer opens connections to servers along a route, Close releases any connection the route itself holds
+type Dialer interface {
+	DialContext(ctx context.Context, network string, address string) (net.Conn, error)
+	Close() error
+}
+
+// New creates the dialer described by cfg, nil is returned when cfg.Type is Direct
+func New(cfg Config) (Dialer, error) {
+	if cfg.Type != Direct && cfg.Address == "" {
+		return nil, fmt.Errorf("%s address is not set", strings.ToLower(cfg.Type.String()))
+	}
+
+	switch cfg.Type {
+	case Direct:
+		return nil, nil
+	case HTTPConnect:
+		return &connectDialer{cfg: cfg}, nil
+	case SOCKS5:
+		var socksAuth *proxy.Auth
+		if cfg.Username != "" {
+			socksAuth = &proxy.Auth{User: cfg.Username, Password: cfg.Password}
+		}
+		d, err := proxy.SOCKS5("tcp", cfg.Address, socksAuth, &net.Dialer{})
+		if err != nil {
+			return nil, err
+		}
+		return &socksDialer{d.(proxy.ContextDialer)}, nil
+	case SSHTunnel:
+		return newSSHDialer(cfg)
+	}
+	return nil, fmt.Errorf("unknown route %q", cfg.Type)
+}
+
+type socksDialer struct {
+	proxy.ContextDialer
+}
+
+func (d *socksDialer) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	if network != "tcp" {
+		return nil, fmt.Errorf("a %s socket cannot be reached through a SOCKS5 proxy", network)
+	}
+	return d.ContextDialer.DialContext(ctx, network, address)
+}
+
+func (d *socksDialer) Close() error {
+	return nil
+}
+
+// SplitAddress returns the network and address of an address given to a grpc dialer, which passes unix sockets as
+// unix:path, unix://path or a NUL prefixed abstract name
+func SplitAddress(address string) (string, string) {
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		return "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "unix:"):
+		return "unix", strings.TrimPrefix(address, "unix:")
+	case strings.HasPrefix(address, "\x00"):
+		return "unix", "@" + address[1:]
+	}
+	return "tcp", address
+}
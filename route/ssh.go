@@ -0,0 +1,196 @@
+package route
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshDialer forwards connections through an SSH server, the SSH connection is opened on first use and shared
+// by every connection until it drops
+type sshDialer struct {
+	address string
+	config  *ssh.ClientConfig
+	dialer  net.Dialer
+
+	mu     sync.Mutex
+	client *ssh.Client
+	closed bool
+}
+
+func newSSHDialer(cfg Config) (*sshDialer, error) {
+	address := cfg.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "22")
+	}
+	if cfg.Username == "" {
+		return nil, fmt.Errorf("SSH user is not set")
+	}
+
+	knownHostsFile := cfg.KnownHosts
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	} else if rest, ok := strings.CutPrefix(knownHostsFile, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		knownHostsFile = filepath.Join(home, rest)
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("known hosts: %w", err)
+	}
+
+	var methods []ssh.AuthMethod
+	switch {
+	case cfg.KeyFile != "":
+		signer, err := readKeyFile(cfg.KeyFile, cfg.Password)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	case cfg.Password != "":
+		methods = append(methods, ssh.Password(cfg.Password))
+	case os.Getenv("SSH_AUTH_SOCK") != "":
+		// the agent is only asked for keys when the SSH connection is opened
+		methods = append(methods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+			if err != nil {
+				return nil, fmt.Errorf("SSH agent: %w", err)
+			}
+			defer conn.Close()
+			return agent.NewClient(conn).Signers()
+		}))
+	default:
+		return nil, fmt.Errorf("SSH tunnel needs a key file, a password or a running SSH agent")
+	}
+
+	return &sshDialer{
+		address: address,
+		config: &ssh.ClientConfig{
+			User:              cfg.Username,
+			Auth:              methods,
+			HostKeyCallback:   hostKeyCallback,
+			HostKeyAlgorithms: knownKeyAlgorithms(hostKeyCallback, address),
+		},
+	}, nil
+}
+
+func readKeyFile(path string, passphrase string) (ssh.Signer, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("SSH key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(pem)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if passphrase == "" {
+			return nil, fmt.Errorf("SSH key %s is encrypted, enter its passphrase as the password", path)
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(passphrase))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("SSH key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// knownKeyAlgorithms returns the host key algorithms recorded for address in known_hosts, so the server is asked
+// for a key that can be checked rather than its preferred one. Nothing is returned for unknown hosts
+func knownKeyAlgorithms(callback ssh.HostKeyCallback, address string) []string {
+	// checking a key that cannot match makes the callback report the keys it knows for the host
+	public, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil
+	}
+	probe, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return nil
+	}
+	var keyErr *knownhosts.KeyError
+	if err = callback(address, &net.TCPAddr{IP: net.IPv4zero}, probe); !errors.As(err, &keyErr) {
+		return nil
+	}
+	var algorithms []string
+	for _, known := range keyErr.Want {
+		if known.Key.Type() == ssh.KeyAlgoRSA {
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256)
+		}
+		algorithms = append(algorithms, known.Key.Type())
+	}
+	return algorithms
+}
+
+func (d *sshDialer) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	client, err := d.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.DialContext(ctx, network, address)
+}
+
+// getClient returns the open SSH connection, connecting first when there is none
+func (d *sshDialer) getClient(ctx context.Context) (*ssh.Client, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, fmt.Errorf("SSH tunnel is closed")
+	}
+	if d.client != nil {
+		return d.client, nil
+	}
+
+	conn, err := d.dialer.DialContext(ctx, "tcp", d.address)
+	if err != nil {
+		return nil, fmt.Errorf("SSH: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, d.address, d.config)
+	stop()
+	if err != nil {
+		_ = conn.Close()
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			return nil, fmt.Errorf("SSH: %s is not in known_hosts, add it with ssh-keyscan first: %w", d.address, err)
+		}
+		return nil, fmt.Errorf("SSH: %w", err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	d.client = client
+	go func() {
+		_ = client.Wait()
+		d.mu.Lock()
+		if d.client == client {
+			d.client = nil
+		}
+		d.mu.Unlock()
+	}()
+	return client, nil
+}
+
+func (d *sshDialer) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	if d.client == nil {
+		return nil
+	}
+	err := d.client.Close()
+	d.client = nil
+	return err
+}
@@ -0,0 +1,69 @@
+// Package shell splits command lines typed as they would be in a shell
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Split splits a command line into words the way a POSIX shell does for quotes, backslashes and line
+// continuations, variables and globs are left as they are
+func Split(command string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == '\\':
+			if i+1 < len(command) {
+				i++
+				if command[i] == '\n' {
+					continue
+				}
+				if command[i] == '\r' && i+1 < len(command) && command[i+1] == '\n' {
+					i++
+					continue
+				}
+				word.WriteByte(command[i])
+				inWord = true
+			}
+		case c == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			word.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(command) && command[i] != '"'; i++ {
+				if command[i] == '\\' && i+1 < len(command) && strings.ContainsRune("\"\\$`\n", rune(command[i+1])) {
+					i++
+					if command[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(command[i])
+			}
+			if i >= len(command) {
+				return nil, fmt.Errorf("unterminated double quote")
+			}
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,42 @@
+package shell
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"", nil},
+		{"  kubectl get token ", []string{"kubectl", "get", "token"}},
+		{`gcloud auth print-access-token --format "value(token)"`,
+			[]string{"gcloud", "auth", "print-access-token", "--format", "value(token)"}},
+		{`echo 'it''s' "a \"b\" \$c \x"`, []string{"echo", "its", `a "b" $c \x`}},
+		{`a\ b c\\d`, []string{"a b", `c\d`}},
+		{"one \\\ntwo \\\r\nthree", []string{"one", "two", "three"}},
+		{`empty "" ''`, []string{"empty", "", ""}},
+		{"tabs\tand\nnewlines", []string{"tabs", "and", "newlines"}},
+		{"$HOME/*.proto", []string{"$HOME/*.proto"}},
+	}
+	for _, tt := range tests {
+		got, err := Split(tt.command)
+		if err != nil {
+			t.Errorf("Split(%q): %v", tt.command, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Split(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestSplitUnterminated(t *testing.T) {
+	for _, command := range []string{`echo 'a`, `echo "a`, `echo "a\"`} {
+		if _, err := Split(command); err == nil {
+			t.Errorf("Split(%q): expected an error", command)
+		}
+	}
+}
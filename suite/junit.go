@@ -0,0 +1,113 @@
+package suite
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML for CI systems, each saved request is a test case
+func (r *Report) WriteJUnit(w io.Writer) error {
+	suite := junitTestSuite{
+		Name:      r.Name,
+		Tests:     len(r.Cases),
+		Failures:  r.Failures(),
+		Skipped:   r.Skips(),
+		Time:      seconds(r.Duration),
+		Timestamp: r.Started.UTC().Format("2006-01-02T15:04:05"),
+	}
+	for _, c := range r.Cases {
+		tc := junitTestCase{
+			Name:      c.Request.Name,
+			ClassName: c.Request.Service + "." + c.Request.Method,
+			Time:      seconds(c.Duration),
+		}
+		if c.Response != nil {
+			tc.SystemOut = c.Response.Body
+		}
+		if c.Status == Skipped {
+			tc.Skipped = &junitSkipped{Message: "skipped after an earlier step failed"}
+		} else if !c.Passed() {
+			var failed []string
+			var details strings.Builder
+			for _, outcome := range c.Outcomes {
+				if !outcome.Passed {
+					failed = append(failed, outcome.Assertion.Describe())
+					fmt.Fprintf(&details, "%s: %s\n", outcome.Assertion.Describe(), outcome.Message)
+				}
+			}
+			for _, captureErr := range c.CaptureErrors {
+				failed = append(failed, "capture")
+				details.WriteString(captureErr + "\n")
+			}
+			tc.Failure = &junitFailure{
+				Message: "failed: " + strings.Join(failed, ", "),
+				Type:    "AssertionFailure",
+				Text:    details.String(),
+			}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{
+		Name:     r.Name,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
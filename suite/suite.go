@@ -0,0 +1,186 @@
+package suite
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"grpc_ui_tool/assert"
+	"grpc_ui_tool/capture"
+	"grpc_ui_tool/config"
+	"grpc_ui_tool/proto"
+)
+
+// Status is the state of a single step while a collection runs
+type Status int
+
+const (
+	Pending Status = iota
+	Running
+	Passed
+	Failed
+	Skipped
+)
+
+func (s Status) String() string {
+	switch s {
+	case Pending:
+		return "PENDING"
+	case Running:
+		return "RUNNING"
+	case Passed:
+		return "PASS"
+	case Failed:
+		return "FAIL"
+	case Skipped:
+		return "SKIP"
+	}
+	return "UNKNOWN"
+}
+
+// Options changes how a collection is run
+type Options struct {
+	// StopOnFailure skips the remaining steps after a failure, as later steps of a chain usually depend on earlier ones
+	StopOnFailure bool
+}
+
+// CaseResult is the outcome of running one saved request
+type CaseResult struct {
+	Request  *config.SavedRequest
+	Status   Status
+	Response *proto.Response
+	Error    error
+	Outcomes []assert.Outcome
+	Captured map[string]string
+	// CaptureErrors holds the captures that could not be extracted, the step fails if there are any
+	CaptureErrors []string
+	Duration      time.Duration
+}
+
+// Report is the outcome of running a whole collection
+type Report struct {
+	Name      string
+	Started   time.Time
+	Duration  time.Duration
+	Cases     []*CaseResult
+	Variables map[string]string
+}
+
+// Passed reports whether every assertion and capture of the case succeeded
+func (c *CaseResult) Passed() bool {
+	return c.Status == Passed
+}
+
+// Failures returns the number of cases that failed
+func (r *Report) Failures() int {
+	return r.count(Failed)
+}
+
+// Skips returns the number of cases that were not run
+func (r *Report) Skips() int {
+	return r.count(Skipped)
+}
+
+func (r *Report) count(status Status) int {
+	n := 0
+	for _, c := range r.Cases {
+		if c.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Run sends every request of the collection in order and checks its assertions. Values captured from each response
+// are available as variables to every later request. progress is called whenever a step changes status if not nil
+func Run(gcd *proto.GrpcConnection, collection *config.Collection, opts Options, progress func(index int, result *CaseResult)) *Report {
+	report := &Report{Name: collection.Name, Started: time.Now(), Variables: make(map[string]string)}
+	for _, req := range collection.Requests {
+		report.Cases = append(report.Cases, &CaseResult{Request: req, Status: Pending})
+	}
+	notify := func(index int) {
+		if progress != nil {
+			progress(index, report.Cases[index])
+		}
+	}
+
+	stopped := false
+	for i, result := range report.Cases {
+		if stopped {
+			result.Status = Skipped
+			notify(i)
+			continue
+		}
+		result.Status = Running
+		notify(i)
+
+		runStep(gcd.WithVariables(report.Variables), result, report.Variables)
+		notify(i)
+		if result.Status == Failed && opts.StopOnFailure {
+			stopped = true
+		}
+	}
+	report.Duration = time.Since(report.Started)
+	return report
+}
+
+func runStep(gcd *proto.GrpcConnection, result *CaseResult, variables map[string]string) {
+	req := result.Request
+	start := time.Now()
+	result.Response, result.Error = gcd.Send(req.Service, req.Method, req.Body, req.Metadata)
+	result.Duration = time.Since(start)
+	result.Outcomes = assert.CheckAll(req.Assertions, result.Response, result.Error)
+
+	result.Captured = make(map[string]string)
+	for _, c := range req.Captures {
+		value, err := capture.Extract(c, result.Response, result.Error)
+		if err != nil {
+			result.CaptureErrors = append(result.CaptureErrors, err.Error())
+			continue
+		}
+		result.Captured[c.Variable] = value
+		variables[c.Variable] = value
+	}
+
+	result.Status = Passed
+	if len(result.CaptureErrors) > 0 {
+		result.Status = Failed
+	}
+	for _, outcome := range result.Outcomes {
+		if !outcome.Passed {
+			result.Status = Failed
+		}
+	}
+}
+
+// Format renders the report as a human readable pass/fail summary
+func (r *Report) Format() string {
+	var sb strings.Builder
+	for _, c := range r.Cases {
+		fmt.Fprintf(&sb, "%s  %s (%s/%s) %s\n", c.Status, c.Request.Name, c.Request.Service, c.Request.Method, c.Duration.Round(time.Millisecond))
+		sb.WriteString(c.FormatDetails("      "))
+	}
+	fmt.Fprintf(&sb, "\n%d passed, %d failed, %d skipped in %s\n", r.count(Passed), r.Failures(), r.Skips(), r.Duration.Round(time.Millisecond))
+	return sb.String()
+}
+
+// FormatDetails renders the assertion outcomes and captured values of a step, each line prefixed with indent
+func (c *CaseResult) FormatDetails(indent string) string {
+	var sb strings.Builder
+	for _, outcome := range c.Outcomes {
+		mark := "ok  "
+		if !outcome.Passed {
+			mark = "fail"
+		}
+		fmt.Fprintf(&sb, "%s%s %s: %s\n", indent, mark, outcome.Assertion.Describe(), outcome.Message)
+	}
+	for _, captureErr := range c.CaptureErrors {
+		fmt.Fprintf(&sb, "%sfail %s\n", indent, captureErr)
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Captured)) {
+		fmt.Fprintf(&sb, "%scapt %s = %s\n", indent, name, c.Captured[name])
+	}
+	return sb.String()
+}
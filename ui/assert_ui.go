@@ -0,0 +1,113 @@
+package ui
+
+import (
+	"grpc_ui_tool/assert"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+type assertionRow struct {
+	kindSelect *widget.Select
+	pathEntry  *widget.Entry
+	valueEntry *widget.Entry
+}
+
+var assertions []*assertionRow
+
+// createAssertionsSection builds the assertion rows attached to a request when it is saved to a collection
+func (toolUI *UI) createAssertionsSection(existing []assert.Assertion) *fyne.Container {
+	assertions = nil
+	box := container.New(layout.NewVBoxLayout())
+
+	label := widget.NewLabel("Assertions")
+	label.Alignment = fyne.TextAlignCenter
+	label.TextStyle = fyne.TextStyle{Bold: true}
+	box.Add(label)
+
+	rows := container.New(layout.NewVBoxLayout())
+	box.Add(rows)
+
+	addButton := widget.NewButtonWithIcon("", theme.ContentAddIcon(), func() {
+		toolUI.addAssertionRow(rows, assert.Assertion{Kind: assert.StatusCode, Value: "OK"})
+	})
+	clearButton := widget.NewButtonWithIcon("", theme.ContentClearIcon(), func() {
+		rows.RemoveAll()
+		assertions = nil
+	})
+	buttonBox := container.New(layout.NewHBoxLayout(), addButton, clearButton)
+	box.Add(container.New(layout.NewBorderLayout(nil, nil, nil, buttonBox), buttonBox))
+
+	for _, a := range existing {
+		toolUI.addAssertionRow(rows, a)
+	}
+	return box
+}
+
+func (toolUI *UI) addAssertionRow(rows *fyne.Container, a assert.Assertion) {
+	pathEntry := widget.NewEntry()
+	pathEntry.SetText(a.Path)
+	valueEntry := widget.NewEntry()
+	valueEntry.SetText(a.Value)
+
+	names := make([]string, 0, len(assert.Kinds))
+	for _, k := range assert.Kinds {
+		names = append(names, k.String())
+	}
+	kindSelect := widget.NewSelect(names, func(selected string) {
+		switch kindFromName(selected) {
+		case assert.StatusCode:
+			pathEntry.SetPlaceHolder("")
+			pathEntry.Disable()
+			valueEntry.SetPlaceHolder("OK, NOT_FOUND or a number")
+		case assert.LatencyUnder:
+			pathEntry.SetPlaceHolder("")
+			pathEntry.Disable()
+			valueEntry.SetPlaceHolder("milliseconds")
+		case assert.HeaderPresent:
+			pathEntry.Enable()
+			pathEntry.SetPlaceHolder("header name")
+			valueEntry.SetPlaceHolder("optional expected value")
+		case assert.JSONExists:
+			pathEntry.Enable()
+			pathEntry.SetPlaceHolder("$.items[0].id")
+			valueEntry.SetPlaceHolder("")
+		case assert.JSONMatches:
+			pathEntry.Enable()
+			pathEntry.SetPlaceHolder("$.items[0].id")
+			valueEntry.SetPlaceHolder("regular expression")
+		default:
+			pathEntry.Enable()
+			pathEntry.SetPlaceHolder("$.items[0].id")
+			valueEntry.SetPlaceHolder("expected value")
+		}
+	})
+	kindSelect.SetSelected(a.Kind.String())
+
+	rows.Add(container.New(layout.NewGridLayout(3), kindSelect, pathEntry, valueEntry))
+	assertions = append(assertions, &assertionRow{kindSelect, pathEntry, valueEntry})
+}
+
+func kindFromName(name string) assert.Kind {
+	for _, k := range assert.Kinds {
+		if k.String() == name {
+			return k
+		}
+	}
+	return assert.StatusCode
+}
+
+func getAssertions() []assert.Assertion {
+	var result []assert.Assertion
+	for _, row := range assertions {
+		a := assert.Assertion{Kind: kindFromName(row.kindSelect.Selected), Value: row.valueEntry.Text}
+		if !row.pathEntry.Disabled() {
+			a.Path = row.pathEntry.Text
+		}
+		result = append(result, a)
+	}
+	return result
+}
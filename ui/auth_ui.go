@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"grpc_ui_tool/auth"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+type authEntry struct {
+	label  string
+	value  *string
+	types  []auth.Type
+	secret bool
+	hint   string
+}
+
+// createAuthForm builds the credential provider settings, only the entries used by the selected type are shown.
+// The returned function reads the current settings back from the form
+func (toolUI *UI) createAuthForm(cfg auth.Config) (*fyne.Container, func() auth.Config) {
+	authBox := container.New(layout.NewVBoxLayout())
+	authForm := container.New(layout.NewFormLayout())
+
+	all := []auth.Type{auth.StaticToken, auth.TokenFile, auth.ExecCommand, auth.OAuth2Client}
+	entries := []*authEntry{
+		{"Header", &cfg.Header, all, false, "authorization"},
+		{"Scheme", &cfg.Scheme, all, false, "Bearer"},
+		{"Token", &cfg.Token, []auth.Type{auth.StaticToken}, true, "Token"},
+		{"Token File", &cfg.File, []auth.Type{auth.TokenFile}, false, "/path/to/token"},
+		{"Command", &cfg.Command, []auth.Type{auth.ExecCommand}, false, "command printing an ExecCredential"},
+		{"Token URL", &cfg.TokenURL, []auth.Type{auth.OAuth2Client}, false, "https://auth.example.com/oauth/token"},
+		{"Client ID", &cfg.ClientID, []auth.Type{auth.OAuth2Client}, false, "Client ID"},
+		{"Client Secret", &cfg.ClientSecret, []auth.Type{auth.OAuth2Client}, true, "Client Secret"},
+		{"Scopes", &cfg.Scopes, []auth.Type{auth.OAuth2Client}, false, "scope1 scope2"},
+		{"Audience", &cfg.Audience, []auth.Type{auth.OAuth2Client}, false, "Audience"},
+	}
+
+	widgets := make(map[*authEntry]*widget.Entry)
+	for _, e := range entries {
+		var ent *widget.Entry
+		if e.secret {
+			ent = widget.NewPasswordEntry()
+		} else {
+			ent = widget.NewEntry()
+		}
+		ent.SetPlaceHolder(e.hint)
+		ent.SetText(*e.value)
+		widgets[e] = ent
+	}
+
+	names := make([]string, 0, len(auth.Types))
+	for _, t := range auth.Types {
+		names = append(names, t.String())
+	}
+	typeSelect := widget.NewSelect(names, func(selected string) {
+		for _, t := range auth.Types {
+			if t.String() == selected {
+				cfg.Type = t
+			}
+		}
+		authForm.RemoveAll()
+		for _, e := range entries {
+			for _, t := range e.types {
+				if t == cfg.Type {
+					label := widget.NewLabel(e.label)
+					label.Alignment = fyne.TextAlignTrailing
+					authForm.Add(label)
+					authForm.Add(widgets[e])
+				}
+			}
+		}
+		authForm.Refresh()
+	})
+	typeLabel := toolUI.getFieldLabel("Authentication")
+	authBox.Add(container.New(layout.NewBorderLayout(nil, nil, typeLabel, nil), typeLabel, typeSelect))
+	authBox.Add(authForm)
+	typeSelect.SetSelected(cfg.Type.String())
+
+	return authBox, func() auth.Config {
+		for _, e := range entries {
+			*e.value = widgets[e].Text
+		}
+		return cfg
+	}
+}
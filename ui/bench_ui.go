@@ -0,0 +1,138 @@
+package ui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"grpc_ui_tool/bench"
+	"grpc_ui_tool/proto"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+	grpcmd "google.golang.org/grpc/metadata"
+)
+
+func (toolUI *UI) showBenchmarkDialog(serviceName string, methodName string, jsonRequest string, md grpcmd.MD) {
+	concurrency := widget.NewEntry()
+	concurrency.SetText("10")
+	connections := widget.NewEntry()
+	connections.SetText("1")
+	total := widget.NewEntry()
+	total.SetText("200")
+	duration := widget.NewEntry()
+	duration.SetPlaceHolder("e.g. 30s, empty runs until the total is reached")
+	qps := widget.NewEntry()
+	qps.SetPlaceHolder("empty is unlimited")
+
+	dialog.ShowForm("Benchmark "+methodName, "Run", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Concurrency", concurrency),
+		widget.NewFormItem("Connections", connections),
+		widget.NewFormItem("Total Calls", total),
+		widget.NewFormItem("Duration", duration),
+		widget.NewFormItem("Target QPS", qps),
+	}, func(run bool) {
+		if !run {
+			return
+		}
+		opts, err := parseBenchmarkOptions(concurrency.Text, connections.Text, total.Text, duration.Text, qps.Text)
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		call, err := grpcConn.Prepare(serviceName, methodName, jsonRequest, md)
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		toolUI.runBenchmark(call, opts)
+	}, toolUI.Window)
+}
+
+func parseBenchmarkOptions(concurrency, connections, total, duration, qps string) (bench.Options, error) {
+	var opts bench.Options
+	var err error
+	if opts.Concurrency, err = strconv.Atoi(concurrency); err != nil || opts.Concurrency < 1 {
+		return opts, fmt.Errorf("concurrency must be a positive number")
+	}
+	if opts.Connections, err = strconv.Atoi(connections); err != nil || opts.Connections < 1 {
+		return opts, fmt.Errorf("connections must be a positive number")
+	}
+	if total != "" {
+		if opts.Total, err = strconv.Atoi(total); err != nil || opts.Total < 0 {
+			return opts, fmt.Errorf("total calls must be a number")
+		}
+	}
+	if duration != "" {
+		if opts.Duration, err = time.ParseDuration(duration); err != nil {
+			return opts, fmt.Errorf("duration: %w", err)
+		}
+	}
+	if qps != "" {
+		if opts.QPS, err = strconv.ParseFloat(qps, 64); err != nil || opts.QPS < 0 {
+			return opts, fmt.Errorf("target QPS must be a number")
+		}
+	}
+	if opts.Total == 0 && opts.Duration == 0 {
+		return opts, fmt.Errorf("either a total number of calls or a duration is required")
+	}
+	return opts, nil
+}
+
+func (toolUI *UI) runBenchmark(call *proto.Call, opts bench.Options) {
+	ctx, cancel := context.WithCancel(context.Background())
+	progressLabel := widget.NewLabel("Starting...")
+	progress := dialog.NewCustom("Benchmark Running", "Stop", container.NewVBox(widget.NewProgressBarInfinite(), progressLabel), toolUI.Window)
+	progress.SetOnClosed(cancel)
+	progress.Show()
+
+	go func() {
+		result, err := bench.Run(ctx, grpcConn, call, opts, func(done int) {
+			progressLabel.SetText(fmt.Sprintf("%d calls completed", done))
+		})
+		progress.SetOnClosed(nil)
+		progress.Hide()
+		cancel()
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		toolUI.showBenchmarkResult(result)
+	}()
+}
+
+func (toolUI *UI) showBenchmarkResult(result *bench.Result) {
+	report := widget.NewTextGridFromString(result.Format())
+	saveButton := widget.NewButton("Save JSON", func() {
+		saveDialog := dialog.NewFileSave(func(closer fyne.URIWriteCloser, err error) {
+			if closer == nil {
+				return
+			}
+			if err != nil {
+				dialog.ShowError(err, toolUI.Window)
+				return
+			}
+			encoder := json.NewEncoder(closer)
+			encoder.SetIndent("", "  ")
+			if err = encoder.Encode(result); err != nil {
+				dialog.ShowError(err, toolUI.Window)
+			}
+			if err = closer.Close(); err != nil {
+				dialog.ShowError(err, toolUI.Window)
+			}
+		}, toolUI.Window)
+		saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{".json"}))
+		saveDialog.SetFileName("benchmark.json")
+		saveDialog.Show()
+	})
+	content := container.NewBorder(nil, saveButton, nil, nil, container.NewScroll(report))
+	results := dialog.NewCustom("Benchmark Results", "OK", content, toolUI.Window)
+	size := toolUI.MainContent.Size()
+	results.Resize(fyne.NewSize(size.Width/1.2, size.Height/1.2))
+	results.Show()
+}
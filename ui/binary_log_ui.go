@@ -0,0 +1,277 @@
+package ui
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"grpc_ui_tool/config"
+	"grpc_ui_tool/proto"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	binlogpb "google.golang.org/grpc/binarylog/grpc_binarylog_v1"
+	"google.golang.org/grpc/codes"
+)
+
+// binaryLogPath is the file calls are being written to, empty when not writing a binary log
+var binaryLogPath string
+
+// createBinaryLogButtons returns a button starting and stopping a binary log of the calls made from now on,
+// and one opening a binary log file in the viewer
+func (toolUI *UI) createBinaryLogButtons() (*widget.Button, *widget.Button) {
+	var recordButton *widget.Button
+	updateRecord := func() {
+		if grpcConn.BinaryLog != nil {
+			recordButton.SetText("Stop Binary Log")
+			recordButton.SetIcon(theme.MediaStopIcon())
+		} else {
+			recordButton.SetText("Write Binary Log")
+			recordButton.SetIcon(theme.MediaRecordIcon())
+		}
+	}
+	recordButton = widget.NewButtonWithIcon("", nil, func() {
+		if grpcConn.BinaryLog != nil {
+			err := grpcConn.BinaryLog.Close()
+			grpcConn.SetBinaryLog(nil)
+			updateRecord()
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("binary log %s: %w", binaryLogPath, err), toolUI.Window)
+			} else {
+				dialog.ShowInformation("Binary Log", "The calls were written to "+binaryLogPath, toolUI.Window)
+			}
+			binaryLogPath = ""
+			return
+		}
+		saveDialog := dialog.NewFileSave(func(closer fyne.URIWriteCloser, err error) {
+			if closer == nil {
+				return
+			}
+			if err != nil {
+				dialog.ShowError(err, toolUI.Window)
+				return
+			}
+			// the log is created again so only the user can read it, with the debug log's redaction rules
+			_ = closer.Close()
+			rules, err := config.LoadRedactRules()
+			if err != nil {
+				dialog.ShowError(err, toolUI.Window)
+				return
+			}
+			binaryLog, err := proto.CreateBinaryLog(closer.URI().Path(), rules)
+			if err != nil {
+				dialog.ShowError(err, toolUI.Window)
+				return
+			}
+			binaryLogPath = closer.URI().Path()
+			grpcConn.SetBinaryLog(binaryLog)
+			updateRecord()
+		}, toolUI.Window)
+		saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{".binlog"}))
+		saveDialog.SetFileName("calls.binlog")
+		saveDialog.Show()
+	})
+	updateRecord()
+
+	openButton := widget.NewButtonWithIcon("Open Binary Log", theme.FolderOpenIcon(), func() {
+		openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if reader == nil {
+				return
+			}
+			if err != nil {
+				dialog.ShowError(err, toolUI.Window)
+				return
+			}
+			data, err := io.ReadAll(reader)
+			_ = reader.Close()
+			if err != nil {
+				dialog.ShowError(err, toolUI.Window)
+				return
+			}
+			entries, err := proto.ReadBinaryLog(data)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("invalid binary log %s: %w", reader.URI().Name(), err), toolUI.Window)
+				return
+			}
+			toolUI.showBinaryLogViewer(reader.URI().Name(), entries)
+		}, toolUI.Window)
+		openDialog.SetView(dialog.ListView)
+		openDialog.Show()
+	})
+	return recordButton, openButton
+}
+
+// binaryLogCall is the entries of one call in a binary log, in the order they happened
+type binaryLogCall struct {
+	logger  binlogpb.GrpcLogEntry_Logger
+	id      uint64
+	method  string
+	entries []*binlogpb.GrpcLogEntry
+}
+
+// groupBinaryLogCalls groups entries by call, the client and server loggers of one process number calls separately
+func groupBinaryLogCalls(entries []*binlogpb.GrpcLogEntry) []*binaryLogCall {
+	type callKey struct {
+		logger binlogpb.GrpcLogEntry_Logger
+		id     uint64
+	}
+	var calls []*binaryLogCall
+	byKey := make(map[callKey]*binaryLogCall)
+	for _, entry := range entries {
+		key := callKey{logger: entry.GetLogger(), id: entry.GetCallId()}
+		call, ok := byKey[key]
+		if !ok {
+			call = &binaryLogCall{logger: key.logger, id: key.id}
+			byKey[key] = call
+			calls = append(calls, call)
+		}
+		if header := entry.GetClientHeader(); header != nil {
+			call.method = header.GetMethodName()
+		}
+		call.entries = append(call.entries, entry)
+	}
+	for _, call := range calls {
+		sort.SliceStable(call.entries, func(i, j int) bool {
+			return call.entries[i].GetSequenceIdWithinCall() < call.entries[j].GetSequenceIdWithinCall()
+		})
+	}
+	return calls
+}
+
+// showBinaryLogViewer lists the calls in a binary log and shows every entry of the selected one, decoding the
+// messages with the loaded proto files
+func (toolUI *UI) showBinaryLogViewer(name string, entries []*binlogpb.GrpcLogEntry) {
+	calls := groupBinaryLogCalls(entries)
+	details := widget.NewTextGrid()
+	callList := widget.NewList(func() int {
+		return len(calls)
+	}, func() fyne.CanvasObject {
+		return widget.NewLabel("")
+	}, func(id widget.ListItemID, item fyne.CanvasObject) {
+		call := calls[id]
+		method := call.method
+		if method == "" {
+			method = "unknown method"
+		}
+		item.(*widget.Label).SetText(fmt.Sprintf("%s  %s  %s  %s", formatBinaryLogTime(call.entries[0]), method,
+			strings.TrimPrefix(call.logger.String(), "LOGGER_"), binaryLogCallStatus(call)))
+	})
+	callList.OnSelected = func(id widget.ListItemID) {
+		details.SetText(formatBinaryLogCall(calls[id]))
+	}
+
+	summary := widget.NewLabel(fmt.Sprintf("%d calls in %d entries", len(calls), len(entries)))
+	if grpcConn.FileRegistry == nil {
+		summary.SetText(summary.Text + ", load a proto file to decode the messages")
+	}
+	split := container.NewHSplit(callList, container.NewScroll(details))
+	split.Offset = 0.4
+	content := container.NewBorder(summary, nil, nil, nil, split)
+
+	viewer := dialog.NewCustom("Binary Log "+name, "Close", content, toolUI.Window)
+	size := toolUI.MainContent.Size()
+	viewer.Resize(fyne.NewSize(size.Width/1.05, size.Height/1.05))
+	viewer.Show()
+}
+
+func binaryLogCallStatus(call *binaryLogCall) string {
+	for _, entry := range call.entries {
+		switch {
+		case entry.GetTrailer() != nil:
+			return codes.Code(entry.GetTrailer().GetStatusCode()).String()
+		case entry.GetType() == binlogpb.GrpcLogEntry_EVENT_TYPE_CANCEL:
+			return "cancelled"
+		}
+	}
+	return "incomplete"
+}
+
+func formatBinaryLogTime(entry *binlogpb.GrpcLogEntry) string {
+	if entry.GetTimestamp() == nil {
+		return "--:--:--.---"
+	}
+	return entry.GetTimestamp().AsTime().Local().Format("15:04:05.000")
+}
+
+func formatBinaryLogCall(call *binaryLogCall) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Call: %d logged by the %s\n", call.id,
+		strings.ToLower(strings.TrimPrefix(call.logger.String(), "LOGGER_"))))
+	sb.WriteString("Duration: " + binaryLogDuration(call).String() + "\n")
+	for _, entry := range call.entries {
+		sb.WriteString(fmt.Sprintf("\n#%d %s %s\n", entry.GetSequenceIdWithinCall(),
+			strings.TrimPrefix(entry.GetType().String(), "EVENT_TYPE_"), formatBinaryLogTime(entry)))
+		if peer := entry.GetPeer(); peer != nil {
+			address := peer.GetAddress()
+			if peer.GetIpPort() != 0 {
+				address = net.JoinHostPort(address, strconv.Itoa(int(peer.GetIpPort())))
+			}
+			sb.WriteString("Peer: " + address + "\n")
+		}
+		if entry.GetPayloadTruncated() {
+			sb.WriteString("Payload truncated by the logger\n")
+		}
+		switch {
+		case entry.GetClientHeader() != nil:
+			header := entry.GetClientHeader()
+			sb.WriteString("Method: " + header.GetMethodName() + "\n")
+			if header.GetAuthority() != "" {
+				sb.WriteString("Authority: " + header.GetAuthority() + "\n")
+			}
+			if header.GetTimeout() != nil {
+				sb.WriteString("Timeout: " + header.GetTimeout().AsDuration().String() + "\n")
+			}
+			sb.WriteString(formatMetadataLines("Metadata", proto.DisplayMetadata(proto.BinaryLogMetadata(header.GetMetadata()))))
+		case entry.GetServerHeader() != nil:
+			sb.WriteString(formatMetadataLines("Header",
+				proto.DisplayMetadata(proto.BinaryLogMetadata(entry.GetServerHeader().GetMetadata()))))
+		case entry.GetMessage() != nil:
+			sb.WriteString(formatBinaryLogMessage(call.method, entry))
+		case entry.GetTrailer() != nil:
+			trailer := entry.GetTrailer()
+			sb.WriteString("Status: " + codes.Code(trailer.GetStatusCode()).String() + "\n")
+			if trailer.GetStatusMessage() != "" {
+				sb.WriteString("Message: " + trailer.GetStatusMessage() + "\n")
+			}
+			if len(trailer.GetStatusDetails()) > 0 {
+				sb.WriteString("Details: " + base64.StdEncoding.EncodeToString(trailer.GetStatusDetails()) + "\n")
+			}
+			sb.WriteString(formatMetadataLines("Trailer", proto.DisplayMetadata(proto.BinaryLogMetadata(trailer.GetMetadata()))))
+		}
+	}
+	return sb.String()
+}
+
+// formatBinaryLogMessage decodes a logged message as JSON, falling back to base64 when it cannot be decoded
+func formatBinaryLogMessage(method string, entry *binlogpb.GrpcLogEntry) string {
+	message := entry.GetMessage()
+	size := fmt.Sprintf("Length: %d bytes", message.GetLength())
+	if int(message.GetLength()) != len(message.GetData()) {
+		size += fmt.Sprintf(", %d logged", len(message.GetData()))
+	}
+	request := entry.GetType() == binlogpb.GrpcLogEntry_EVENT_TYPE_CLIENT_MESSAGE
+	if decoded, err := grpcConn.DecodeMessage(method, request, message.GetData()); err == nil {
+		return size + "\n" + decoded + "\n"
+	} else if !entry.GetPayloadTruncated() {
+		size += fmt.Sprintf("\nNot decoded: %v", err)
+	}
+	return size + "\n" + base64.StdEncoding.EncodeToString(message.GetData()) + "\n"
+}
+
+// binaryLogDuration is how long a call took from its first to its last logged entry
+func binaryLogDuration(call *binaryLogCall) time.Duration {
+	first, last := call.entries[0].GetTimestamp(), call.entries[len(call.entries)-1].GetTimestamp()
+	if first == nil || last == nil {
+		return 0
+	}
+	return last.AsTime().Sub(first.AsTime())
+}
@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"grpc_ui_tool/capture"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+type captureRow struct {
+	sourceSelect    *widget.Select
+	expressionEntry *widget.Entry
+	variableEntry   *widget.Entry
+}
+
+var captures []*captureRow
+
+// createCapturesSection builds the rows that store values from the response into variables for later requests in a collection
+func (toolUI *UI) createCapturesSection(existing []capture.Capture) *fyne.Container {
+	captures = nil
+	box := container.New(layout.NewVBoxLayout())
+
+	label := widget.NewLabel("Captures")
+	label.Alignment = fyne.TextAlignCenter
+	label.TextStyle = fyne.TextStyle{Bold: true}
+	box.Add(label)
+
+	rows := container.New(layout.NewVBoxLayout())
+	box.Add(rows)
+
+	addButton := widget.NewButtonWithIcon("", theme.ContentAddIcon(), func() {
+		toolUI.addCaptureRow(rows, capture.Capture{Source: capture.JSONPath})
+	})
+	clearButton := widget.NewButtonWithIcon("", theme.ContentClearIcon(), func() {
+		rows.RemoveAll()
+		captures = nil
+	})
+	buttonBox := container.New(layout.NewHBoxLayout(), addButton, clearButton)
+	box.Add(container.New(layout.NewBorderLayout(nil, nil, nil, buttonBox), buttonBox))
+
+	for _, c := range existing {
+		toolUI.addCaptureRow(rows, c)
+	}
+	return box
+}
+
+func (toolUI *UI) addCaptureRow(rows *fyne.Container, c capture.Capture) {
+	expressionEntry := widget.NewEntry()
+	expressionEntry.SetText(c.Expression)
+	variableEntry := widget.NewEntry()
+	variableEntry.SetPlaceHolder("variable name")
+	variableEntry.SetText(c.Variable)
+
+	names := make([]string, 0, len(capture.Sources))
+	for _, s := range capture.Sources {
+		names = append(names, s.String())
+	}
+	sourceSelect := widget.NewSelect(names, func(selected string) {
+		if sourceFromName(selected) == capture.CEL {
+			expressionEntry.SetPlaceHolder("response.items[0].id")
+		} else {
+			expressionEntry.SetPlaceHolder("$.items[0].id")
+		}
+	})
+	if c.Source == "" {
+		c.Source = capture.JSONPath
+	}
+	sourceSelect.SetSelected(c.Source.String())
+
+	rows.Add(container.New(layout.NewGridLayout(3), sourceSelect, expressionEntry, variableEntry))
+	captures = append(captures, &captureRow{sourceSelect, expressionEntry, variableEntry})
+}
+
+func sourceFromName(name string) capture.Source {
+	for _, s := range capture.Sources {
+		if s.String() == name {
+			return s
+		}
+	}
+	return capture.JSONPath
+}
+
+func getCaptures() []capture.Capture {
+	var result []capture.Capture
+	for _, row := range captures {
+		if row.variableEntry.Text == "" || row.expressionEntry.Text == "" {
+			continue
+		}
+		result = append(result, capture.Capture{
+			Variable:   row.variableEntry.Text,
+			Source:     sourceFromName(row.sourceSelect.Selected),
+			Expression: row.expressionEntry.Text,
+		})
+	}
+	return result
+}
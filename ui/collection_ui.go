@@ -0,0 +1,159 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"grpc_ui_tool/assert"
+	"grpc_ui_tool/capture"
+	"grpc_ui_tool/config"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+	grpcmd "google.golang.org/grpc/metadata"
+)
+
+// showSaveRequestDialog asks for a name and collection file and appends the request to it, creating the collection
+// with the current server and proto files if it does not exist yet
+func (toolUI *UI) showSaveRequestDialog(serviceName string, methodName string, jsonRequest string, md grpcmd.MD,
+	reqAssertions []assert.Assertion, reqCaptures []capture.Capture) {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(methodName)
+	dialog.ShowForm("Save Request", "Choose Collection", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+		saved := &config.SavedRequest{
+			Name:       nameEntry.Text,
+			Service:    serviceName,
+			Method:     methodName,
+			Body:       redactSecrets(jsonRequest),
+			Metadata:   grpcmd.MD{},
+			Assertions: reqAssertions,
+			Captures:   reqCaptures,
+		}
+		for key, values := range md {
+			for _, value := range values {
+				saved.Metadata[key] = append(saved.Metadata[key], redactSecrets(value))
+			}
+		}
+
+		saveDialog := dialog.NewFileSave(func(closer fyne.URIWriteCloser, err error) {
+			if closer == nil {
+				return
+			}
+			if err != nil {
+				dialog.ShowError(err, toolUI.Window)
+				return
+			}
+			path := closer.URI().Path()
+			_ = closer.Close()
+			if err = toolUI.appendToCollection(path, saved); err != nil {
+				dialog.ShowError(err, toolUI.Window)
+			}
+		}, toolUI.Window)
+		saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{".gtcollection"}))
+		saveDialog.SetFileName("requests.gtcollection")
+		saveDialog.SetView(dialog.ListView)
+		saveDialog.Show()
+	}, toolUI.Window)
+}
+
+func (toolUI *UI) appendToCollection(path string, saved *config.SavedRequest) error {
+	collection, err := config.LoadCollection(path)
+	if err != nil {
+		info, statErr := os.Stat(path)
+		if statErr == nil && info.Size() > 0 {
+			return err
+		}
+		collection = &config.Collection{
+			Name:   strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+			Server: relativePath(path, toolUI.ServerFile),
+			Proto:  relativePath(path, toolUI.ProtoFile),
+		}
+		for _, importPath := range toolUI.ImportPaths {
+			collection.ImportPaths = append(collection.ImportPaths, relativePath(path, importPath))
+		}
+	}
+	collection.Requests = append(collection.Requests, saved)
+	return config.SaveCollection(path, collection)
+}
+
+// relativePath makes path relative to the directory of the collection file so collections can be moved together with their protos
+func relativePath(collectionPath string, path string) string {
+	if path == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(filepath.Dir(collectionPath), path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// prepareCollection loads the server and protos referenced by a collection when none have been set up in the UI yet
+func (toolUI *UI) prepareCollection(path string, collection *config.Collection) error {
+	if grpcConn.Hostname == "" && len(grpcConn.Addresses) == 0 && collection.Server != "" {
+		server, err := config.LoadServer(config.ResolvePath(path, collection.Server))
+		if err != nil {
+			return err
+		}
+		grpcConn.SetConnectionDetails(server.Hostname, server.Port, server.Metadata)
+		grpcConn.SetAddresses(server.Addresses, server.LoadBalancing)
+		grpcConn.SetAuth(server.Auth)
+		grpcConn.SetTLS(server.TLS)
+		grpcConn.SetTransport(server.Transport)
+		grpcConn.SetRoute(server.Route)
+		grpcConn.SetTuning(server.Tuning)
+		grpcConn.SetTrace(server.Trace)
+		grpcConn.SetServiceConfig(server.ServiceConfig)
+		toolUI.ServerLabel.SetText(serverLabel(server.Hostname, server.Addresses))
+	}
+	if grpcConn.FileRegistry == nil {
+		if collection.Proto == "" {
+			return fmt.Errorf("collection %s has no proto file, load one first", collection.Name)
+		}
+		var importPaths []string
+		for _, importPath := range collection.ImportPaths {
+			importPaths = append(importPaths, config.ResolvePath(path, importPath))
+		}
+		if err := grpcConn.LoadRegistry(importPaths, config.ResolvePath(path, collection.Proto)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (toolUI *UI) showRunCollectionDialog() {
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if reader == nil {
+			return
+		}
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		path := reader.URI().Path()
+		_ = reader.Close()
+
+		collection, err := config.LoadCollection(path)
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		if err = toolUI.prepareCollection(path, collection); err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		toolUI.showWorkflowDialog(collection)
+	}, toolUI.Window)
+	openDialog.SetFilter(storage.NewExtensionFileFilter([]string{".gtcollection"}))
+	openDialog.SetView(dialog.ListView)
+	openDialog.Show()
+}
@@ -0,0 +1,235 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"grpc_ui_tool/config"
+	"grpc_ui_tool/proto"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// createDebugLogButton starts recording every call in the debug log, with the stored redaction rules
+func (toolUI *UI) createDebugLogButton() {
+	rules, err := config.LoadRedactRules()
+	if err != nil {
+		rules = proto.DefaultRedactRules
+		defer dialog.ShowError(err, toolUI.Window)
+	}
+	debugLog, err := proto.NewDebugLog(rules)
+	if err != nil {
+		debugLog, _ = proto.NewDebugLog(proto.DefaultRedactRules)
+		defer dialog.ShowError(err, toolUI.Window)
+	}
+	grpcConn.SetDebugLog(debugLog)
+
+	toolUI.DebugLogButton = widget.NewButtonWithIcon("", theme.InfoIcon(), func() {
+		toolUI.showDebugLogDialog()
+	})
+}
+
+// showDebugLogDialog lists the calls in the debug log as they happen, newest last, showing what was sent and
+// received for the selected one. The filter matches anywhere in a call's details
+func (toolUI *UI) showDebugLogDialog() {
+	debugLog := grpcConn.DebugLog
+	// filtered is replaced from the goroutines making calls while the list reads it
+	var filteredMu sync.Mutex
+	var filtered []proto.DebugEntry
+	filterEntry := widget.NewEntry()
+	filterEntry.SetPlaceHolder("Filter by method, status, metadata or message")
+	applyFilter := func() {
+		filter := strings.ToLower(filterEntry.Text)
+		var matched []proto.DebugEntry
+		for _, entry := range debugLog.Entries() {
+			if strings.Contains(strings.ToLower(formatDebugEntry(entry)), filter) {
+				matched = append(matched, entry)
+			}
+		}
+		filteredMu.Lock()
+		filtered = matched
+		filteredMu.Unlock()
+	}
+	entries := func() []proto.DebugEntry {
+		filteredMu.Lock()
+		defer filteredMu.Unlock()
+		return filtered
+	}
+	applyFilter()
+
+	selected := -1
+	details := widget.NewTextGrid()
+	entryList := widget.NewList(func() int {
+		return len(entries())
+	}, func() fyne.CanvasObject {
+		return widget.NewLabel("")
+	}, func(id widget.ListItemID, item fyne.CanvasObject) {
+		list := entries()
+		if id >= len(list) {
+			return
+		}
+		entry := list[id]
+		item.(*widget.Label).SetText(fmt.Sprintf("%s  %s  %s  %s", entry.Time.Format("15:04:05.000"), entry.Method,
+			entry.Code, formatMillis(entry.Duration)))
+	})
+	entryList.OnSelected = func(id widget.ListItemID) {
+		if list := entries(); id < len(list) {
+			selected = id
+			details.SetText(formatDebugEntry(list[id]))
+		}
+	}
+	// new calls are appended so the selection stays on the same call unless the filter changed or the log was cleared
+	refresh := func(keepSelection bool) {
+		applyFilter()
+		if !keepSelection || selected >= len(entries()) {
+			selected = -1
+			entryList.UnselectAll()
+			details.SetText("")
+		}
+		entryList.Refresh()
+		entryList.ScrollToBottom()
+	}
+	filterEntry.OnChanged = func(string) {
+		refresh(false)
+	}
+	debugLog.OnEntry(func() {
+		refresh(true)
+	})
+
+	clearButton := widget.NewButtonWithIcon("Clear", theme.DeleteIcon(), func() {
+		debugLog.Clear()
+	})
+	saveButton := widget.NewButtonWithIcon("Save", theme.DocumentSaveIcon(), func() {
+		saved := entries()
+		saveDialog := dialog.NewFileSave(func(closer fyne.URIWriteCloser, err error) {
+			if closer == nil {
+				return
+			}
+			if err != nil {
+				dialog.ShowError(err, toolUI.Window)
+				return
+			}
+			for _, entry := range saved {
+				if _, err = io.WriteString(closer, formatDebugEntry(entry)+"\n"); err != nil {
+					break
+				}
+			}
+			if err != nil {
+				dialog.ShowError(err, toolUI.Window)
+			}
+			if err = closer.Close(); err != nil {
+				dialog.ShowError(err, toolUI.Window)
+			}
+		}, toolUI.Window)
+		saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{".log", ".txt"}))
+		saveDialog.SetFileName("grpc-debug.log")
+		saveDialog.Show()
+	})
+	rulesButton := widget.NewButtonWithIcon("Redaction Rules", theme.VisibilityOffIcon(), func() {
+		toolUI.showRedactRulesDialog(debugLog)
+	})
+
+	binaryLogButton, openBinaryLogButton := toolUI.createBinaryLogButtons()
+
+	split := container.NewHSplit(entryList, container.NewScroll(details))
+	split.Offset = 0.4
+	buttons := container.NewHBox(clearButton, saveButton, rulesButton, widget.NewSeparator(), binaryLogButton, openBinaryLogButton)
+	content := container.NewBorder(filterEntry, buttons, nil, nil, split)
+
+	logDialog := dialog.NewCustom("Debug Log", "Close", content, toolUI.Window)
+	logDialog.SetOnClosed(func() {
+		debugLog.OnEntry(nil)
+	})
+	size := toolUI.MainContent.Size()
+	logDialog.Resize(fyne.NewSize(size.Width/1.05, size.Height/1.05))
+	logDialog.Show()
+	entryList.ScrollToBottom()
+}
+
+// showRedactRulesDialog edits the redaction rules, one per line as a header name pattern optionally followed by a
+// regular expression for the part of the value to hide
+func (toolUI *UI) showRedactRulesDialog(debugLog *proto.DebugLog) {
+	rules, err := config.LoadRedactRules()
+	if err != nil {
+		dialog.ShowError(err, toolUI.Window)
+		return
+	}
+	lines := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		lines = append(lines, strings.TrimSpace(rule.Header+" "+rule.Pattern))
+	}
+	rulesEntry := widget.NewMultiLineEntry()
+	rulesEntry.SetText(strings.Join(lines, "\n"))
+	rulesEntry.SetMinRowsVisible(8)
+	help := widget.NewLabel("One rule per line: a header name, * matches any characters, optionally followed by a " +
+		"regular expression matching the part of the value to hide. Without one the whole value is hidden.")
+	help.Wrapping = fyne.TextWrapWord
+
+	rulesDialog := dialog.NewCustomConfirm("Redaction Rules", "Save", "Cancel",
+		container.NewBorder(help, nil, nil, nil, rulesEntry), func(save bool) {
+			if !save {
+				return
+			}
+			rules, err := parseRedactRules(rulesEntry.Text)
+			if err == nil {
+				err = debugLog.SetRedactRules(rules)
+			}
+			if err == nil {
+				err = config.SaveRedactRules(rules)
+			}
+			if err != nil {
+				dialog.ShowError(err, toolUI.Window)
+			}
+		}, toolUI.Window)
+	size := toolUI.MainContent.Size()
+	rulesDialog.Resize(fyne.NewSize(size.Width/1.5, size.Height/1.5))
+	rulesDialog.Show()
+}
+
+func parseRedactRules(text string) ([]proto.RedactRule, error) {
+	rules := []proto.RedactRule{}
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		header, pattern, _ := strings.Cut(line, " ")
+		rule := proto.RedactRule{Header: header, Pattern: strings.TrimSpace(pattern)}
+		if err := rule.Check(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func formatDebugEntry(entry proto.DebugEntry) string {
+	var sb strings.Builder
+	sb.WriteString("Time: " + entry.Time.Format(time.RFC3339Nano) + "\n")
+	method := entry.Method
+	if entry.Stream {
+		method += " (stream)"
+	}
+	sb.WriteString("Method: " + method + "\n")
+	sb.WriteString("Status: " + entry.Code.String() + "\n")
+	if entry.Message != "" {
+		sb.WriteString("Message: " + entry.Message + "\n")
+	}
+	sb.WriteString("Duration: " + entry.Duration.String() + "\n")
+	sb.WriteString(formatMetadataLines("Metadata", entry.Metadata))
+	for i, request := range entry.Requests {
+		sb.WriteString(fmt.Sprintf("\nRequest %d:\n%s\n", i+1, request))
+	}
+	for i, response := range entry.Responses {
+		sb.WriteString(fmt.Sprintf("\nResponse %d:\n%s\n", i+1, response))
+	}
+	return sb.String()
+}
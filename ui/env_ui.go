@@ -0,0 +1,232 @@
+package ui
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"grpc_ui_tool/config"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const noEnvironment = "No Environment"
+
+type variablePair struct {
+	keyEntry   *widget.Entry
+	valueEntry *widget.Entry
+}
+
+var environments *config.Environments
+
+func (toolUI *UI) createEnvironmentSelect() {
+	var err error
+	environments, err = config.LoadEnvironments()
+	if err != nil {
+		environments = &config.Environments{}
+		defer dialog.ShowError(err, toolUI.Window)
+	}
+
+	toolUI.EnvironmentSelect = widget.NewSelect([]string{}, func(selected string) {
+		if selected == noEnvironment {
+			selected = ""
+		}
+		if selected == environments.Active {
+			return
+		}
+		environments.Active = selected
+		grpcConn.SetVariables(environments.ActiveVariables())
+		if err := config.SaveEnvironments(environments); err != nil {
+			dialog.ShowError(err, toolUI.Window)
+		}
+	})
+	toolUI.EnvironmentSelect.PlaceHolder = noEnvironment
+
+	toolUI.EnvironmentButton = widget.NewButtonWithIcon("", theme.SettingsIcon(), func() {
+		toolUI.showEnvironmentDialog()
+	})
+
+	toolUI.refreshEnvironmentSelect()
+}
+
+func (toolUI *UI) refreshEnvironmentSelect() {
+	toolUI.EnvironmentSelect.SetOptions(append([]string{noEnvironment}, environments.Names()...))
+	if environments.Active == "" {
+		toolUI.EnvironmentSelect.SetSelected(noEnvironment)
+	} else {
+		toolUI.EnvironmentSelect.SetSelected(environments.Active)
+	}
+	grpcConn.SetVariables(environments.ActiveVariables())
+}
+
+func (toolUI *UI) showEnvironmentDialog() {
+	var variables []*variablePair
+	var editing *config.Environment
+	var envSelect *widget.Select
+
+	working := &config.Environments{Active: environments.Active}
+	for _, env := range environments.List {
+		vars := make(map[string]string, len(env.Variables))
+		for key, value := range env.Variables {
+			vars[key] = value
+		}
+		working.List = append(working.List, &config.Environment{Name: env.Name, Variables: vars})
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Environment Name")
+	nameLabel := toolUI.getFieldLabel("Name")
+	nameItem := container.New(layout.NewBorderLayout(nil, nil, nameLabel, nil), nameLabel, nameEntry)
+
+	varGrid := container.New(layout.NewGridLayout(2))
+	addVariable := func(key string, value string) {
+		vke := widget.NewEntry()
+		vke.SetText(key)
+		vke.SetPlaceHolder("Variable Name")
+		keyLabel := toolUI.getFieldLabel("Name")
+		vve := widget.NewEntry()
+		vve.SetText(value)
+		vve.SetPlaceHolder("Variable Value")
+		valueLabel := toolUI.getFieldLabel("Value")
+		varGrid.Add(container.New(layout.NewBorderLayout(nil, nil, keyLabel, nil), keyLabel, vke))
+		varGrid.Add(container.New(layout.NewBorderLayout(nil, nil, valueLabel, nil), valueLabel, vve))
+		variables = append(variables, &variablePair{vke, vve})
+	}
+
+	commit := func() error {
+		if editing == nil {
+			return nil
+		}
+		name := nameEntry.Text
+		if name == "" || name == noEnvironment {
+			return fmt.Errorf("invalid environment name %q", name)
+		}
+		if other := working.Get(name); other != nil && other != editing {
+			return fmt.Errorf("environment %q already exists", name)
+		}
+		if working.Active == editing.Name {
+			working.Active = name
+		}
+		editing.Name = name
+		editing.Variables = make(map[string]string)
+		for _, pair := range variables {
+			if pair.keyEntry.Text != "" {
+				editing.Variables[pair.keyEntry.Text] = pair.valueEntry.Text
+			}
+		}
+		return nil
+	}
+
+	load := func(env *config.Environment) {
+		editing = env
+		varGrid.RemoveAll()
+		variables = nil
+		nameEntry.SetText("")
+		if env == nil {
+			return
+		}
+		nameEntry.SetText(env.Name)
+		for _, key := range slices.Sorted(maps.Keys(env.Variables)) {
+			addVariable(key, env.Variables[key])
+		}
+	}
+
+	envSelect = widget.NewSelect(working.Names(), func(selected string) {
+		if editing != nil && editing.Name == selected {
+			return
+		}
+		if err := commit(); err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			envSelect.SetSelected(editing.Name)
+			return
+		}
+		envSelect.SetOptions(working.Names())
+		load(working.Get(selected))
+	})
+	envSelectLabel := toolUI.getFieldLabel("Environment")
+	envSelectItem := container.New(layout.NewBorderLayout(nil, nil, envSelectLabel, nil), envSelectLabel, envSelect)
+
+	newEnvButton := widget.NewButtonWithIcon("New", theme.ContentAddIcon(), func() {
+		if err := commit(); err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		name := "environment"
+		for i := 2; working.Get(name) != nil; i++ {
+			name = fmt.Sprintf("environment %d", i)
+		}
+		working.List = append(working.List, &config.Environment{Name: name, Variables: make(map[string]string)})
+		editing = nil
+		envSelect.SetOptions(working.Names())
+		envSelect.SetSelected(name)
+	})
+	deleteEnvButton := widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), func() {
+		if editing == nil {
+			return
+		}
+		for i, env := range working.List {
+			if env == editing {
+				working.List = append(working.List[:i], working.List[i+1:]...)
+				break
+			}
+		}
+		if working.Active == editing.Name {
+			working.Active = ""
+		}
+		load(nil)
+		envSelect.ClearSelected()
+		envSelect.SetOptions(working.Names())
+	})
+
+	addVariableButton := widget.NewButtonWithIcon("", theme.ContentAddIcon(), func() {
+		if editing != nil {
+			addVariable("", "")
+		}
+	})
+	clearVariablesButton := widget.NewButtonWithIcon("", theme.ContentClearIcon(), func() {
+		varGrid.RemoveAll()
+		variables = nil
+	})
+	variableButtonBox := container.New(layout.NewHBoxLayout(), addVariableButton, clearVariablesButton)
+
+	envButtonBox := container.New(layout.NewHBoxLayout(), newEnvButton, deleteEnvButton)
+	content := container.New(layout.NewVBoxLayout(),
+		container.New(layout.NewBorderLayout(nil, nil, nil, envButtonBox), envButtonBox, envSelectItem),
+		nameItem,
+		widget.NewSeparator(),
+		varGrid,
+		container.New(layout.NewBorderLayout(nil, nil, nil, variableButtonBox), variableButtonBox),
+	)
+
+	if working.Active != "" {
+		envSelect.SetSelected(working.Active)
+	} else if len(working.List) > 0 {
+		envSelect.SetSelected(working.List[0].Name)
+	}
+
+	var envDialog *dialog.ConfirmDialog
+	envDialog = dialog.NewCustomConfirm("Environments", "Save", "Cancel", container.NewScroll(content), func(save bool) {
+		if !save {
+			return
+		}
+		if err := commit(); err != nil {
+			// the dialog has already closed, open it again with the edits so they can be corrected
+			envDialog.Show()
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		environments = working
+		if err := config.SaveEnvironments(environments); err != nil {
+			dialog.ShowError(err, toolUI.Window)
+		}
+		toolUI.refreshEnvironmentSelect()
+	}, toolUI.Window)
+	size := toolUI.MainContent.Size()
+	envDialog.Resize(fyne.NewSize(size.Width/1.2, size.Height/1.2))
+	envDialog.Show()
+}
@@ -0,0 +1,90 @@
+package ui
+
+import (
+	"strings"
+
+	"grpc_ui_tool/auth"
+	"grpc_ui_tool/expand"
+	"grpc_ui_tool/export"
+	"grpc_ui_tool/proto"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	grpcmd "google.golang.org/grpc/metadata"
+)
+
+// showExportDialog shows the request as a grpcurl command and Go and Python clients, variables are evaluated as if
+// the request was sent but secrets are shown as references
+func (toolUI *UI) showExportDialog(serviceName string, methodName string, jsonRequest string, md grpcmd.MD) {
+	call, err := grpcConn.Prepare(serviceName, methodName, jsonRequest, md)
+	if err != nil {
+		dialog.ShowError(err, toolUI.Window)
+		return
+	}
+
+	metadata := map[string][]string{}
+	for key, values := range call.Metadata {
+		for _, value := range values {
+			metadata[key] = append(metadata[key], redactSecrets(value))
+		}
+	}
+	// only a static token can be exported, the other credential types fetch short lived tokens at call time
+	if grpcConn.Auth.Type == auth.StaticToken {
+		header := strings.ToLower(grpcConn.Auth.Header)
+		if header == "" {
+			header = "authorization"
+		}
+		token := grpcConn.Auth.Token
+		if grpcConn.Auth.Scheme != "" {
+			token = grpcConn.Auth.Scheme + " " + token
+		}
+		if expanded, err := expand.String(token, grpcConn.Variables); err == nil {
+			token = expanded
+		}
+		metadata[header] = []string{redactSecrets(token)}
+	}
+
+	target := call.Target
+	// the generated clients have no equivalent of a static address list, they call the first address
+	if len(grpcConn.Addresses) > 0 {
+		target, _, _ = strings.Cut(strings.TrimPrefix(target, "static:///"), ",")
+	}
+	req := &export.Request{
+		Target:      target,
+		Method:      call.Descriptor(),
+		Body:        redactSecrets(call.Request),
+		Metadata:    metadata,
+		ProtoFile:   toolUI.ProtoFile,
+		ImportPaths: toolUI.ImportPaths,
+		Plaintext:   grpcConn.TLS.Mode == proto.TLSPlaintext,
+		Insecure:    grpcConn.TLS.Mode == proto.TLSInsecure || grpcConn.TLS.Mode == proto.TLSTrustOnFirstUse,
+		CACert:      grpcConn.TLS.CACert,
+
+		Authority:      grpcConn.Tuning.Authority,
+		UserAgent:      grpcConn.Tuning.UserAgent,
+		MaxReceiveSize: grpcConn.Tuning.MaxReceiveSize,
+		KeepaliveTime:  grpcConn.Tuning.KeepaliveTime,
+	}
+	tabs := container.NewAppTabs(
+		toolUI.exportTab("grpcurl", export.Grpcurl(req)),
+		toolUI.exportTab("Go", export.Go(req)),
+		toolUI.exportTab("Python", export.Python(req)),
+	)
+
+	exportDialog := dialog.NewCustom("Export "+methodName, "Close", tabs, toolUI.Window)
+	size := toolUI.MainContent.Size()
+	exportDialog.Resize(fyne.NewSize(size.Width/1.1, size.Height/1.1))
+	exportDialog.Show()
+}
+
+func (toolUI *UI) exportTab(name string, text string) *container.TabItem {
+	copyButton := widget.NewButtonWithIcon("Copy", theme.ContentCopyIcon(), func() {
+		toolUI.Window.Clipboard().SetContent(text)
+	})
+	content := container.NewBorder(nil, container.NewHBox(copyButton), nil, nil,
+		container.NewScroll(widget.NewTextGridFromString(text)))
+	return container.NewTabItem(name, content)
+}
@@ -0,0 +1,113 @@
+package ui
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"grpc_ui_tool/history"
+	"grpc_ui_tool/proto"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	grpcmd "google.golang.org/grpc/metadata"
+)
+
+const historyLimit = 200
+
+func (toolUI *UI) recordHistory(serviceName string, methodName string, resp *proto.Response, sendErr error) {
+	if resp == nil {
+		return
+	}
+	entry := &history.Entry{
+		Time:     time.Now(),
+		Target:   resp.Target,
+		Service:  serviceName,
+		Method:   methodName,
+		Metadata: redactHistoryMetadata(resp.Metadata),
+		Request:  redactSecrets(resp.Request),
+		Response: redactSecrets(resp.Body),
+		Duration: resp.Duration,
+	}
+	if sendErr != nil {
+		entry.Error = redactSecrets(sendErr.Error())
+	}
+	if err := history.Record(entry); err != nil {
+		dialog.ShowError(err, toolUI.Window)
+	}
+}
+
+// redactHistoryMetadata replaces secrets in the metadata of a call with their references, and hides the values of
+// sensitive headers that hold no reference the way the debug log does
+func redactHistoryMetadata(md grpcmd.MD) map[string][]string {
+	hidden := md
+	if grpcConn.DebugLog != nil {
+		hidden = grpcConn.DebugLog.Redact(md)
+	}
+	redacted := make(map[string][]string, len(md))
+	for key, values := range md {
+		for i, value := range values {
+			if value = redactSecrets(value); !strings.Contains(value, "{{") {
+				value = hidden[key][i]
+			}
+			redacted[key] = append(redacted[key], value)
+		}
+	}
+	return redacted
+}
+
+func (toolUI *UI) showHistoryDialog() {
+	entries, err := history.Load(historyLimit)
+	if err != nil {
+		dialog.ShowError(err, toolUI.Window)
+		return
+	}
+
+	details := widget.NewTextGrid()
+	list := widget.NewList(func() int {
+		return len(entries)
+	}, func() fyne.CanvasObject {
+		return widget.NewLabel("")
+	}, func(id widget.ListItemID, item fyne.CanvasObject) {
+		entry := entries[id]
+		status := "OK"
+		if entry.Error != "" {
+			status = "Error"
+		}
+		item.(*widget.Label).SetText(entry.Time.Format("2006-01-02 15:04:05") + "  " + entry.Method + "  " + status)
+	})
+	list.OnSelected = func(id widget.ListItemID) {
+		details.SetText(formatHistoryEntry(entries[id]))
+	}
+
+	split := container.NewHSplit(list, container.NewScroll(details))
+	split.Offset = 0.35
+
+	historyDialog := dialog.NewCustom("History", "Close", split, toolUI.Window)
+	size := toolUI.MainContent.Size()
+	historyDialog.Resize(fyne.NewSize(size.Width/1.1, size.Height/1.1))
+	historyDialog.Show()
+}
+
+func formatHistoryEntry(entry *history.Entry) string {
+	var sb strings.Builder
+	sb.WriteString("Time: " + entry.Time.Format(time.RFC3339) + "\n")
+	sb.WriteString("Target: " + entry.Target + "\n")
+	sb.WriteString("Method: " + entry.Service + "/" + entry.Method + "\n")
+	sb.WriteString("Duration: " + entry.Duration.String() + "\n")
+	for _, key := range slices.Sorted(maps.Keys(entry.Metadata)) {
+		for _, value := range entry.Metadata[key] {
+			sb.WriteString("Metadata: " + key + ": " + value + "\n")
+		}
+	}
+	sb.WriteString("\nRequest:\n" + entry.Request + "\n")
+	if entry.Error != "" {
+		sb.WriteString("\nError:\n" + entry.Error + "\n")
+	} else {
+		sb.WriteString("\nResponse:\n" + entry.Response + "\n")
+	}
+	return sb.String()
+}
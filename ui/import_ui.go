@@ -0,0 +1,292 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"grpc_ui_tool/auth"
+	"grpc_ui_tool/config"
+	"grpc_ui_tool/importer"
+	"grpc_ui_tool/proto"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	grpcmd "google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// showImportDialog asks for a grpcurl command, which opens the request it describes, or a Postman or Insomnia
+// export, which is converted into collections
+func (toolUI *UI) showImportDialog() {
+	commandEntry := widget.NewMultiLineEntry()
+	commandEntry.SetPlaceHolder("grpcurl -plaintext -proto api.proto -H 'x-user: 1' -d '{\"id\": 1}' localhost:50051 pkg.Service/Method")
+	commandEntry.SetMinRowsVisible(6)
+	commandEntry.Wrapping = fyne.TextWrapWord
+	dirEntry := widget.NewEntry()
+	dirEntry.SetText(importDirectory(toolUI.ProtoFile))
+	dirItem := widget.NewFormItem("Directory", dirEntry)
+	dirItem.HintText = "relative paths in the command are taken from here"
+	grpcurlForm := widget.NewForm(widget.NewFormItem("Command", commandEntry), dirItem)
+
+	formatSelect := widget.NewSelect([]string{postmanFormat, insomniaFormat}, nil)
+	formatSelect.SetSelected(postmanFormat)
+	exportEntry := widget.NewEntry()
+	exportEntry.SetPlaceHolder("exported collection .json")
+	outputEntry := widget.NewEntry()
+	outputEntry.SetText(importDirectory(toolUI.ProtoFile))
+	protoEntry := widget.NewEntry()
+	protoEntry.SetPlaceHolder("used by requests without a proto in the export")
+	protoEntry.SetText(toolUI.ProtoFile)
+	environmentCheck := widget.NewCheck("Add the collection's variables as an environment", nil)
+	environmentCheck.SetChecked(true)
+	outputItem := widget.NewFormItem("Save To", outputEntry)
+	outputItem.HintText = "collections, server files and embedded protos are written here"
+	collectionForm := widget.NewForm(
+		widget.NewFormItem("Format", formatSelect),
+		widget.NewFormItem("Export File", toolUI.withFileButton(exportEntry, []string{".json"})),
+		outputItem,
+		widget.NewFormItem("Proto File", toolUI.withFileButton(protoEntry, []string{".proto"})),
+		widget.NewFormItem("", environmentCheck),
+	)
+
+	grpcurlTab := container.NewTabItem("grpcurl Command", grpcurlForm)
+	tabs := container.NewAppTabs(grpcurlTab, container.NewTabItem("Postman / Insomnia", collectionForm))
+	importDialog := dialog.NewCustomConfirm("Import", "Import", "Cancel", tabs, func(ok bool) {
+		if !ok {
+			return
+		}
+		if tabs.Selected() != grpcurlTab {
+			importPaths := []string(nil)
+			if protoEntry.Text == toolUI.ProtoFile {
+				importPaths = toolUI.ImportPaths
+			}
+			toolUI.importCollection(formatSelect.Selected, exportEntry.Text, outputEntry.Text, protoEntry.Text, importPaths,
+				environmentCheck.Checked)
+			return
+		}
+		cmd, err := importer.ParseGrpcurl(commandEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		cmd.ResolvePaths(dirEntry.Text)
+		if err = toolUI.openGrpcurlCommand(cmd); err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		if len(cmd.Ignored) > 0 {
+			dialog.ShowInformation("Import", "These flags have no equivalent and were ignored: "+
+				strings.Join(cmd.Ignored, ", "), toolUI.Window)
+		}
+	}, toolUI.Window)
+	size := toolUI.MainContent.Size()
+	importDialog.Resize(fyne.NewSize(size.Width/1.1, importDialog.MinSize().Height))
+	importDialog.Show()
+}
+
+const (
+	postmanFormat  = "Postman Collection"
+	insomniaFormat = "Insomnia Export (v4 JSON)"
+)
+
+// importCollection converts a Postman or Insomnia export into collections and server files in outputDir and
+// reports what was written and every item that could not be mapped
+func (toolUI *UI) importCollection(format string, exportFile string, outputDir string, protoFile string, importPaths []string,
+	addEnvironment bool) {
+	contents, err := os.ReadFile(exportFile)
+	if err != nil {
+		dialog.ShowError(err, toolUI.Window)
+		return
+	}
+	var result *importer.Result
+	if format == insomniaFormat {
+		result, err = importer.ParseInsomnia(contents)
+	} else {
+		result, err = importer.ParsePostman(contents)
+	}
+	if err != nil {
+		dialog.ShowError(err, toolUI.Window)
+		return
+	}
+	if result.Name == "" {
+		result.Name = strings.TrimSuffix(filepath.Base(exportFile), filepath.Ext(exportFile))
+	}
+	addEnvironment = addEnvironment && len(result.Variables) > 0
+
+	replaced, err := result.ExistingFiles(outputDir)
+	if err != nil {
+		dialog.ShowError(err, toolUI.Window)
+		return
+	}
+	if addEnvironment {
+		envs, err := config.LoadEnvironments()
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		if envs.Get(result.Name) != nil {
+			replaced = append(replaced, "the environment "+result.Name)
+		}
+	}
+	if len(replaced) == 0 {
+		toolUI.saveImport(result, outputDir, protoFile, importPaths, addEnvironment, false)
+		return
+	}
+	dialog.ShowConfirm("Replace Existing", "The import will replace:\n  "+strings.Join(replaced, "\n  ")+
+		"\n\nReplace them?", func(ok bool) {
+		if ok {
+			toolUI.saveImport(result, outputDir, protoFile, importPaths, addEnvironment, true)
+		}
+	}, toolUI.Window)
+}
+
+// saveImport writes an imported result and shows a summary, existing files and an environment with the same name are
+// only replaced when overwrite is set
+func (toolUI *UI) saveImport(result *importer.Result, outputDir string, protoFile string, importPaths []string,
+	addEnvironment bool, overwrite bool) {
+	files, err := result.Save(outputDir, protoFile, importPaths, func(protoFile string, importPaths []string) (*protoregistry.Files, error) {
+		conn := proto.NewGrpcConnection()
+		if err := conn.LoadRegistry(importPaths, protoFile); err != nil {
+			return nil, err
+		}
+		return conn.FileRegistry, nil
+	}, overwrite)
+	if err != nil {
+		dialog.ShowError(err, toolUI.Window)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Imported %d requests from %s\n", result.Requests(), result.Name))
+	for _, path := range append(append(files.Collections, files.Servers...), files.Protos...) {
+		sb.WriteString("  " + path + "\n")
+	}
+	if addEnvironment {
+		if err = addImportedEnvironment(result.Name, result.Variables, overwrite); err != nil {
+			dialog.ShowError(err, toolUI.Window)
+		} else {
+			sb.WriteString(fmt.Sprintf("\nAdded %d variables as the environment %s\n", len(result.Variables), result.Name))
+			toolUI.refreshEnvironmentSelect()
+		}
+	}
+	if len(result.Skipped) > 0 {
+		sb.WriteString("\nNot imported:\n")
+		for _, skipped := range result.Skipped {
+			sb.WriteString("  " + skipped + "\n")
+		}
+	}
+
+	summary := dialog.NewCustom("Import", "OK", container.NewScroll(widget.NewTextGridFromString(sb.String())), toolUI.Window)
+	size := toolUI.MainContent.Size()
+	summary.Resize(fyne.NewSize(size.Width/1.1, size.Height/1.1))
+	summary.Show()
+}
+
+// addImportedEnvironment saves variables as an environment, an environment with the same name is only replaced when
+// overwrite is set
+func addImportedEnvironment(name string, variables map[string]string, overwrite bool) error {
+	envs, err := config.LoadEnvironments()
+	if err != nil {
+		return err
+	}
+	if env := envs.Get(name); env != nil {
+		if !overwrite {
+			return fmt.Errorf("an environment named %s already exists", name)
+		}
+		env.Variables = variables
+	} else {
+		envs.List = append(envs.List, &config.Environment{Name: name, Variables: variables})
+	}
+	if err = config.SaveEnvironments(envs); err != nil {
+		return err
+	}
+	environments = envs
+	return nil
+}
+
+// withFileButton puts a browse button next to an entry which fills it with the chosen file's path
+func (toolUI *UI) withFileButton(entry *widget.Entry, extensions []string) fyne.CanvasObject {
+	browseButton := widget.NewButtonWithIcon("", theme.FolderOpenIcon(), func() {
+		openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if reader == nil {
+				return
+			}
+			_ = reader.Close()
+			if err != nil {
+				dialog.ShowError(err, toolUI.Window)
+				return
+			}
+			entry.SetText(reader.URI().Path())
+		}, toolUI.Window)
+		openDialog.SetFilter(storage.NewExtensionFileFilter(extensions))
+		openDialog.Show()
+	})
+	return container.NewBorder(nil, nil, nil, browseButton, entry)
+}
+
+// openGrpcurlCommand configures the server and registry from the command and shows its request in the form
+func (toolUI *UI) openGrpcurlCommand(cmd *importer.GrpcurlCommand) error {
+	var err error
+	if len(cmd.ProtoFiles) > 0 {
+		err = grpcConn.LoadRegistry(cmd.ImportPaths, cmd.ProtoFiles...)
+	} else {
+		err = grpcConn.LoadProtosets(cmd.Protosets...)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err = grpcConn.FileRegistry.FindDescriptorByName(protoreflect.FullName(cmd.Service + "." + cmd.Method)); err != nil {
+		return fmt.Errorf("method %s/%s is not in the loaded files", cmd.Service, cmd.Method)
+	}
+
+	server := &config.Server{Hostname: cmd.Hostname, Port: cmd.Port, Metadata: grpcmd.MD{}, TLS: cmd.TLS,
+		Tuning: cmd.Tuning}
+	grpcConn.SetConnectionDetails(server.Hostname, server.Port, server.Metadata)
+	grpcConn.SetAddresses(nil, "")
+	grpcConn.SetAuth(auth.Config{})
+	grpcConn.SetTLS(server.TLS)
+	grpcConn.SetTransport(server.Transport)
+	grpcConn.SetRoute(server.Route)
+	grpcConn.SetTuning(server.Tuning)
+	grpcConn.SetTrace(server.Trace)
+	grpcConn.SetServiceConfig(server.ServiceConfig)
+	toolUI.ServerFile = ""
+	toolUI.ProtoFile = ""
+	if len(cmd.ProtoFiles) > 0 {
+		toolUI.ProtoFile = cmd.ProtoFiles[0]
+	}
+	toolUI.ImportPaths = cmd.ImportPaths
+	toolUI.ServerLabel.SetText(server.Hostname)
+
+	clearRequestStructure()
+	toolUI.hideOrClearAllMainContent()
+	toolUI.showServerUI(server)
+	toolUI.hideOrClearAllMainContent()
+	pendingRequest = &config.SavedRequest{
+		Service:  cmd.Service,
+		Method:   cmd.Method,
+		Body:     cmd.Body,
+		Metadata: cmd.Metadata,
+	}
+	toolUI.showInputUI()
+	toolUI.MainContent.Refresh()
+	return nil
+}
+
+// importDirectory is where relative paths in imported files are resolved from by default
+func importDirectory(protoFile string) string {
+	if protoFile != "" {
+		return filepath.Dir(protoFile)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return home
+	}
+	return "."
+}
@@ -1,9 +1,17 @@
 package ui
 
 import (
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"image/color"
+	"maps"
+	"slices"
+	"strings"
+	"time"
 
+	"grpc_ui_tool/assert"
+	"grpc_ui_tool/config"
 	"grpc_ui_tool/proto"
 
 	"fyne.io/fyne/v2"
@@ -13,6 +21,7 @@ import (
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"google.golang.org/grpc/status"
 )
 
 type inputWidgetType int
@@ -43,10 +52,21 @@ type messageField struct {
 
 var fieldStructure *message
 
+var requestMetadata []*metadataPair
+
+// pendingRequest is shown in the request form the next time it is created, used by imports
+var pendingRequest *config.SavedRequest
+
 func (toolUI *UI) showInputUI() {
 	var serviceSelect, methodSelect *widget.Select
+	prefill := pendingRequest
+	pendingRequest = nil
+	if prefill == nil {
+		prefill = &config.SavedRequest{}
+	}
 
 	fieldStructure = &message{}
+	requestMetadata = nil
 
 	content := container.New(layout.NewVBoxLayout())
 
@@ -94,6 +114,28 @@ func (toolUI *UI) showInputUI() {
 	content.Add(serviceMethodGrid)
 	content.Add(inputBox)
 
+	content.Add(widget.NewSeparator())
+	meta := widget.NewLabel("Request Metadata")
+	meta.Alignment = fyne.TextAlignCenter
+	meta.TextStyle = fyne.TextStyle{Bold: true}
+	content.Add(meta)
+	metaGrid := container.New(layout.NewGridLayout(2))
+	content.Add(metaGrid)
+	addMetaDataButton := widget.NewButtonWithIcon("", theme.ContentAddIcon(), func() {
+		toolUI.addMetadataItem(metaGrid, &requestMetadata, "", "")
+	})
+	clearMetaDataButton := widget.NewButtonWithIcon("", theme.ContentClearIcon(), func() {
+		metaGrid.RemoveAll()
+		requestMetadata = nil
+	})
+	metadataButtonBox := container.New(layout.NewHBoxLayout(), addMetaDataButton, clearMetaDataButton)
+	content.Add(container.New(layout.NewBorderLayout(nil, nil, nil, metadataButtonBox), metadataButtonBox))
+
+	content.Add(widget.NewSeparator())
+	content.Add(toolUI.createAssertionsSection(prefill.Assertions))
+	content.Add(widget.NewSeparator())
+	content.Add(toolUI.createCapturesSection(prefill.Captures))
+
 	activity := widget.NewActivity()
 	activity.Hide()
 	submitButton := widget.NewButton("Submit", func() {
@@ -101,19 +143,80 @@ func (toolUI *UI) showInputUI() {
 		activity.Start()
 		jsonString := toolUI.getRequestJson()
 
-		resp, err := grpcConn.Send(serviceSelect.Selected, methodSelect.Selected, jsonString)
+		md, err := getMetadata(requestMetadata)
 		if err != nil {
 			dialog.ShowError(err, toolUI.Window)
 			activity.Stop()
 			activity.Hide()
 			return
 		}
+		resp, err := grpcConn.Send(serviceSelect.Selected, methodSelect.Selected, jsonString, md)
+		toolUI.recordHistory(serviceSelect.Selected, methodSelect.Selected, resp, err)
+		if toolUI.handlePinning(resp, err) {
+			activity.Stop()
+			activity.Hide()
+			return
+		}
+		// a failed call is still shown as a response when assertions are set, they may expect the failure
+		reqAssertions := getAssertions()
+		if err != nil && len(reqAssertions) == 0 {
+			if resp.Attempts > 1 {
+				err = fmt.Errorf("%w\n\nfailed after %d attempts", err, resp.Attempts)
+			}
+			if resp.Trace != nil {
+				err = fmt.Errorf("%w\n\ntrace ID %s", err, resp.Trace.TraceID)
+			}
+			toolUI.showCallError(err, resp.Peer)
+			activity.Stop()
+			activity.Hide()
+			return
+		}
 
 		size := toolUI.MainContent.Size()
-		textGrid := widget.NewTextGridFromString(resp)
-
-		scroll := container.NewScroll(textGrid)
-		max := container.NewBorder(nil, nil, nil, nil, scroll)
+		bodyTab := container.NewTabItem("Body", container.NewScroll(widget.NewTextGridFromString(resp.Body)))
+		if err != nil {
+			bodyTab = container.NewTabItem("Error", container.NewScroll(widget.NewTextGridFromString(err.Error())))
+		}
+		tabs := container.NewAppTabs(
+			bodyTab,
+			container.NewTabItem("Headers", container.NewScroll(widget.NewTextGridFromString(formatMetadata(resp.Headers)))),
+			container.NewTabItem("Trailers", container.NewScroll(widget.NewTextGridFromString(formatMetadata(resp.Trailers)))),
+			container.NewTabItem("Timing", container.NewScroll(widget.NewTextGridFromString(formatTiming(resp.Timing)))),
+		)
+		if resp.Peer != nil {
+			peerTab := "Peer"
+			if len(resp.Peer.CertificateWarnings(time.Now())) > 0 {
+				peerTab += " (!)"
+			}
+			tabs.Append(container.NewTabItem(peerTab, container.NewScroll(widget.NewTextGridFromString(formatPeer(resp.Peer)))))
+		}
+		if len(reqAssertions) > 0 {
+			outcomes := ""
+			for _, outcome := range assert.CheckAll(reqAssertions, resp, err) {
+				result := "PASS"
+				if !outcome.Passed {
+					result = "FAIL"
+				}
+				outcomes += result + "  " + outcome.Assertion.Describe() + ": " + outcome.Message + "\n"
+			}
+			assertionsTab := container.NewTabItem("Assertions", container.NewScroll(widget.NewTextGridFromString(outcomes)))
+			tabs.Append(assertionsTab)
+			if err != nil {
+				tabs.Select(assertionsTab)
+			}
+		}
+		summaryText := fmt.Sprintf("Duration: %s    Attempts: %d", resp.Duration.Round(time.Millisecond), resp.Attempts)
+		if err != nil {
+			summaryText = "Status: " + status.Code(err).String() + "    " + summaryText
+		}
+		if resp.Trace != nil {
+			summaryText += "    Trace ID: " + resp.Trace.TraceID
+		}
+		if resp.SpanError != nil {
+			summaryText += "\nSpan not exported: " + resp.SpanError.Error()
+		}
+		summary := widget.NewLabel(summaryText)
+		max := container.NewBorder(summary, nil, nil, nil, tabs)
 
 		results := dialog.NewCustom("GRPC Response", "OK", max, toolUI.Window)
 		results.Resize(fyne.NewSize(size.Width/1.5, size.Height/1.5))
@@ -127,15 +230,174 @@ func (toolUI *UI) showInputUI() {
 
 	stack := container.NewStack(submitButton, activity)
 
-	buttonBox := container.New(layout.NewBorderLayout(nil, nil, nil, stack), stack)
+	benchmarkButton := widget.NewButtonWithIcon("Benchmark", theme.MediaFastForwardIcon(), func() {
+		if serviceSelect.Selected == "" || methodSelect.Selected == "" {
+			return
+		}
+		md, err := getMetadata(requestMetadata)
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		toolUI.showBenchmarkDialog(serviceSelect.Selected, methodSelect.Selected, toolUI.getRequestJson(), md)
+	})
+
+	saveRequestButton := widget.NewButtonWithIcon("Save Request", theme.DocumentSaveIcon(), func() {
+		if serviceSelect.Selected == "" || methodSelect.Selected == "" {
+			return
+		}
+		md, err := getMetadata(requestMetadata)
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		toolUI.showSaveRequestDialog(serviceSelect.Selected, methodSelect.Selected, toolUI.getRequestJson(), md, getAssertions(), getCaptures())
+	})
+
+	exportButton := widget.NewButtonWithIcon("Export", theme.ContentCopyIcon(), func() {
+		if serviceSelect.Selected == "" || methodSelect.Selected == "" {
+			return
+		}
+		md, err := getMetadata(requestMetadata)
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		toolUI.showExportDialog(serviceSelect.Selected, methodSelect.Selected, toolUI.getRequestJson(), md)
+	})
+
+	actionBox := container.New(layout.NewHBoxLayout(), exportButton, saveRequestButton, benchmarkButton, stack)
+	buttonBox := container.New(layout.NewBorderLayout(nil, nil, nil, actionBox), actionBox)
 	content.Add(buttonBox)
 
+	if prefill.Service != "" {
+		serviceSelect.SetSelected(prefill.Service)
+		methodSelect.SetSelected(prefill.Method)
+		if err = fillRequestJson(prefill.Body); err != nil {
+			dialog.ShowError(fmt.Errorf("request body: %w", err), toolUI.Window)
+		}
+		for _, key := range slices.Sorted(maps.Keys(prefill.Metadata)) {
+			for _, value := range prefill.Metadata[key] {
+				toolUI.addMetadataItem(metaGrid, &requestMetadata, key, value)
+			}
+		}
+	}
+
 	toolUI.InputContent = container.NewScroll(content)
 	toolUI.InputContent.ScrollToTop()
 	toolUI.MainContent.Add(toolUI.InputContent)
 	toolUI.CurrentView = InputView
 }
 
+func formatMetadata(md map[string][]string) string {
+	text := ""
+	for _, key := range slices.Sorted(maps.Keys(md)) {
+		for _, value := range md[key] {
+			text += key + ": " + value + "\n"
+		}
+	}
+	return text
+}
+
+// formatTiming draws the phases of a call as a waterfall of bars scaled to the whole call, followed by the
+// message sizes
+func formatTiming(timing *proto.Timing) string {
+	if timing == nil {
+		return ""
+	}
+	const width = 40
+	total := timing.Total()
+	var sb strings.Builder
+	for _, phase := range timing.Phases() {
+		start, end := 0, 0
+		if total > 0 {
+			start = int(int64(width) * int64(phase.Start) / int64(total))
+			end = int(int64(width) * int64(phase.End) / int64(total))
+		}
+		bar := strings.Repeat(" ", start) + strings.Repeat("█", max(end-start, 1))
+		sb.WriteString(fmt.Sprintf("%-20s %10s %10s  |%-*s|\n", phase.Name, formatMillis(phase.Start),
+			"+"+formatMillis(phase.End-phase.Start), width, bar))
+	}
+	sb.WriteString(fmt.Sprintf("%-20s %10s\n\n", "Total", formatMillis(total)))
+	for _, size := range []struct {
+		name string
+		size proto.WireSize
+	}{{"Request", timing.Request}, {"Response", timing.Response}} {
+		sb.WriteString(fmt.Sprintf("%-9s %d bytes, %d compressed, %d on the wire\n", size.name,
+			size.size.Uncompressed, size.size.Compressed, size.size.Wire))
+	}
+	return sb.String()
+}
+
+func formatMillis(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+}
+
+// showCallError shows why a call failed along with the server it talked to, which tells a certificate problem or
+// the wrong backend apart
+func (toolUI *UI) showCallError(err error, peer *proto.PeerInfo) {
+	if peer == nil {
+		dialog.ShowError(err, toolUI.Window)
+		return
+	}
+	message := widget.NewLabel(err.Error())
+	message.Wrapping = fyne.TextWrapWord
+	details := container.NewScroll(widget.NewTextGridFromString(formatPeer(peer)))
+	content := container.NewBorder(message, nil, nil, nil, details)
+	errorDialog := dialog.NewCustom("Error", "OK", content, toolUI.Window)
+	size := toolUI.MainContent.Size()
+	errorDialog.Resize(fyne.NewSize(size.Width/1.5, size.Height/1.5))
+	errorDialog.Show()
+}
+
+// formatPeer describes the server address, the negotiated TLS parameters and the certificate chain, with any
+// certificate warnings first
+func formatPeer(peer *proto.PeerInfo) string {
+	var sb strings.Builder
+	for _, warning := range peer.CertificateWarnings(time.Now()) {
+		sb.WriteString("WARNING: " + warning + "\n")
+	}
+	if sb.Len() > 0 {
+		sb.WriteString("\n")
+	}
+	address := peer.Address
+	if address == "" {
+		address = "unknown"
+	}
+	sb.WriteString("Address: " + address + "\n")
+	if peer.TLS == nil {
+		sb.WriteString("TLS: none\n")
+		return sb.String()
+	}
+	if peer.TLS.Version == 0 {
+		// the certificates were rejected before the handshake completed
+		sb.WriteString("TLS: handshake failed\n")
+	} else {
+		alpn := peer.TLS.NegotiatedProtocol
+		if alpn == "" {
+			alpn = "none"
+		}
+		sb.WriteString("TLS: " + tls.VersionName(peer.TLS.Version) + "\n")
+		sb.WriteString("Cipher: " + tls.CipherSuiteName(peer.TLS.CipherSuite) + "\n")
+		sb.WriteString("ALPN: " + alpn + "\n")
+	}
+	if peer.TLS.ServerName != "" {
+		sb.WriteString("Server name: " + peer.TLS.ServerName + "\n")
+	}
+	for i, cert := range peer.Certificates() {
+		sb.WriteString(fmt.Sprintf("\nCertificate %d\n", i))
+		sb.WriteString("  Subject: " + cert.Subject.String() + "\n")
+		if names := proto.SubjectAltNames(cert); len(names) > 0 {
+			sb.WriteString("  Alternative names: " + strings.Join(names, ", ") + "\n")
+		}
+		sb.WriteString("  Issuer: " + cert.Issuer.String() + "\n")
+		sb.WriteString("  Valid: " + cert.NotBefore.Format(time.RFC3339) + " to " + cert.NotAfter.Format(time.RFC3339) + "\n")
+		sb.WriteString("  Serial: " + cert.SerialNumber.Text(16) + "\n")
+		sb.WriteString("  SHA-256: " + proto.CertificateFingerprint(cert) + "\n")
+	}
+	return sb.String()
+}
+
 func clearRequestStructure() {
 	fieldStructure = nil
 }
@@ -262,6 +524,67 @@ func getNestedJson(msg *message) string {
 	return jsonString
 }
 
+// fillRequestJson sets the form fields from a JSON request body, oneofs select the option holding the given field
+func fillRequestJson(body string) error {
+	if strings.TrimSpace(body) == "" || fieldStructure == nil {
+		return nil
+	}
+	var values map[string]any
+	if err := json.Unmarshal([]byte(body), &values); err != nil {
+		return err
+	}
+	fillNestedJson(fieldStructure, values)
+	return nil
+}
+
+func fillNestedJson(msg *message, values map[string]any) {
+	for _, m := range msg.fields {
+		if m.inputType == gridOneOf {
+			for _, key := range m.field.FieldOneOf.OneOfKeys {
+				options := m.field.FieldOneOf.OneOfValues[key]
+				if len(options) > 0 && fieldValue(options[0], values) != nil {
+					m.sel.SetSelected(key)
+					fillNestedJson(m.nested, values)
+					break
+				}
+			}
+			continue
+		}
+
+		value := fieldValue(m.field, values)
+		if value == nil {
+			continue
+		}
+		switch m.inputType {
+		case gridMessage:
+			if nested, ok := value.(map[string]any); ok {
+				fillNestedJson(m.nested, nested)
+			}
+		case entry:
+			if text, ok := value.(string); ok {
+				m.entry.SetText(text)
+			} else {
+				encoded, _ := json.Marshal(value)
+				m.entry.SetText(string(encoded))
+			}
+		case check:
+			if checked, ok := value.(bool); ok {
+				m.check.SetChecked(checked)
+			}
+		case sel:
+			m.sel.SetSelected(fmt.Sprint(value))
+		}
+	}
+}
+
+// fieldValue finds a field by its JSON name or its proto name as both are accepted in requests
+func fieldValue(field *proto.Field, values map[string]any) any {
+	if value, ok := values[field.JsonName]; ok {
+		return value
+	}
+	return values[field.Name]
+}
+
 func (toolUI *UI) getRequestJson() string {
 	jsonString := "{ "
 	jsonString += getNestedJson(fieldStructure)
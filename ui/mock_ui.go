@@ -0,0 +1,280 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"grpc_ui_tool/assert"
+	"grpc_ui_tool/config"
+	"grpc_ui_tool/mock"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	mockServer    *mock.Server
+	mockResponses = &config.Mocks{}
+	mockAddress   = "127.0.0.1:50051"
+
+	mockCallsMu sync.Mutex
+	mockCalls   []mock.Call
+	// mockCallsChanged refreshes the open mock dialog's call list
+	mockCallsChanged func()
+)
+
+// showMockDialog configures and runs a local server that implements every service in the loaded proto files with
+// canned responses, it keeps running after the dialog is closed
+func (toolUI *UI) showMockDialog() {
+	if grpcConn.FileRegistry == nil {
+		dialog.ShowError(fmt.Errorf("load a proto file to choose the services to mock"), toolUI.Window)
+		return
+	}
+
+	var methods []string
+	services, _ := grpcConn.GetServices()
+	for _, service := range services {
+		names, _ := grpcConn.GetMethods(service)
+		for _, name := range names {
+			methods = append(methods, service+"/"+name)
+		}
+	}
+
+	bodyEntry := widget.NewMultiLineEntry()
+	bodyEntry.SetPlaceHolder(`{"id": "{{id}}", "name": "mock"}`)
+	bodyEntry.SetMinRowsVisible(6)
+	codeNames := []string{}
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		codeNames = append(codeNames, c.String())
+	}
+	codeSelect := widget.NewSelect(codeNames, nil)
+	messageEntry := widget.NewEntry()
+	delayEntry := widget.NewEntry()
+	delayEntry.SetPlaceHolder("e.g. 250ms")
+
+	// the edits of the selected method are stored whenever another method is chosen and before starting or saving
+	var current string
+	storeCurrent := func() {
+		if current == "" {
+			return
+		}
+		code := codeSelect.Selected
+		if code == codes.OK.String() {
+			code = ""
+		}
+		mockResponses.Set(&config.MockResponse{
+			Method:  current,
+			Body:    bodyEntry.Text,
+			Code:    code,
+			Message: messageEntry.Text,
+			Delay:   strings.TrimSpace(delayEntry.Text),
+		})
+	}
+	methodSelect := widget.NewSelect(methods, func(selected string) {
+		storeCurrent()
+		current = selected
+		resp := mockResponses.Get(selected)
+		if resp == nil {
+			resp = &config.MockResponse{}
+		}
+		bodyEntry.SetText(resp.Body)
+		codeSelect.SetSelected(codes.OK.String())
+		if code, err := assert.ParseCode(resp.Code); err == nil {
+			codeSelect.SetSelected(code.String())
+		}
+		messageEntry.SetText(resp.Message)
+		delayEntry.SetText(resp.Delay)
+	})
+	if len(methods) > 0 {
+		methodSelect.SetSelected(methods[0])
+	}
+
+	addressEntry := widget.NewEntry()
+	addressEntry.SetText(mockAddress)
+	reflectionCheck := widget.NewCheck("Reflection", nil)
+	plaintextCheck := widget.NewCheck("Plaintext", nil)
+
+	form := widget.NewForm(
+		widget.NewFormItem("Method", methodSelect),
+		widget.NewFormItem("Response", bodyEntry),
+		widget.NewFormItem("Status", codeSelect),
+		widget.NewFormItem("Message", messageEntry),
+		widget.NewFormItem("Delay", delayEntry),
+		widget.NewFormItem("Listen", container.NewBorder(nil, nil, nil, container.NewHBox(reflectionCheck, plaintextCheck), addressEntry)),
+	)
+
+	details := widget.NewTextGrid()
+	callList := widget.NewList(func() int {
+		mockCallsMu.Lock()
+		defer mockCallsMu.Unlock()
+		return len(mockCalls)
+	}, func() fyne.CanvasObject {
+		return widget.NewLabel("")
+	}, func(id widget.ListItemID, item fyne.CanvasObject) {
+		call := mockCallAt(id)
+		item.(*widget.Label).SetText(call.Time.Format("15:04:05.000") + "  " + call.Method + "  " + status.Code(call.Err).String())
+	})
+	callList.OnSelected = func(id widget.ListItemID) {
+		details.SetText(formatMockCall(mockCallAt(id)))
+	}
+	mockCallsChanged = callList.Refresh
+
+	stateLabel := widget.NewLabel("Stopped")
+	var startButton *widget.Button
+	updateState := func() {
+		if mockServer != nil && mockServer.Running() != nil {
+			stateLabel.SetText("Listening on " + mockServer.Running().String())
+			startButton.SetText("Stop")
+			startButton.SetIcon(theme.MediaStopIcon())
+		} else {
+			stateLabel.SetText("Stopped")
+			startButton.SetText("Start")
+			startButton.SetIcon(theme.MediaPlayIcon())
+		}
+	}
+	startButton = widget.NewButtonWithIcon("Start", theme.MediaPlayIcon(), func() {
+		if mockServer != nil && mockServer.Running() != nil {
+			mockServer.Stop()
+			updateState()
+			return
+		}
+		storeCurrent()
+		if err := validateMocks(mockResponses); err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		mockAddress = addressEntry.Text
+		mockServer = mock.New(grpcConn.FileRegistry, mockResponses, recordMockCall)
+		mockServer.SetVariables(grpcConn.Variables)
+		if _, err := mockServer.Start(mock.Options{
+			Address:    mockAddress,
+			Reflection: reflectionCheck.Checked,
+			Plaintext:  plaintextCheck.Checked,
+		}); err != nil {
+			dialog.ShowError(err, toolUI.Window)
+		}
+		updateState()
+	})
+	startButton.Importance = widget.HighImportance
+	updateState()
+
+	openButton := widget.NewButtonWithIcon("", theme.FolderOpenIcon(), func() {
+		openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if reader == nil {
+				return
+			}
+			_ = reader.Close()
+			if err != nil {
+				dialog.ShowError(err, toolUI.Window)
+				return
+			}
+			mocks, err := config.LoadMocks(reader.URI().Path())
+			if err != nil {
+				dialog.ShowError(err, toolUI.Window)
+				return
+			}
+			mockResponses = mocks
+			if mockServer != nil {
+				mockServer.SetMocks(mocks)
+			}
+			current = ""
+			methodSelect.SetSelected("")
+			if len(methods) > 0 {
+				methodSelect.SetSelected(methods[0])
+			}
+		}, toolUI.Window)
+		openDialog.SetFilter(storage.NewExtensionFileFilter([]string{".gtmock"}))
+		openDialog.Show()
+	})
+	saveButton := widget.NewButtonWithIcon("", theme.DocumentSaveIcon(), func() {
+		storeCurrent()
+		saveDialog := dialog.NewFileSave(func(closer fyne.URIWriteCloser, err error) {
+			if closer == nil {
+				return
+			}
+			_ = closer.Close()
+			if err != nil {
+				dialog.ShowError(err, toolUI.Window)
+				return
+			}
+			if err = config.SaveMocks(closer.URI().Path(), mockResponses); err != nil {
+				dialog.ShowError(err, toolUI.Window)
+			}
+		}, toolUI.Window)
+		saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{".gtmock"}))
+		saveDialog.SetFileName("responses.gtmock")
+		saveDialog.Show()
+	})
+	applyButton := widget.NewButton("Apply", func() {
+		storeCurrent()
+		if err := validateMocks(mockResponses); err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		if mockServer != nil {
+			mockServer.SetMocks(mockResponses)
+		}
+	})
+
+	controls := container.NewBorder(nil, nil, container.NewHBox(openButton, saveButton),
+		container.NewHBox(applyButton, startButton), stateLabel)
+	calls := container.NewHSplit(callList, container.NewScroll(details))
+	calls.Offset = 0.4
+	content := container.NewBorder(container.NewVBox(form, controls, widget.NewSeparator()), nil, nil, nil, calls)
+
+	mockDialog := dialog.NewCustom("Mock Server", "Close", content, toolUI.Window)
+	mockDialog.SetOnClosed(func() {
+		storeCurrent()
+		mockCallsChanged = nil
+	})
+	size := toolUI.MainContent.Size()
+	mockDialog.Resize(fyne.NewSize(size.Width/1.05, size.Height/1.05))
+	mockDialog.Show()
+}
+
+func validateMocks(mocks *config.Mocks) error {
+	for _, resp := range mocks.Responses {
+		if _, err := resp.DelayDuration(); err != nil {
+			return fmt.Errorf("%s delay: %w", resp.Method, err)
+		}
+	}
+	return nil
+}
+
+func recordMockCall(call mock.Call) {
+	mockCallsMu.Lock()
+	mockCalls = append(mockCalls, call)
+	mockCallsMu.Unlock()
+	if refresh := mockCallsChanged; refresh != nil {
+		refresh()
+	}
+}
+
+func mockCallAt(id int) mock.Call {
+	mockCallsMu.Lock()
+	defer mockCallsMu.Unlock()
+	return mockCalls[id]
+}
+
+func formatMockCall(call mock.Call) string {
+	var sb strings.Builder
+	sb.WriteString("Time: " + call.Time.Format(time.RFC3339Nano) + "\n")
+	sb.WriteString("Method: " + call.Method + "\n")
+	sb.WriteString("Status: " + status.Code(call.Err).String() + "\n")
+	sb.WriteString(formatMetadataLines("Metadata", call.Metadata))
+	sb.WriteString("\nRequest:\n" + call.Request + "\n")
+	if call.Err != nil {
+		sb.WriteString("\nError:\n" + call.Err.Error() + "\n")
+	} else {
+		sb.WriteString("\nResponse:\n" + call.Response + "\n")
+	}
+	return sb.String()
+}
@@ -136,8 +136,14 @@ func (toolUI *UI) showProtoUI() {
 			dialog.ShowError(err, toolUI.Window)
 			return
 		}
+		toolUI.ProtoFile = protoFile
+		toolUI.ImportPaths = importPaths
 		toolUI.hideOrClearAllMainContent()
 		toolUI.showInputUI()
+		// the calls still work, grpc just never applies the method configs that do not match
+		if err = grpcConn.CheckServiceConfig(); err != nil {
+			dialog.ShowError(err, toolUI.Window)
+		}
 	})
 	submitButton.Importance = widget.HighImportance
 	submitButton.SetIcon(theme.ConfirmIcon())
@@ -0,0 +1,218 @@
+package ui
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"grpc_ui_tool/mock"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	recordingProxy *mock.Proxy
+	proxyAddress   = "127.0.0.1:50052"
+
+	proxyExchangesMu sync.Mutex
+	proxyExchanges   []mock.Exchange
+	// proxyExchangesChanged refreshes the open proxy dialog's exchange list
+	proxyExchangesChanged func()
+)
+
+// showProxyDialog runs a local proxy to the current server and shows the calls passing through it as they happen,
+// any exchange can be saved to a collection or added to the mock server's responses
+func (toolUI *UI) showProxyDialog() {
+	if grpcConn.FileRegistry == nil {
+		dialog.ShowError(fmt.Errorf("load a proto file to decode the proxied calls"), toolUI.Window)
+		return
+	}
+
+	selected := -1
+	details := widget.NewTextGrid()
+	exchangeList := widget.NewList(func() int {
+		proxyExchangesMu.Lock()
+		defer proxyExchangesMu.Unlock()
+		return len(proxyExchanges)
+	}, func() fyne.CanvasObject {
+		return widget.NewLabel("")
+	}, func(id widget.ListItemID, item fyne.CanvasObject) {
+		exchange := proxyExchangeAt(id)
+		state := "..."
+		if exchange.Done {
+			state = status.Code(exchange.Err).String()
+		}
+		item.(*widget.Label).SetText(fmt.Sprintf("%s  %s  %s", exchange.Time.Format("15:04:05.000"), exchange.Method, state))
+	})
+	exchangeList.OnSelected = func(id widget.ListItemID) {
+		selected = id
+		details.SetText(formatExchange(proxyExchangeAt(id)))
+	}
+	proxyExchangesChanged = func() {
+		exchangeList.Refresh()
+		if selected >= 0 {
+			details.SetText(formatExchange(proxyExchangeAt(selected)))
+		}
+	}
+
+	addressEntry := widget.NewEntry()
+	addressEntry.SetText(proxyAddress)
+	plaintextCheck := widget.NewCheck("Plaintext", nil)
+	stateLabel := widget.NewLabel("")
+
+	var startButton *widget.Button
+	updateState := func() {
+		if recordingProxy != nil && recordingProxy.Running() != nil {
+			stateLabel.SetText("Forwarding " + recordingProxy.Running().String())
+			startButton.SetText("Stop")
+			startButton.SetIcon(theme.MediaStopIcon())
+		} else {
+			stateLabel.SetText("Stopped")
+			startButton.SetText("Start")
+			startButton.SetIcon(theme.MediaRecordIcon())
+		}
+	}
+	startButton = widget.NewButtonWithIcon("Start", theme.MediaRecordIcon(), func() {
+		if recordingProxy != nil && recordingProxy.Running() != nil {
+			recordingProxy.Stop()
+			updateState()
+			return
+		}
+		target, err := grpcConn.Target()
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		upstream, err := grpcConn.DialTransparent(target)
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		proxyExchangesMu.Lock()
+		proxyExchanges = nil
+		proxyExchangesMu.Unlock()
+		selected = -1
+		exchangeList.UnselectAll()
+		details.SetText("")
+
+		proxyAddress = addressEntry.Text
+		recordingProxy = mock.NewProxy(grpcConn.FileRegistry, upstream, recordExchange)
+		if _, err = recordingProxy.Start(mock.Options{Address: proxyAddress, Plaintext: plaintextCheck.Checked}); err != nil {
+			_ = upstream.Close()
+			dialog.ShowError(err, toolUI.Window)
+		}
+		updateState()
+		exchangeList.Refresh()
+	})
+	startButton.Importance = widget.HighImportance
+	updateState()
+
+	saveRequestButton := widget.NewButtonWithIcon("Save Request", theme.DocumentSaveIcon(), func() {
+		if selected < 0 {
+			return
+		}
+		exchange := proxyExchangeAt(selected)
+		service, method, found := strings.Cut(exchange.Method, "/")
+		if !found || len(exchange.Requests) == 0 {
+			dialog.ShowError(fmt.Errorf("the exchange has no request to save"), toolUI.Window)
+			return
+		}
+		toolUI.showSaveRequestDialog(service, method, exchange.Requests[0], exchange.Metadata, nil, nil)
+	})
+	mockButton := widget.NewButtonWithIcon("Add Mock Response", theme.ContentAddIcon(), func() {
+		if selected < 0 {
+			return
+		}
+		exchange := proxyExchangeAt(selected)
+		if !exchange.Done {
+			dialog.ShowError(fmt.Errorf("the call has not finished yet"), toolUI.Window)
+			return
+		}
+		mockResponses.Set(mock.Fixture(exchange))
+		if mockServer != nil {
+			mockServer.SetMocks(mockResponses)
+		}
+		dialog.ShowInformation("Mock Response", "The response for "+exchange.Method+
+			" was added to the mock server, it can be edited and saved from the mock server dialog", toolUI.Window)
+	})
+
+	controls := container.NewBorder(nil, nil, widget.NewLabel("Listen"),
+		container.NewHBox(plaintextCheck, startButton), addressEntry)
+	top := container.NewVBox(controls, stateLabel, widget.NewSeparator())
+	bottom := container.NewHBox(saveRequestButton, mockButton)
+	split := container.NewHSplit(exchangeList, container.NewScroll(details))
+	split.Offset = 0.4
+	content := container.NewBorder(top, bottom, nil, nil, split)
+
+	proxyDialog := dialog.NewCustom("Recording Proxy", "Close", content, toolUI.Window)
+	proxyDialog.SetOnClosed(func() {
+		proxyExchangesChanged = nil
+	})
+	size := toolUI.MainContent.Size()
+	proxyDialog.Resize(fyne.NewSize(size.Width/1.05, size.Height/1.05))
+	proxyDialog.Show()
+}
+
+func recordExchange(exchange mock.Exchange) {
+	proxyExchangesMu.Lock()
+	if exchange.ID <= len(proxyExchanges) {
+		proxyExchanges[exchange.ID-1] = exchange
+	} else {
+		for len(proxyExchanges) < exchange.ID {
+			proxyExchanges = append(proxyExchanges, exchange)
+		}
+	}
+	proxyExchangesMu.Unlock()
+	if refresh := proxyExchangesChanged; refresh != nil {
+		refresh()
+	}
+}
+
+func proxyExchangeAt(id int) mock.Exchange {
+	proxyExchangesMu.Lock()
+	defer proxyExchangesMu.Unlock()
+	return proxyExchanges[id]
+}
+
+func formatExchange(exchange mock.Exchange) string {
+	var sb strings.Builder
+	sb.WriteString("Time: " + exchange.Time.Format(time.RFC3339Nano) + "\n")
+	sb.WriteString("Method: " + exchange.Method + "\n")
+	if exchange.Done {
+		sb.WriteString("Status: " + status.Code(exchange.Err).String() + "\n")
+		sb.WriteString("Duration: " + exchange.Duration.String() + "\n")
+	} else {
+		sb.WriteString("Status: in progress\n")
+	}
+	sb.WriteString(formatMetadataLines("Metadata", exchange.Metadata))
+	sb.WriteString(formatMetadataLines("Header", exchange.Headers))
+	sb.WriteString(formatMetadataLines("Trailer", exchange.Trailers))
+	for i, request := range exchange.Requests {
+		sb.WriteString(fmt.Sprintf("\nRequest %d:\n%s\n", i+1, request))
+	}
+	for i, response := range exchange.Responses {
+		sb.WriteString(fmt.Sprintf("\nResponse %d:\n%s\n", i+1, response))
+	}
+	if exchange.Err != nil {
+		sb.WriteString("\nError:\n" + exchange.Err.Error() + "\n")
+	}
+	return sb.String()
+}
+
+func formatMetadataLines(label string, md map[string][]string) string {
+	var sb strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(md)) {
+		for _, value := range md[key] {
+			sb.WriteString(label + ": " + key + ": " + value + "\n")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,97 @@
+package ui
+
+import (
+	"grpc_ui_tool/route"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+type routeEntry struct {
+	label  string
+	value  *string
+	types  []route.Type
+	secret bool
+	hint   string
+}
+
+// createRouteForm builds the dial route settings, only the entries used by the selected route are shown.
+// The returned function reads the current settings back from the form
+func (toolUI *UI) createRouteForm(cfg route.Config) (*fyne.Container, func() route.Config) {
+	routeBox := container.New(layout.NewVBoxLayout())
+	routeForm := container.New(layout.NewFormLayout())
+
+	proxies := []route.Type{route.HTTPConnect, route.SOCKS5}
+	all := []route.Type{route.HTTPConnect, route.SOCKS5, route.SSHTunnel}
+	ssh := []route.Type{route.SSHTunnel}
+	entries := []*routeEntry{
+		{"Proxy Address", &cfg.Address, proxies, false, "proxy.example.com:3128"},
+		{"SSH Host", &cfg.Address, ssh, false, "bastion.example.com:22"},
+		{"Username", &cfg.Username, all, false, "optional for proxies"},
+		{"Password", &cfg.Password, proxies, true, "optional"},
+		{"Key File", &cfg.KeyFile, ssh, false, "~/.ssh/id_ed25519, or empty for the SSH agent"},
+		{"Password", &cfg.Password, ssh, true, "key passphrase, or password without a key"},
+		{"Known Hosts", &cfg.KnownHosts, ssh, false, "~/.ssh/known_hosts"},
+	}
+
+	widgets := make(map[*routeEntry]*widget.Entry)
+	for _, e := range entries {
+		var ent *widget.Entry
+		if e.secret {
+			ent = widget.NewPasswordEntry()
+		} else {
+			ent = widget.NewEntry()
+		}
+		ent.SetPlaceHolder(e.hint)
+		ent.SetText(*e.value)
+		widgets[e] = ent
+	}
+
+	names := make([]string, 0, len(route.Types))
+	for _, t := range route.Types {
+		names = append(names, t.String())
+	}
+	typeSelect := widget.NewSelect(names, func(selected string) {
+		for _, t := range route.Types {
+			if t.String() == selected {
+				cfg.Type = t
+			}
+		}
+		routeForm.RemoveAll()
+		for _, e := range entries {
+			for _, t := range e.types {
+				if t == cfg.Type {
+					label := widget.NewLabel(e.label)
+					label.Alignment = fyne.TextAlignTrailing
+					routeForm.Add(label)
+					routeForm.Add(widgets[e])
+				}
+			}
+		}
+		routeForm.Refresh()
+	})
+	typeLabel := toolUI.getFieldLabel("Route")
+	routeBox.Add(container.New(layout.NewBorderLayout(nil, nil, typeLabel, nil), typeLabel, typeSelect))
+	routeBox.Add(routeForm)
+	typeSelect.SetSelected(cfg.Type.String())
+
+	return routeBox, func() route.Config {
+		// entries sharing a field are read from the one shown for the selected route
+		for _, e := range entries {
+			for _, t := range e.types {
+				if t == cfg.Type {
+					*e.value = widgets[e].Text
+				}
+			}
+		}
+		if cfg.Type != route.SSHTunnel {
+			cfg.KeyFile, cfg.KnownHosts = "", ""
+		}
+		if cfg.Type == route.Direct {
+			cfg = route.Config{}
+		}
+		return cfg
+	}
+}
@@ -0,0 +1,225 @@
+package ui
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"grpc_ui_tool/config"
+	"grpc_ui_tool/expand"
+	"grpc_ui_tool/vault"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	grpcmd "google.golang.org/grpc/metadata"
+)
+
+type secretPair struct {
+	nameEntry  *widget.Entry
+	valueEntry *widget.Entry
+}
+
+var secrets *vault.Vault
+
+func (toolUI *UI) createSecretsButton() {
+	var err error
+	secrets, err = vault.Open()
+	if err != nil {
+		defer dialog.ShowError(err, toolUI.Window)
+	} else {
+		expand.SetSecretLookup(secrets.Get)
+	}
+
+	toolUI.SecretsButton = widget.NewButtonWithIcon("", theme.AccountIcon(), func() {
+		if secrets == nil {
+			return
+		}
+		toolUI.showSecretsDialog()
+	})
+}
+
+// redactSecrets replaces any secret values in text with references so they are never stored
+func redactSecrets(text string) string {
+	if secrets == nil || !secrets.Unlocked() {
+		return text
+	}
+	return secrets.Redact(text)
+}
+
+// redactValue replaces a secret held by a single field with its reference
+func redactValue(value string) string {
+	if secrets == nil || !secrets.Unlocked() {
+		return value
+	}
+	return secrets.RedactValue(value)
+}
+
+// redactServer replaces secret values in metadata and credentials with references before a server is saved,
+// field by field
+func redactServer(server *config.Server) *config.Server {
+	redacted := *server
+	redacted.Metadata = grpcmd.MD{}
+	for key, values := range server.Metadata {
+		for _, value := range values {
+			redacted.Metadata[key] = append(redacted.Metadata[key], redactValue(value))
+		}
+	}
+	redacted.Auth.Token = redactValue(server.Auth.Token)
+	redacted.Auth.ClientSecret = redactValue(server.Auth.ClientSecret)
+	redacted.Route.Password = redactValue(server.Route.Password)
+	return &redacted
+}
+
+// plaintextSecrets names the credentials and sensitive headers of a server that still hold a literal value rather
+// than a secret reference or a variable. Headers are sensitive when the debug log's redaction rules hide them
+func plaintextSecrets(server *config.Server) []string {
+	literal := func(value string) bool {
+		return value != "" && !strings.Contains(value, "{{")
+	}
+	var fields []string
+	if literal(server.Auth.Token) {
+		fields = append(fields, "the token")
+	}
+	if literal(server.Auth.ClientSecret) {
+		fields = append(fields, "the client secret")
+	}
+	if literal(server.Route.Password) {
+		fields = append(fields, "the route password")
+	}
+	for _, key := range slices.Sorted(maps.Keys(server.Metadata)) {
+		if grpcConn.DebugLog == nil || !grpcConn.DebugLog.Sensitive(key) {
+			continue
+		}
+		for _, value := range server.Metadata[key] {
+			if literal(value) {
+				fields = append(fields, "the "+key+" header")
+				break
+			}
+		}
+	}
+	return fields
+}
+
+// unlockSecrets asks for the vault passphrase and calls then once it is unlocked
+func (toolUI *UI) unlockSecrets(then func()) {
+	passphrase := widget.NewPasswordEntry()
+	dialog.ShowForm("Unlock Secret Vault", "Unlock", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Passphrase", passphrase),
+	}, func(unlock bool) {
+		if !unlock {
+			return
+		}
+		if err := secrets.Unlock(passphrase.Text); err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		then()
+	}, toolUI.Window)
+}
+
+func (toolUI *UI) showSecretsDialog() {
+	if !secrets.Exists() {
+		passphrase := widget.NewPasswordEntry()
+		confirm := widget.NewPasswordEntry()
+		dialog.ShowForm("Create Secret Vault", "Create", "Cancel", []*widget.FormItem{
+			widget.NewFormItem("Passphrase", passphrase),
+			widget.NewFormItem("Confirm", confirm),
+		}, func(create bool) {
+			if !create {
+				return
+			}
+			if passphrase.Text != confirm.Text {
+				dialog.ShowError(fmt.Errorf("passphrases do not match"), toolUI.Window)
+				return
+			}
+			if err := secrets.Create(passphrase.Text); err != nil {
+				dialog.ShowError(err, toolUI.Window)
+				return
+			}
+			toolUI.showSecretsDialog()
+		}, toolUI.Window)
+		return
+	}
+
+	if !secrets.Unlocked() {
+		toolUI.unlockSecrets(toolUI.showSecretsDialog)
+		return
+	}
+
+	var pairs []*secretPair
+	secretGrid := container.New(layout.NewVBoxLayout())
+	addSecret := func(name string, value string) {
+		ne := widget.NewEntry()
+		ne.SetPlaceHolder("Secret Name")
+		ne.SetText(name)
+		ve := widget.NewPasswordEntry()
+		ve.SetPlaceHolder("Secret Value")
+		ve.SetText(value)
+		copyButton := widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
+			toolUI.Window.Clipboard().SetContent(vault.Reference(ne.Text))
+		})
+		nameLabel := toolUI.getFieldLabel("Name")
+		valueLabel := toolUI.getFieldLabel("Value")
+		row := container.New(layout.NewGridLayout(2),
+			container.New(layout.NewBorderLayout(nil, nil, nameLabel, nil), nameLabel, ne),
+			container.New(layout.NewBorderLayout(nil, nil, valueLabel, copyButton), valueLabel, copyButton, ve))
+		secretGrid.Add(row)
+		pairs = append(pairs, &secretPair{ne, ve})
+	}
+	for _, name := range secrets.Names() {
+		value, err := secrets.Get(name)
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		addSecret(name, value)
+	}
+
+	info := widget.NewLabel("Use {{secret \"name\"}} in metadata, request fields or credentials, the copy button copies the reference.")
+	info.Wrapping = fyne.TextWrapWord
+
+	var secretsDialog dialog.Dialog
+	addButton := widget.NewButtonWithIcon("", theme.ContentAddIcon(), func() {
+		addSecret("", "")
+	})
+	lockButton := widget.NewButtonWithIcon("Lock", theme.LogoutIcon(), func() {
+		secrets.Lock()
+		secretsDialog.Hide()
+	})
+	buttonBox := container.New(layout.NewHBoxLayout(), addButton, lockButton)
+	content := container.New(layout.NewVBoxLayout(), info, secretGrid,
+		container.New(layout.NewBorderLayout(nil, nil, nil, buttonBox), buttonBox))
+
+	secretsDialog = dialog.NewCustomConfirm("Secrets", "Save", "Cancel", container.NewScroll(content), func(save bool) {
+		if !save {
+			return
+		}
+		keep := make(map[string]bool)
+		for _, pair := range pairs {
+			if pair.nameEntry.Text == "" {
+				continue
+			}
+			if err := secrets.Set(pair.nameEntry.Text, pair.valueEntry.Text); err != nil {
+				dialog.ShowError(err, toolUI.Window)
+				return
+			}
+			keep[pair.nameEntry.Text] = true
+		}
+		for _, name := range secrets.Names() {
+			if !keep[name] {
+				secrets.Delete(name)
+			}
+		}
+		if err := secrets.Save(); err != nil {
+			dialog.ShowError(err, toolUI.Window)
+		}
+	}, toolUI.Window)
+	size := toolUI.MainContent.Size()
+	secretsDialog.Resize(fyne.NewSize(size.Width/1.1, size.Height/1.1))
+	secretsDialog.Show()
+}
@@ -1,11 +1,24 @@
 package ui
 
 import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"grpc_ui_tool/auth"
+	"grpc_ui_tool/config"
+	"grpc_ui_tool/proto"
+	"grpc_ui_tool/route"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	grpcmd "google.golang.org/grpc/metadata"
 )
 
 type metadataPair struct {
@@ -13,20 +26,14 @@ type metadataPair struct {
 	valueEntry *widget.Entry
 }
 
-type connectionDetails struct {
-	hostname string
-	port     string
-	metadata map[string]string
-}
-
 var metadata []*metadataPair
 
-func (toolUI *UI) showServerUI(connDetails *connectionDetails) {
-	if toolUI.ServerContent != nil && connDetails == nil {
+func (toolUI *UI) showServerUI(server *config.Server) {
+	if toolUI.ServerContent != nil && server == nil {
 		toolUI.ServerContent.Show()
 		toolUI.CurrentView = ServerView
 		return
-	} else if toolUI.ServerContent != nil && connDetails != nil {
+	} else if toolUI.ServerContent != nil && server != nil {
 		toolUI.MainContent.Remove(toolUI.ServerContent)
 	}
 	toolUI.clearMetadata()
@@ -40,6 +47,7 @@ func (toolUI *UI) showServerUI(connDetails *connectionDetails) {
 	serverForm.Add(hostLabel)
 
 	hostEntry := widget.NewEntry()
+	hostEntry.SetPlaceHolder("host, IPv6 address, unix:///path, unix-abstract:name or dns:///host:port")
 	serverForm.Add(hostEntry)
 
 	portLabel := widget.NewLabel("GRPC Server Port")
@@ -47,8 +55,26 @@ func (toolUI *UI) showServerUI(connDetails *connectionDetails) {
 	serverForm.Add(portLabel)
 
 	portEntry := widget.NewEntry()
+	portEntry.SetPlaceHolder("empty when the hostname is a target URI")
 	serverForm.Add(portEntry)
 
+	addressesLabel := widget.NewLabel("Static Addresses")
+	addressesLabel.Alignment = fyne.TextAlignTrailing
+	serverForm.Add(addressesLabel)
+
+	addressesEntry := widget.NewMultiLineEntry()
+	addressesEntry.SetPlaceHolder("host:port per line, used instead of the hostname and port")
+	addressesEntry.SetMinRowsVisible(2)
+	serverForm.Add(addressesEntry)
+
+	balancingLabel := widget.NewLabel("Load Balancing")
+	balancingLabel.Alignment = fyne.TextAlignTrailing
+	serverForm.Add(balancingLabel)
+
+	balancingSelect := widget.NewSelect(balancingNames(), nil)
+	balancingSelect.SetSelected(balancingName(""))
+	serverForm.Add(balancingSelect)
+
 	serverBox.Add(serverForm)
 
 	sep := widget.NewSeparator()
@@ -64,22 +90,7 @@ func (toolUI *UI) showServerUI(connDetails *connectionDetails) {
 	serverBox.Add(metaGrid)
 
 	addMetaDataButton := widget.NewButtonWithIcon("", theme.ContentAddIcon(), func() {
-		mke := widget.NewEntry()
-		mke.SetPlaceHolder("Metadata Key")
-		keyLabel := widget.NewLabel("Key")
-		keyLabel.TextStyle = fyne.TextStyle{Bold: true}
-		keyItem := container.New(layout.NewBorderLayout(nil, nil, keyLabel, nil), keyLabel, mke)
-
-		mve := widget.NewEntry()
-		mve.SetPlaceHolder("Metadata Value")
-		valueLabel := widget.NewLabel("Value")
-		valueLabel.TextStyle = fyne.TextStyle{Bold: true}
-		valueItem := container.New(layout.NewBorderLayout(nil, nil, valueLabel, nil), valueLabel, mve)
-
-		metaGrid.Add(keyItem)
-		metaGrid.Add(valueItem)
-
-		metadata = append(metadata, &metadataPair{mke, mve})
+		toolUI.addMaskedMetadataItem(metaGrid, &metadata, "", "")
 	})
 	clearMetaDataButton := widget.NewButtonWithIcon("", theme.ContentClearIcon(), func() {
 		metaGrid.RemoveAll()
@@ -89,21 +100,94 @@ func (toolUI *UI) showServerUI(connDetails *connectionDetails) {
 	serverBox.Add(container.New(layout.NewBorderLayout(nil, nil, nil, metadataButtonBox),
 		metadataButtonBox))
 
+	var authCfg auth.Config
+	var tlsCfg proto.TLSConfig
+	var transportCfg proto.TransportConfig
+	var routeCfg route.Config
+	var tuningCfg proto.TuningConfig
+	var traceCfg proto.TraceConfig
+	var serviceConfig string
+	if server != nil {
+		authCfg = server.Auth
+		tlsCfg = server.TLS
+		transportCfg = server.Transport
+		routeCfg = server.Route
+		tuningCfg = server.Tuning
+		traceCfg = server.Trace
+		serviceConfig = server.ServiceConfig
+	}
+	tlsBox, getTLS := toolUI.createTLSForm(tlsCfg)
+	serverBox.Add(widget.NewSeparator())
+	serverBox.Add(tlsBox)
+
+	transportBox, getTransport := toolUI.createTransportForm(transportCfg)
+	serverBox.Add(transportBox)
+
+	routeBox, getRoute := toolUI.createRouteForm(routeCfg)
+	serverBox.Add(widget.NewSeparator())
+	serverBox.Add(routeBox)
+
+	tuningBox, getTuning := toolUI.createTuningForm(tuningCfg)
+	serverBox.Add(widget.NewSeparator())
+	serverBox.Add(tuningBox)
+
+	traceBox, getTrace := toolUI.createTraceForm(traceCfg)
+	serverBox.Add(traceBox)
+
+	// retry and hedging policies, timeouts and load balancing config in grpc's service config JSON
+	serviceConfigEntry := widget.NewMultiLineEntry()
+	serviceConfigEntry.SetPlaceHolder(`{"methodConfig": [{"name": [{"service": "package.Service"}], "retryPolicy": {` +
+		`"maxAttempts": 3, "initialBackoff": "0.1s", "maxBackoff": "1s", "backoffMultiplier": 2, ` +
+		`"retryableStatusCodes": ["UNAVAILABLE"]}}]}`)
+	serviceConfigEntry.Wrapping = fyne.TextWrapWord
+	serviceConfigEntry.SetMinRowsVisible(6)
+	serviceConfigEntry.SetText(serviceConfig)
+	serverBox.Add(widget.NewAccordion(widget.NewAccordionItem("Service Config", serviceConfigEntry)))
+
+	authBox, getAuth := toolUI.createAuthForm(authCfg)
+	serverBox.Add(widget.NewSeparator())
+	serverBox.Add(authBox)
+
 	submitButton := widget.NewButton("Submit", func() {
-		var metaMap = make(map[string]string)
-		for _, pair := range metadata {
-			if pair.keyEntry.Text != "" && pair.valueEntry.Text != "" {
-				metaMap[pair.keyEntry.Text] = pair.valueEntry.Text
-			}
+		metaMap, err := getMetadata(metadata)
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		tuning, err := getTuning()
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		// the service config is checked on a copy so the connection keeps its settings when it is invalid
+		addresses := strings.Fields(addressesEntry.Text)
+		checked := grpcConn.WithVariables(nil)
+		checked.SetAddresses(addresses, balancingPolicy(balancingSelect.Selected))
+		checked.SetServiceConfig(strings.TrimSpace(serviceConfigEntry.Text))
+		if err = checked.CheckServiceConfig(); err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
 		}
 		grpcConn.SetConnectionDetails(hostEntry.Text, portEntry.Text, metaMap)
-		if hostEntry.Text == "" && portEntry.Text == "" {
+		grpcConn.SetAddresses(addresses, balancingPolicy(balancingSelect.Selected))
+		grpcConn.SetAuth(getAuth())
+		grpcConn.SetTLS(getTLS())
+		grpcConn.SetTransport(getTransport())
+		grpcConn.SetRoute(getRoute())
+		grpcConn.SetTuning(tuning)
+		grpcConn.SetTrace(getTrace())
+		grpcConn.SetServiceConfig(checked.ServiceConfig)
+		if hostEntry.Text == "" && portEntry.Text == "" && len(addresses) == 0 {
 			return
 		}
 
-		toolUI.ServerLabel.SetText(hostEntry.Text)
+		toolUI.ServerLabel.SetText(serverLabel(hostEntry.Text, addresses))
 		toolUI.hideOrClearAllMainContent()
 		toolUI.showProtoUI()
+		if ignored := grpcConn.NativeOnlySettings(); len(ignored) > 0 {
+			dialog.ShowInformation("Server", "These settings only apply to native gRPC and are ignored by "+
+				grpcConn.Transport.Protocol.String()+": "+strings.Join(ignored, ", "), toolUI.Window)
+		}
 	})
 	submitButton.Importance = widget.HighImportance
 	submitButton.SetIcon(theme.ConfirmIcon())
@@ -112,30 +196,17 @@ func (toolUI *UI) showServerUI(connDetails *connectionDetails) {
 	buttonBox.Add(submitButton)
 	serverBox.Add(buttonBox)
 
-	if connDetails != nil {
-		hostEntry.SetText(connDetails.hostname)
-		portEntry.SetText(connDetails.port)
+	if server != nil {
+		hostEntry.SetText(server.Hostname)
+		portEntry.SetText(server.Port)
+		addressesEntry.SetText(strings.Join(server.Addresses, "\n"))
+		balancingSelect.SetSelected(balancingName(server.LoadBalancing))
 		metaGrid.RemoveAll()
 		toolUI.clearMetadata()
-		for key, value := range connDetails.metadata {
-			mke := widget.NewEntry()
-			mke.SetText(key)
-			mke.SetPlaceHolder("Metadata Key")
-			keyLabel := widget.NewLabel("Key")
-			keyLabel.TextStyle = fyne.TextStyle{Bold: true}
-			keyItem := container.New(layout.NewBorderLayout(nil, nil, keyLabel, nil), keyLabel, mke)
-
-			mve := widget.NewEntry()
-			mve.SetText(value)
-			mve.SetPlaceHolder("Metadata Value")
-			valueLabel := widget.NewLabel("Value")
-			valueLabel.TextStyle = fyne.TextStyle{Bold: true}
-			valueItem := container.New(layout.NewBorderLayout(nil, nil, valueLabel, nil), valueLabel, mve)
-
-			metaGrid.Add(keyItem)
-			metaGrid.Add(valueItem)
-
-			metadata = append(metadata, &metadataPair{mke, mve})
+		for _, key := range slices.Sorted(maps.Keys(server.Metadata)) {
+			for _, value := range server.Metadata[key] {
+				toolUI.addMaskedMetadataItem(metaGrid, &metadata, key, value)
+			}
 		}
 	}
 
@@ -145,6 +216,155 @@ func (toolUI *UI) showServerUI(connDetails *connectionDetails) {
 	toolUI.CurrentView = ServerView
 }
 
+// saveServer asks where to save the current server. Credentials and sensitive headers are stored as secret
+// references, a server still holding plaintext ones is not saved, unlocking the vault first when it may hold them
+func (toolUI *UI) saveServer() {
+	server := redactServer(&config.Server{
+		Hostname:      grpcConn.Hostname,
+		Port:          grpcConn.Port,
+		Addresses:     grpcConn.Addresses,
+		LoadBalancing: grpcConn.LoadBalancing,
+		Metadata:      grpcConn.Metadata,
+		Auth:          grpcConn.Auth,
+		TLS:           grpcConn.TLS,
+		Transport:     grpcConn.Transport,
+		Route:         grpcConn.Route,
+		Tuning:        grpcConn.Tuning,
+		Trace:         grpcConn.Trace,
+		ServiceConfig: grpcConn.ServiceConfig,
+	})
+	if fields := plaintextSecrets(server); len(fields) > 0 {
+		if secrets != nil && secrets.Exists() && !secrets.Unlocked() {
+			toolUI.unlockSecrets(toolUI.saveServer)
+			return
+		}
+		dialog.ShowError(fmt.Errorf("the server was not saved because %s would be stored in plaintext, add them to "+
+			"the secret vault and use {{secret \"name\"}} instead", strings.Join(fields, ", ")), toolUI.Window)
+		return
+	}
+
+	saveDialog := dialog.NewFileSave(func(closer fyne.URIWriteCloser, err error) {
+		if closer == nil {
+			return
+		}
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		err = config.WriteServer(closer, server)
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			_ = closer.Close()
+			return
+		}
+		err = closer.Close()
+		if err != nil {
+			dialog.ShowError(err, toolUI.Window)
+			return
+		}
+		toolUI.ServerFile = closer.URI().Path()
+	}, toolUI.Window)
+	saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{".gtserver"}))
+	saveDialog.SetFileName("Please Save With .gtserver Extension")
+	saveDialog.SetView(dialog.ListView)
+	saveDialog.Show()
+}
+
+func balancingNames() []string {
+	names := make([]string, 0, len(proto.LoadBalancingPolicies))
+	for _, policy := range proto.LoadBalancingPolicies {
+		names = append(names, balancingName(policy))
+	}
+	return names
+}
+
+func balancingName(policy string) string {
+	if policy == "" {
+		return "Default"
+	}
+	return policy
+}
+
+func balancingPolicy(name string) string {
+	if name == balancingName("") {
+		return ""
+	}
+	return name
+}
+
+// serverLabel returns the text shown for the current server in the top bar
+func serverLabel(hostname string, addresses []string) string {
+	if len(addresses) > 0 {
+		return strings.Join(addresses, ", ")
+	}
+	return hostname
+}
+
+// addMetadataItem adds a key and value row to metaGrid and tracks its entries in pairs, keys can be repeated to send
+// multiple values and values of keys ending in -bin are entered as base64
+func (toolUI *UI) addMetadataItem(metaGrid *fyne.Container, pairs *[]*metadataPair, key string, value string) {
+	toolUI.addMetadataRow(metaGrid, pairs, key, value, false)
+}
+
+// addMaskedMetadataItem adds a metadata row whose value is hidden until revealed, for server metadata which often
+// holds credentials
+func (toolUI *UI) addMaskedMetadataItem(metaGrid *fyne.Container, pairs *[]*metadataPair, key string, value string) {
+	toolUI.addMetadataRow(metaGrid, pairs, key, value, true)
+}
+
+func (toolUI *UI) addMetadataRow(metaGrid *fyne.Container, pairs *[]*metadataPair, key string, value string, masked bool) {
+	mke := widget.NewEntry()
+	mke.SetText(key)
+	mke.SetPlaceHolder("Metadata Key")
+	keyLabel := widget.NewLabel("Key")
+	keyLabel.TextStyle = fyne.TextStyle{Bold: true}
+	keyItem := container.New(layout.NewBorderLayout(nil, nil, keyLabel, nil), keyLabel, mke)
+
+	mve := widget.NewEntry()
+	if masked {
+		mve = widget.NewPasswordEntry()
+	}
+	mve.SetText(value)
+	mve.SetPlaceHolder("Metadata Value")
+	valueLabel := widget.NewLabel("Value")
+	valueLabel.TextStyle = fyne.TextStyle{Bold: true}
+	valueItem := container.New(layout.NewBorderLayout(nil, nil, valueLabel, nil), valueLabel, mve)
+
+	metaGrid.Add(keyItem)
+	metaGrid.Add(valueItem)
+
+	*pairs = append(*pairs, &metadataPair{mke, mve})
+}
+
+// getMetadata collects the metadata rows, keys without variables are lowercased and rows without a key or value are skipped.
+// Keys or values using variables are only validated once expanded at send time
+func getMetadata(pairs []*metadataPair) (grpcmd.MD, error) {
+	md := grpcmd.MD{}
+	for _, pair := range pairs {
+		if pair.keyEntry.Text == "" || pair.valueEntry.Text == "" {
+			continue
+		}
+		key := proto.MetadataKey(pair.keyEntry.Text)
+		md[key] = append(md[key], pair.valueEntry.Text)
+	}
+
+	check := grpcmd.MD{}
+	for key, values := range md {
+		if strings.Contains(key, "{{") {
+			continue
+		}
+		for _, value := range values {
+			if !strings.Contains(value, "{{") {
+				check[key] = append(check[key], value)
+			}
+		}
+	}
+	if err := proto.ValidateMetadata(check); err != nil {
+		return nil, err
+	}
+	return md, nil
+}
+
 func (toolUI *UI) clearMetadata() {
 	metadata = nil
 }
@@ -0,0 +1,157 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+
+	"grpc_ui_tool/config"
+	"grpc_ui_tool/proto"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// createTLSForm builds the transport security settings, the CA certificate is only shown when the server certificate
+// is verified. The returned function reads the current settings back from the form
+func (toolUI *UI) createTLSForm(cfg proto.TLSConfig) (*fyne.Container, func() proto.TLSConfig) {
+	caEntry := widget.NewEntry()
+	caEntry.SetPlaceHolder("system roots, or /path/to/ca.pem")
+	caEntry.SetText(cfg.CACert)
+	browseButton := widget.NewButtonWithIcon("", theme.FolderOpenIcon(), func() {
+		openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if reader == nil {
+				return
+			}
+			_ = reader.Close()
+			if err != nil {
+				dialog.ShowError(err, toolUI.Window)
+				return
+			}
+			caEntry.SetText(reader.URI().Path())
+		}, toolUI.Window)
+		openDialog.SetFilter(storage.NewExtensionFileFilter([]string{".pem", ".crt", ".cer"}))
+		openDialog.Show()
+	})
+	caLabel := toolUI.getFieldLabel("CA Certificate")
+	caRow := container.New(layout.NewBorderLayout(nil, nil, caLabel, browseButton), caLabel, browseButton, caEntry)
+
+	pinText := widget.NewLabel("")
+	pinText.Wrapping = fyne.TextWrapBreak
+	showPin := func() {
+		if cfg.Pin == "" {
+			pinText.SetText("none yet, the certificate of the next call is trusted")
+		} else {
+			pinText.SetText(cfg.Pin)
+		}
+	}
+	showPin()
+	toolUI.setFormPin = func(pin string) {
+		cfg.Pin = pin
+		showPin()
+	}
+	forgetButton := widget.NewButtonWithIcon("Forget", theme.DeleteIcon(), func() {
+		toolUI.setFormPin("")
+	})
+	pinLabel := toolUI.getFieldLabel("Pinned Certificate")
+	pinRow := container.New(layout.NewBorderLayout(nil, nil, pinLabel, forgetButton), pinLabel, forgetButton, pinText)
+
+	names := make([]string, 0, len(proto.TLSModes))
+	for _, m := range proto.TLSModes {
+		names = append(names, m.String())
+	}
+	modeSelect := widget.NewSelect(names, func(selected string) {
+		for _, m := range proto.TLSModes {
+			if m.String() == selected {
+				cfg.Mode = m
+			}
+		}
+		if cfg.Mode == proto.TLSVerify {
+			caRow.Show()
+		} else {
+			caRow.Hide()
+		}
+		if cfg.Mode == proto.TLSTrustOnFirstUse {
+			pinRow.Show()
+		} else {
+			pinRow.Hide()
+		}
+	})
+	modeLabel := toolUI.getFieldLabel("Transport Security")
+	tlsBox := container.New(layout.NewVBoxLayout(),
+		container.New(layout.NewBorderLayout(nil, nil, modeLabel, nil), modeLabel, modeSelect), caRow, pinRow)
+	modeSelect.SetSelected(cfg.Mode.String())
+
+	return tlsBox, func() proto.TLSConfig {
+		cfg.CACert = ""
+		if cfg.Mode == proto.TLSVerify {
+			cfg.CACert = caEntry.Text
+		}
+		result := cfg
+		if cfg.Mode != proto.TLSTrustOnFirstUse {
+			result.Pin = ""
+		}
+		return result
+	}
+}
+
+// handlePinning asks whether to trust a certificate that changed since it was pinned on first use, it reports
+// whether the call failed because the certificate changed
+func (toolUI *UI) handlePinning(resp *proto.Response, err error) bool {
+	var pinErr *proto.PinMismatchError
+	if !errors.As(err, &pinErr) {
+		return false
+	}
+
+	message := widget.NewLabel("The server presented a different certificate from the one trusted on its first use. " +
+		"This is expected after the certificate was renewed, but can also mean the connection is being intercepted.\n\n" +
+		"Pinned: " + pinErr.Pinned + "\nPresented: " + pinErr.Presented)
+	message.Wrapping = fyne.TextWrapWord
+	content := container.NewBorder(message, nil, nil, nil)
+	if resp.Peer != nil {
+		content.Add(container.NewScroll(widget.NewTextGridFromString(formatPeer(resp.Peer))))
+	}
+	pinDialog := dialog.NewCustomConfirm("Server Certificate Changed", "Trust New Certificate", "Reject", content,
+		func(accept bool) {
+			if accept {
+				toolUI.pinCertificate(pinErr.Presented)
+			}
+		}, toolUI.Window)
+	size := toolUI.MainContent.Size()
+	pinDialog.Resize(fyne.NewSize(size.Width/1.5, size.Height/1.5))
+	pinDialog.Show()
+	return true
+}
+
+// pinCertificate trusts the certificate with the given fingerprint from now on and saves it
+func (toolUI *UI) pinCertificate(pin string) {
+	grpcConn.Pin(pin)
+	toolUI.savePin(pin)
+}
+
+// savePin shows a pinned certificate in the server form and stores it in the open server file when the file also
+// trusts on first use, it is called for certificates pinned by any connection
+func (toolUI *UI) savePin(pin string) {
+	if toolUI.setFormPin != nil {
+		toolUI.setFormPin(pin)
+	}
+	if toolUI.ServerFile == "" {
+		return
+	}
+	server, err := config.LoadServer(toolUI.ServerFile)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("pinned certificate not saved: %w", err), toolUI.Window)
+		return
+	}
+	if server.TLS.Mode != proto.TLSTrustOnFirstUse {
+		return
+	}
+	server.TLS.Pin = pin
+	if err = config.SaveServer(toolUI.ServerFile, server); err != nil {
+		dialog.ShowError(fmt.Errorf("pinned certificate not saved: %w", err), toolUI.Window)
+	}
+}
@@ -0,0 +1,80 @@
+package ui
+
+import (
+	"strings"
+
+	"grpc_ui_tool/proto"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+// createTraceForm builds the trace context settings, collapsed by default since the W3C headers are always sent.
+// The destination is only shown when spans are exported. The returned function reads the settings back from the form
+func (toolUI *UI) createTraceForm(cfg proto.TraceConfig) (fyne.CanvasObject, func() proto.TraceConfig) {
+	traceForm := container.New(layout.NewFormLayout())
+
+	b3Names := make([]string, 0, len(proto.B3Modes))
+	for _, m := range proto.B3Modes {
+		b3Names = append(b3Names, m.String())
+	}
+	b3Select := widget.NewSelect(b3Names, func(selected string) {
+		for _, m := range proto.B3Modes {
+			if m.String() == selected {
+				cfg.B3 = m
+			}
+		}
+	})
+	b3Select.SetSelected(cfg.B3.String())
+	addFormRow(traceForm, "B3 Headers", b3Select)
+
+	traceStateEntry := widget.NewEntry()
+	traceStateEntry.SetPlaceHolder("vendor=value, sent as tracestate when a call starts a new trace")
+	traceStateEntry.SetText(cfg.TraceState)
+	addFormRow(traceForm, "Trace State", traceStateEntry)
+
+	destinationLabel := widget.NewLabel("Destination")
+	destinationLabel.Alignment = fyne.TextAlignTrailing
+	destinationEntry := widget.NewEntry()
+	destinationEntry.SetText(cfg.Destination)
+	exportNames := make([]string, 0, len(proto.SpanExports))
+	for _, e := range proto.SpanExports {
+		exportNames = append(exportNames, e.String())
+	}
+	exportSelect := widget.NewSelect(exportNames, func(selected string) {
+		for _, e := range proto.SpanExports {
+			if e.String() == selected {
+				cfg.Export = e
+			}
+		}
+		switch cfg.Export {
+		case proto.ExportFile:
+			destinationEntry.SetPlaceHolder("/path/to/spans.jsonl")
+		case proto.ExportCollector:
+			destinationEntry.SetPlaceHolder(proto.DefaultCollector)
+		}
+		if cfg.Export == proto.ExportNone {
+			destinationLabel.Hide()
+			destinationEntry.Hide()
+		} else {
+			destinationLabel.Show()
+			destinationEntry.Show()
+		}
+	})
+	addFormRow(traceForm, "Export Spans", exportSelect)
+	traceForm.Add(destinationLabel)
+	traceForm.Add(destinationEntry)
+	exportSelect.SetSelected(cfg.Export.String())
+
+	accordion := widget.NewAccordion(widget.NewAccordionItem("Tracing", traceForm))
+	return accordion, func() proto.TraceConfig {
+		cfg.TraceState = strings.TrimSpace(traceStateEntry.Text)
+		cfg.Destination = strings.TrimSpace(destinationEntry.Text)
+		if cfg.Export == proto.ExportNone {
+			cfg.Destination = ""
+		}
+		return cfg
+	}
+}
@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"grpc_ui_tool/proto"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+// createTransportForm builds the protocol settings, the codec is only shown for the Connect protocols.
+// The returned function reads the current settings back from the form
+func (toolUI *UI) createTransportForm(cfg proto.TransportConfig) (*fyne.Container, func() proto.TransportConfig) {
+	codecNames := make([]string, 0, len(proto.Codecs))
+	for _, c := range proto.Codecs {
+		codecNames = append(codecNames, c.String())
+	}
+	codecSelect := widget.NewSelect(codecNames, func(selected string) {
+		for _, c := range proto.Codecs {
+			if c.String() == selected {
+				cfg.Codec = c
+			}
+		}
+	})
+	codecSelect.SetSelected(cfg.Codec.String())
+	codecLabel := toolUI.getFieldLabel("Codec")
+	codecRow := container.New(layout.NewBorderLayout(nil, nil, codecLabel, nil), codecLabel, codecSelect)
+
+	protocolNames := make([]string, 0, len(proto.Protocols))
+	for _, p := range proto.Protocols {
+		protocolNames = append(protocolNames, p.String())
+	}
+	protocolSelect := widget.NewSelect(protocolNames, func(selected string) {
+		for _, p := range proto.Protocols {
+			if p.String() == selected {
+				cfg.Protocol = p
+			}
+		}
+		if isConnect(cfg.Protocol) {
+			codecRow.Show()
+		} else {
+			codecRow.Hide()
+		}
+	})
+	protocolLabel := toolUI.getFieldLabel("Protocol")
+	transportBox := container.New(layout.NewVBoxLayout(),
+		container.New(layout.NewBorderLayout(nil, nil, protocolLabel, nil), protocolLabel, protocolSelect), codecRow)
+	protocolSelect.SetSelected(cfg.Protocol.String())
+
+	return transportBox, func() proto.TransportConfig {
+		if !isConnect(cfg.Protocol) {
+			cfg.Codec = proto.CodecProto
+		}
+		return cfg
+	}
+}
+
+func isConnect(protocol proto.Protocol) bool {
+	return protocol == proto.ProtocolConnect || protocol == proto.ProtocolConnectStream
+}
@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"grpc_ui_tool/config"
+	"grpc_ui_tool/proto"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+type tuningEntry struct {
+	label string
+	name  string
+	hint  string
+}
+
+// createTuningForm builds the advanced connection settings, collapsed by default since most servers need none.
+// The returned function parses the current settings back from the form
+func (toolUI *UI) createTuningForm(cfg proto.TuningConfig) (fyne.CanvasObject, func() (proto.TuningConfig, error)) {
+	tuningForm := container.New(layout.NewFormLayout())
+
+	compressionSelect := widget.NewSelect([]string{"None", "gzip"}, nil)
+	compressionSelect.SetSelected("None")
+	if cfg.Compression != "" {
+		compressionSelect.SetSelected(cfg.Compression)
+	}
+	addFormRow(tuningForm, "Request Compression", compressionSelect)
+
+	entries := []*tuningEntry{
+		{"Max Send Size", "MaxSendSize", "bytes, unlimited by default"},
+		{"Max Receive Size", "MaxReceiveSize", "bytes, 4194304 by default"},
+		{"Authority", "Authority", "the target's host by default"},
+		{"User Agent", "UserAgent", "grpc-tool/1.0"},
+		{"Keepalive Time", "KeepaliveTime", "e.g. 30s, native gRPC only"},
+		{"Keepalive Timeout", "KeepaliveTimeout", "20s by default"},
+		{"Initial Window Size", "InitialWindowSize", "bytes, native gRPC only"},
+		{"Initial Connection Window Size", "InitialConnWindowSize", "bytes, native gRPC only"},
+	}
+	widgets := make(map[*tuningEntry]*widget.Entry)
+	for _, e := range entries {
+		ent := widget.NewEntry()
+		ent.SetPlaceHolder(e.hint)
+		ent.SetText(config.GetTuningField(&cfg, e.name))
+		widgets[e] = ent
+		addFormRow(tuningForm, e.label, ent)
+	}
+
+	withoutCallsCheck := widget.NewCheck("Send keepalive pings without active calls", nil)
+	withoutCallsCheck.SetChecked(cfg.KeepaliveWithoutCalls)
+	tuningForm.Add(widget.NewLabel(""))
+	tuningForm.Add(withoutCallsCheck)
+	waitCheck := widget.NewCheck("Wait for ready, queue calls until the server is reachable", nil)
+	waitCheck.SetChecked(cfg.WaitForReady)
+	tuningForm.Add(widget.NewLabel(""))
+	tuningForm.Add(waitCheck)
+
+	accordion := widget.NewAccordion(widget.NewAccordionItem("Advanced Connection Settings", tuningForm))
+	return accordion, func() (proto.TuningConfig, error) {
+		tuning := proto.TuningConfig{
+			KeepaliveWithoutCalls: withoutCallsCheck.Checked,
+			WaitForReady:          waitCheck.Checked,
+		}
+		if compressionSelect.Selected != "None" {
+			tuning.Compression = compressionSelect.Selected
+		}
+		for _, e := range entries {
+			if err := config.SetTuningField(&tuning, e.name, widgets[e].Text); err != nil {
+				return cfg, err
+			}
+		}
+		return tuning, nil
+	}
+}
+
+func addFormRow(form *fyne.Container, text string, field fyne.CanvasObject) {
+	label := widget.NewLabel(text)
+	label.Alignment = fyne.TextAlignTrailing
+	form.Add(label)
+	form.Add(field)
+}
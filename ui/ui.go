@@ -1,11 +1,9 @@
 package ui
 
 import (
-	"bufio"
 	"fmt"
-	"os"
-	"strings"
 
+	"grpc_ui_tool/config"
 	"grpc_ui_tool/proto"
 
 	"fyne.io/fyne/v2"
@@ -44,14 +42,32 @@ type UI struct {
 	HomeButton *widget.Button
 	BackButton *widget.Button
 
-	OpenButton *widget.Button
-	SaveButton *widget.Button
+	OpenButton   *widget.Button
+	SaveButton   *widget.Button
+	ImportButton *widget.Button
+
+	EnvironmentSelect *widget.Select
+	EnvironmentButton *widget.Button
+
+	HistoryButton    *widget.Button
+	SecretsButton    *widget.Button
+	CollectionButton *widget.Button
+	MockButton       *widget.Button
+	ProxyButton      *widget.Button
+	DebugLogButton   *widget.Button
 
 	ServerContent *container.Scroll
 	ProtoContent  *container.Scroll
 	InputContent  *container.Scroll
 
 	CurrentView View
+
+	ServerFile  string
+	ProtoFile   string
+	ImportPaths []string
+
+	// setFormPin shows a certificate pinned after the server form was built, so submitting it again keeps the pin
+	setFormPin func(pin string)
 }
 
 var grpcConn *proto.GrpcConnection
@@ -60,6 +76,7 @@ var grpcConn *proto.GrpcConnection
 func CreateUI(conn *proto.GrpcConnection) *UI {
 	toolUI := &UI{}
 	grpcConn = conn
+	grpcConn.OnPin = toolUI.savePin
 
 	toolUI.App = app.New()
 	toolUI.Window = toolUI.App.NewWindow("GRPC Tool")
@@ -95,45 +112,19 @@ func CreateUI(conn *proto.GrpcConnection) *UI {
 				return
 			}
 
-			var hostname, port string
-			fileMetadata := make(map[string]string)
-
-			file, err := os.Open(reader.URI().Path())
+			server, err := config.ReadServer(reader)
 			if err != nil {
-				dialog.ShowError(err, toolUI.Window)
+				dialog.ShowError(fmt.Errorf("invalid server configuration %s: %w", reader.URI().Name(), err), toolUI.Window)
+				_ = reader.Close()
 				return
 			}
-
-			scanner := bufio.NewScanner(file)
-			for scanner.Scan() {
-				line := scanner.Text()
-				split := strings.Split(line, ":")
-				if len(split) < 2 {
-					dialog.ShowError(fmt.Errorf("invalid line in server configuration: %s", line), toolUI.Window)
-					return
-				}
-				switch split[0] {
-				case "Hostname":
-					hostname = split[1]
-				case "Port":
-					port = split[1]
-				case "Metadata":
-					if len(split) < 3 {
-						dialog.ShowError(fmt.Errorf("invalid line in server configuration: %s", line), toolUI.Window)
-						return
-					}
-					fileMetadata[split[1]] = split[2]
-				}
-			}
-			err = file.Close()
+			err = reader.Close()
 			if err != nil {
 				dialog.ShowError(err, toolUI.Window)
 				return
 			}
-			toolUI.showServerUI(&connectionDetails{
-				hostname: hostname,
-				port:     port,
-				metadata: fileMetadata})
+			toolUI.ServerFile = reader.URI().Path()
+			toolUI.showServerUI(server)
 		}, toolUI.Window)
 		openDialog.SetFilter(storage.NewExtensionFileFilter([]string{".gtserver"}))
 		openDialog.SetView(dialog.ListView)
@@ -141,34 +132,7 @@ func CreateUI(conn *proto.GrpcConnection) *UI {
 	})
 
 	toolUI.SaveButton = widget.NewButtonWithIcon("", theme.DocumentSaveIcon(), func() {
-		saveDialog := dialog.NewFileSave(func(closer fyne.URIWriteCloser, err error) {
-			if closer == nil {
-				return
-			}
-			if err != nil {
-				dialog.ShowError(err, toolUI.Window)
-				return
-			}
-			serverConfig := "Hostname" + ":" + grpcConn.Hostname + "\n"
-			serverConfig += "Port" + ":" + grpcConn.Port + "\n"
-			for key, value := range grpcConn.Metadata {
-				serverConfig += "Metadata:" + key + ":" + value + "\n"
-			}
-			_, err = closer.Write([]byte(serverConfig))
-			if err != nil {
-				dialog.ShowError(err, toolUI.Window)
-				return
-			}
-			err = closer.Close()
-			if err != nil {
-				dialog.ShowError(err, toolUI.Window)
-				return
-			}
-		}, toolUI.Window)
-		saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{".gtserver"}))
-		saveDialog.SetFileName("Please Save With .gtserver Extension")
-		saveDialog.SetView(dialog.ListView)
-		saveDialog.Show()
+		toolUI.saveServer()
 	})
 
 	toolUI.ServerLabel = widget.NewLabel("Server")
@@ -180,7 +144,40 @@ func CreateUI(conn *proto.GrpcConnection) *UI {
 	toolUI.TopLeft.Add(toolUI.HomeButton)
 	toolUI.TopLeft.Add(toolUI.BackButton)
 
+	toolUI.createEnvironmentSelect()
+	toolUI.createSecretsButton()
+	toolUI.createDebugLogButton()
+
+	toolUI.HistoryButton = widget.NewButtonWithIcon("", theme.HistoryIcon(), func() {
+		toolUI.showHistoryDialog()
+	})
+
+	toolUI.CollectionButton = widget.NewButtonWithIcon("", theme.ListIcon(), func() {
+		toolUI.showRunCollectionDialog()
+	})
+
+	toolUI.MockButton = widget.NewButtonWithIcon("", theme.ComputerIcon(), func() {
+		toolUI.showMockDialog()
+	})
+
+	toolUI.ProxyButton = widget.NewButtonWithIcon("", theme.MediaRecordIcon(), func() {
+		toolUI.showProxyDialog()
+	})
+
+	toolUI.ImportButton = widget.NewButtonWithIcon("", theme.UploadIcon(), func() {
+		toolUI.showImportDialog()
+	})
+
 	toolUI.TopRight = container.New(layout.NewHBoxLayout())
+	toolUI.TopRight.Add(toolUI.EnvironmentSelect)
+	toolUI.TopRight.Add(toolUI.EnvironmentButton)
+	toolUI.TopRight.Add(toolUI.SecretsButton)
+	toolUI.TopRight.Add(toolUI.HistoryButton)
+	toolUI.TopRight.Add(toolUI.CollectionButton)
+	toolUI.TopRight.Add(toolUI.MockButton)
+	toolUI.TopRight.Add(toolUI.ProxyButton)
+	toolUI.TopRight.Add(toolUI.DebugLogButton)
+	toolUI.TopRight.Add(toolUI.ImportButton)
 	toolUI.TopRight.Add(toolUI.OpenButton)
 	toolUI.TopRight.Add(toolUI.SaveButton)
 
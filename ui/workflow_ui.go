@@ -0,0 +1,152 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"grpc_ui_tool/config"
+	"grpc_ui_tool/suite"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showWorkflowDialog shows the steps of a collection and runs them in order, values captured by a step are
+// available to the steps after it and the list shows the status of every step as it runs
+func (toolUI *UI) showWorkflowDialog(collection *config.Collection) {
+	results := make([]*suite.CaseResult, len(collection.Requests))
+	for i, req := range collection.Requests {
+		results[i] = &suite.CaseResult{Request: req, Status: suite.Pending}
+	}
+	var report *suite.Report
+	selected := -1
+
+	details := widget.NewTextGrid()
+	summary := widget.NewLabel(fmt.Sprintf("%d steps", len(collection.Requests)))
+
+	steps := widget.NewList(func() int {
+		return len(results)
+	}, func() fyne.CanvasObject {
+		return container.NewHBox(widget.NewIcon(theme.RadioButtonIcon()), widget.NewLabel(""))
+	}, func(id widget.ListItemID, item fyne.CanvasObject) {
+		result := results[id]
+		row := item.(*fyne.Container)
+		row.Objects[0].(*widget.Icon).SetResource(statusIcon(result.Status))
+		row.Objects[1].(*widget.Label).SetText(fmt.Sprintf("%d. %s  %s", id+1, result.Request.Name, result.Status))
+	})
+	steps.OnSelected = func(id widget.ListItemID) {
+		selected = id
+		details.SetText(formatStep(results[id]))
+	}
+
+	stopOnFailure := widget.NewCheck("Stop on first failure", nil)
+	stopOnFailure.SetChecked(true)
+
+	var runButton, junitButton *widget.Button
+	runButton = widget.NewButtonWithIcon("Run", theme.MediaPlayIcon(), func() {
+		runButton.Disable()
+		junitButton.Disable()
+		summary.SetText("Running...")
+		opts := suite.Options{StopOnFailure: stopOnFailure.Checked}
+		go func() {
+			report = suite.Run(grpcConn, collection, opts, func(index int, result *suite.CaseResult) {
+				results[index] = result
+				steps.RefreshItem(index)
+				if index == selected {
+					details.SetText(formatStep(result))
+				}
+			})
+			summary.SetText(fmt.Sprintf("%d passed, %d failed, %d skipped", len(report.Cases)-report.Failures()-report.Skips(),
+				report.Failures(), report.Skips()))
+			runButton.Enable()
+			junitButton.Enable()
+		}()
+	})
+	runButton.Importance = widget.HighImportance
+
+	junitButton = widget.NewButton("Save JUnit XML", func() {
+		if report == nil {
+			return
+		}
+		saveDialog := dialog.NewFileSave(func(closer fyne.URIWriteCloser, err error) {
+			if closer == nil {
+				return
+			}
+			if err != nil {
+				dialog.ShowError(err, toolUI.Window)
+				return
+			}
+			if err = report.WriteJUnit(closer); err != nil {
+				dialog.ShowError(err, toolUI.Window)
+			}
+			if err = closer.Close(); err != nil {
+				dialog.ShowError(err, toolUI.Window)
+			}
+		}, toolUI.Window)
+		saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{".xml"}))
+		saveDialog.SetFileName("junit.xml")
+		saveDialog.Show()
+	})
+	junitButton.Disable()
+
+	controls := container.NewHBox(stopOnFailure, summary, junitButton, runButton)
+	split := container.NewHSplit(steps, container.NewScroll(details))
+	split.Offset = 0.35
+	content := container.NewBorder(controls, nil, nil, nil, split)
+
+	workflow := dialog.NewCustom(collection.Name, "Close", content, toolUI.Window)
+	size := toolUI.MainContent.Size()
+	workflow.Resize(fyne.NewSize(size.Width/1.05, size.Height/1.05))
+	workflow.Show()
+}
+
+func statusIcon(status suite.Status) fyne.Resource {
+	switch status {
+	case suite.Running:
+		return theme.MediaPlayIcon()
+	case suite.Passed:
+		return theme.ConfirmIcon()
+	case suite.Failed:
+		return theme.ErrorIcon()
+	case suite.Skipped:
+		return theme.MediaSkipNextIcon()
+	}
+	return theme.RadioButtonIcon()
+}
+
+func formatStep(result *suite.CaseResult) string {
+	var sb strings.Builder
+	req := result.Request
+	sb.WriteString("Method: " + req.Service + "/" + req.Method + "\n")
+	sb.WriteString("Status: " + result.Status.String() + "\n")
+	if result.Duration > 0 {
+		sb.WriteString("Duration: " + result.Duration.String() + "\n")
+	}
+	if result.Response != nil && result.Response.Trace != nil {
+		sb.WriteString("Trace ID: " + result.Response.Trace.TraceID + "\n")
+	}
+	if len(req.Captures) > 0 {
+		sb.WriteString("\nCaptures:\n")
+		for _, c := range req.Captures {
+			sb.WriteString("  " + c.Variable + " <- " + c.Source.String() + " " + c.Expression + "\n")
+		}
+	}
+	if result.Status != suite.Pending && result.Status != suite.Skipped && result.Status != suite.Running {
+		sb.WriteString("\nResults:\n" + result.FormatDetails("  "))
+	}
+	request := req.Body
+	if result.Response != nil && result.Response.Request != "" {
+		request = result.Response.Request
+	}
+	sb.WriteString("\nRequest:\n" + redactSecrets(request) + "\n")
+	if result.Error != nil {
+		sb.WriteString("\nError:\n" + redactSecrets(result.Error.Error()) + "\n")
+	} else if result.Response != nil {
+		sb.WriteString("\nResponse:\n" + redactSecrets(result.Response.Body) + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,386 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"grpc_ui_tool/config"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	fileVersion = 1
+	keyLength   = 32
+	saltLength  = 16
+	scryptN     = 1 << 15
+	scryptR     = 8
+	scryptP     = 1
+)
+
+// ErrLocked is returned when a secret is needed before the vault has been unlocked
+var ErrLocked = errors.New("secret vault is locked, unlock it from the top bar")
+
+// ErrPassphrase is returned when the passphrase does not decrypt the vault
+var ErrPassphrase = errors.New("incorrect passphrase")
+
+// file is the on disk form of the vault, only the secrets are encrypted
+type file struct {
+	Version    int    `json:"version"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Vault is a passphrase protected store of named secrets
+type Vault struct {
+	path string
+
+	mu      sync.RWMutex
+	salt    []byte
+	key     []byte
+	secrets map[string]string
+}
+
+// Open returns the vault stored in the tool's state directory, it starts locked
+func Open() (*Vault, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return &Vault{path: filepath.Join(dir, "secrets.gtvault")}, nil
+}
+
+// Exists reports whether a vault has been created
+func (v *Vault) Exists() bool {
+	_, err := os.Stat(v.path)
+	return err == nil
+}
+
+// Unlocked reports whether secrets can currently be read
+func (v *Vault) Unlocked() bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.key != nil
+}
+
+// Create makes a new empty vault protected by passphrase, an existing vault is never overwritten
+func (v *Vault) Create(passphrase string) error {
+	if v.Exists() {
+		return fmt.Errorf("a secret vault already exists at %s", v.path)
+	}
+	if passphrase == "" {
+		return fmt.Errorf("passphrase cannot be empty")
+	}
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	v.salt = salt
+	v.key = key
+	v.secrets = make(map[string]string)
+	v.mu.Unlock()
+	return v.Save()
+}
+
+// Unlock decrypts the vault with passphrase
+func (v *Vault) Unlock(passphrase string) error {
+	contents, err := os.ReadFile(v.path)
+	if err != nil {
+		return err
+	}
+	f := &file{}
+	if err = json.Unmarshal(contents, f); err != nil {
+		return fmt.Errorf("%s: %w", v.path, err)
+	}
+	if f.Version != fileVersion {
+		return fmt.Errorf("%s: unsupported vault version %d", v.path, f.Version)
+	}
+
+	key, err := deriveKey(passphrase, f.Salt)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Ciphertext, nil)
+	if err != nil {
+		return ErrPassphrase
+	}
+	secrets := make(map[string]string)
+	if err = json.Unmarshal(plain, &secrets); err != nil {
+		return fmt.Errorf("%s: %w", v.path, err)
+	}
+
+	v.mu.Lock()
+	v.salt = f.Salt
+	v.key = key
+	v.secrets = secrets
+	v.mu.Unlock()
+	return nil
+}
+
+// Lock forgets the key and decrypted secrets
+func (v *Vault) Lock() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.key = nil
+	v.secrets = nil
+}
+
+// Save encrypts the secrets with a fresh nonce and writes the vault
+func (v *Vault) Save() error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.key == nil {
+		return ErrLocked
+	}
+
+	plain, err := json.Marshal(v.secrets)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(v.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return err
+	}
+	contents, err := json.Marshal(&file{
+		Version:    fileVersion,
+		Salt:       v.salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plain, nil),
+	})
+	if err != nil {
+		return err
+	}
+	return writeAtomic(v.path, contents)
+}
+
+// writeAtomic replaces the file at path with contents through a temporary file in the same directory, so a crash
+// leaves either the old or the new vault and never a truncated one
+func writeAtomic(path string, contents []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), ".secrets-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if err = temp.Chmod(0600); err != nil {
+		_ = temp.Close()
+		return err
+	}
+	if _, err = temp.Write(contents); err != nil {
+		_ = temp.Close()
+		return err
+	}
+	if err = temp.Sync(); err != nil {
+		_ = temp.Close()
+		return err
+	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+// Names returns the sorted names of every secret
+func (v *Vault) Names() []string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return slices.Sorted(maps.Keys(v.secrets))
+}
+
+// Get returns the value of a secret
+func (v *Vault) Get(name string) (string, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.key == nil {
+		return "", ErrLocked
+	}
+	value, ok := v.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("unknown secret %q", name)
+	}
+	return value, nil
+}
+
+// Set adds or replaces a secret, Save must be called to persist it
+func (v *Vault) Set(name string, value string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return ErrLocked
+	}
+	if name == "" || strings.ContainsAny(name, "\"\\") {
+		return fmt.Errorf("invalid secret name %q", name)
+	}
+	v.secrets[name] = value
+	return nil
+}
+
+// Delete removes a secret, Save must be called to persist it
+func (v *Vault) Delete(name string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.secrets, name)
+}
+
+// Reference returns the template action that refers to a secret
+func Reference(name string) string {
+	return "{{secret \"" + name + "\"}}"
+}
+
+// Redact replaces secrets in text with references so it can be stored safely. Only whole values or words are
+// replaced, in JSON only string values, so a short secret never changes unrelated text
+func (v *Vault) Redact(text string) string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if len(v.secrets) == 0 {
+		return text
+	}
+	if json.Valid([]byte(text)) {
+		return v.redactJSON(text)
+	}
+	redacted, _ := v.redactText(text, nil)
+	return redacted
+}
+
+// RedactValue replaces the secrets held by a single field, such as a header value or a credential
+func (v *Vault) RedactValue(value string) string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	redacted, _ := v.redactText(value, nil)
+	return redacted
+}
+
+// reference returns the reference to the secret that value is, on its own or base64 encoded
+func (v *Vault) reference(value string) (string, bool) {
+	if value == "" {
+		return "", false
+	}
+	// sorted so a value held by two secrets always gets the same reference
+	for _, name := range slices.Sorted(maps.Keys(v.secrets)) {
+		secret := v.secrets[name]
+		switch {
+		case secret == "":
+		case value == secret:
+			return Reference(name), true
+		case value == base64.StdEncoding.EncodeToString([]byte(secret)):
+			return "{{base64 (secret \"" + name + "\")}}", true
+		}
+	}
+	return "", false
+}
+
+// redactText replaces a secret held by the whole of text, otherwise by any of its words, where a word ends at white
+// space, quotes or brackets. escape is applied to the text kept around references, it reports whether any were made
+func (v *Vault) redactText(text string, escape func(string) string) (string, bool) {
+	if escape == nil {
+		escape = func(s string) string { return s }
+	}
+	if reference, ok := v.reference(strings.TrimSpace(text)); ok {
+		return reference, true
+	}
+	isSeparator := func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune("\"'`,;()[]{}<>", r)
+	}
+	var sb strings.Builder
+	changed := false
+	kept, word := 0, -1
+	flush := func(end int) {
+		if word < 0 {
+			return
+		}
+		if reference, ok := v.reference(text[word:end]); ok {
+			sb.WriteString(escape(text[kept:word]) + reference)
+			kept, changed = end, true
+		}
+		word = -1
+	}
+	for i, r := range text {
+		if isSeparator(r) {
+			flush(i)
+		} else if word < 0 {
+			word = i
+		}
+	}
+	flush(len(text))
+	sb.WriteString(escape(text[kept:]))
+	return sb.String(), changed
+}
+
+// redactJSON replaces secrets in the string values of a JSON document, leaving the rest exactly as it was.
+// References are written without escaping their quotes, as template actions are evaluated before JSON is parsed
+func (v *Vault) redactJSON(text string) string {
+	escapeJSON := func(s string) string {
+		quoted, _ := json.Marshal(s)
+		return string(quoted[1 : len(quoted)-1])
+	}
+	var sb strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '"' {
+			sb.WriteByte(text[i])
+			continue
+		}
+		end := i + 1
+		for end < len(text) && text[end] != '"' {
+			if text[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		literal := text[i : end+1]
+		i = end
+		// object keys are left alone, only values are sent
+		if rest := strings.TrimLeft(text[end+1:], " \t\r\n"); strings.HasPrefix(rest, ":") {
+			sb.WriteString(literal)
+			continue
+		}
+		var value string
+		if err := json.Unmarshal([]byte(literal), &value); err != nil {
+			sb.WriteString(literal)
+			continue
+		}
+		if redacted, changed := v.redactText(value, escapeJSON); changed {
+			sb.WriteString(`"` + redacted + `"`)
+		} else {
+			sb.WriteString(literal)
+		}
+	}
+	return sb.String()
+}
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keyLength)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
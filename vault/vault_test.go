@@ -0,0 +1,113 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func unlockedVault(t *testing.T, secrets map[string]string) *Vault {
+	t.Helper()
+	v := &Vault{path: filepath.Join(t.TempDir(), "secrets.gtvault")}
+	if err := v.Create("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range secrets {
+		if err := v.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return v
+}
+
+func TestRedact(t *testing.T) {
+	v := unlockedVault(t, map[string]string{
+		"token": "s3cr3t-t0ken",
+		"short": "a",
+		"quote": `pa"ss`,
+	})
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"whole value", "s3cr3t-t0ken", `{{secret "token"}}`},
+		{"scheme", "Bearer s3cr3t-t0ken", `Bearer {{secret "token"}}`},
+		{"base64", "czNjcjN0LXQwa2Vu", `{{base64 (secret "token")}}`},
+		{"json value", `{"token": "s3cr3t-t0ken", "other": "x b"}`, `{"token": "{{secret "token"}}", "other": "x b"}`},
+		{"json escaped value", `{"password": "pa\"ss"}`, `{"password": "{{secret "quote"}}"}`},
+		{"json unicode escape", `{"token": "s3cr3t-t\u0030ken"}`, `{"token": "{{secret "token"}}"}`},
+		{"json scheme", `{"auth": "Bearer s3cr3t-t0ken"}`, `{"auth": "Bearer {{secret "token"}}"}`},
+		{"json keeps formatting", "{\n  \"b\": 1,\n  \"a\": [\"s3cr3t-t0ken\"]\n}", "{\n  \"b\": 1,\n  \"a\": [\"{{secret \"token\"}}\"]\n}"},
+		{"json words", `{"text": "my s3cr3t-t0ken, again"}`, `{"text": "my {{secret "token"}}, again"}`},
+		{"json keys left alone", `{"s3cr3t-t0ken": 1}`, `{"s3cr3t-t0ken": 1}`},
+		{"json escapes", `{"text": "line\nwith\u0041 s3cr3t-t0ken"}`, `{"text": "line\nwithA {{secret "token"}}"}`},
+		{"short secret", `{"name": "banana", "letter": "a"}`, `{"name": "banana", "letter": "{{secret "short"}}"}`},
+		{"text words", "rpc error: invalid token s3cr3t-t0ken (expired)", `rpc error: invalid token {{secret "token"}} (expired)`},
+		{"text short secret", "a cat ate a banana", `{{secret "short"}} cat ate {{secret "short"}} banana`},
+		{"text part of word", "xs3cr3t-t0ken", "xs3cr3t-t0ken"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := v.Redact(tt.text); got != tt.want {
+				t.Errorf("Redact(%q)\ngot  %q\nwant %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactValue(t *testing.T) {
+	v := unlockedVault(t, map[string]string{"token": "abc123"})
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"abc123", `{{secret "token"}}`},
+		{"Bearer abc123", `Bearer {{secret "token"}}`},
+		{"abc1234", "abc1234"},
+		{"prefix-abc123", "prefix-abc123"},
+		{"token abc123 token", `token {{secret "token"}} token`},
+		{`{{secret "token"}}`, `{{secret "token"}}`},
+	}
+	for _, tt := range tests {
+		if got := v.RedactValue(tt.value); got != tt.want {
+			t.Errorf("RedactValue(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSaveReplacesVault(t *testing.T) {
+	v := unlockedVault(t, map[string]string{"token": "one"})
+	if err := v.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Set("token", "two"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Save(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(v.path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the vault file, found %d entries", len(entries))
+	}
+	info, err := os.Stat(v.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("vault mode %v, want 0600", info.Mode().Perm())
+	}
+
+	reopened := &Vault{path: v.path}
+	if err = reopened.Unlock("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := reopened.Get("token"); err != nil || value != "two" {
+		t.Errorf("token = %q, %v", value, err)
+	}
+}